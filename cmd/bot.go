@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/paulvasilenko/discordbot/discordbot/audit"
+	"github.com/paulvasilenko/discordbot/discordbot/confify"
+	"github.com/paulvasilenko/discordbot/discordbot/guildsettings"
+	"github.com/paulvasilenko/discordbot/discordbot/haiku"
+	"github.com/paulvasilenko/discordbot/discordbot/help"
+	"github.com/paulvasilenko/discordbot/discordbot/homog"
+	"github.com/paulvasilenko/discordbot/discordbot/lifecycle"
+	"github.com/paulvasilenko/discordbot/discordbot/metrics"
+	"github.com/paulvasilenko/discordbot/discordbot/permissions"
+	"github.com/paulvasilenko/discordbot/discordbot/plugin"
+	"github.com/paulvasilenko/discordbot/discordbot/racing"
+	"github.com/paulvasilenko/discordbot/discordbot/ratelimit"
+	"github.com/paulvasilenko/discordbot/discordbot/report"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+	"github.com/paulvasilenko/discordbot/discordbot/scheduler"
+	"github.com/paulvasilenko/discordbot/discordbot/sdr"
+	"github.com/paulvasilenko/discordbot/discordbot/smileystats"
+	"github.com/paulvasilenko/discordbot/discordbot/storage"
+	"github.com/paulvasilenko/discordbot/discordbot/tts"
+	"github.com/paulvasilenko/discordbot/migrations"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// bot is a router with registered plugins, it is shared by bot, replay and console subcommands
+type bot struct {
+	router      *router.Router
+	registry    *plugin.Registry
+	settings    *guildsettings.Manager
+	reloader    *reloader
+	racing      racing.Store
+	smileyStats *smileystats.SmileyStats
+	reporter    *report.Reporter
+	scheduler   *scheduler.Scheduler
+	audit       *audit.Log
+	// confify is nil when plugin is disabled
+	confify *confify.Confify
+}
+
+// newBot registers plugins and their commands, plugins are initialized by registry.Init
+func newBot(conf Config, db *storage.DB, lc *lifecycle.Manager) (*bot, error) {
+	reporter := report.NewReporter(conf.Reports.ChannelID, conf.Reports.Limit, conf.Reports.Period)
+	lc.SetPanicHandler(func(p interface{}, stack []byte) {
+		reporter.Panic("background task", p, stack, nil)
+	})
+
+	rt := router.New(conf.Prefix)
+	if err := rt.Add("PandaBot", &router.Command{
+		Name:        "pandabot",
+		Description: "Answers !pandabot",
+		Handler:     pandabot,
+	}); err != nil {
+		return nil, errors.Wrap(err, "failed to add command")
+	}
+
+	settings := guildsettings.NewManager(guildsettings.NewSQLStore(db), conf.Prefix)
+	rt.SetPrefixFunc(settings.Prefix)
+	checker := permissions.NewChecker(settings)
+	limiter, err := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), conf.RateLimits)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to init rate limiter")
+	}
+	auditLog := audit.NewLog(audit.NewSQLStore(db), conf.Audit.Retention)
+	// Audit log is outside of reporter, so panics are recorded as errors
+	rt.Use(metrics.Middleware, auditLog.Middleware, reporter.Middleware, lc.Middleware, settings.Middleware, checker.Middleware, limiter.Middleware)
+
+	registry := plugin.NewRegistry(rt)
+	registry.SetGuard(settings)
+	registry.SetLifecycle(lc)
+	registry.SetReporter(reporter)
+	sched := scheduler.NewScheduler(scheduler.NewSQLStore(db), lc)
+	registry.SetScheduler(sched)
+
+	ttsClient := &tts.TTSClient{
+		Client:     &http.Client{},
+		RequestURL: conf.TTS.RequestURL,
+	}
+	textToSpeech := tts.NewTTS(ttsClient)
+	textToSpeech.Limiter = limiter
+	smileyStats := smileystats.NewSmileyStats(smileystats.NewStore(db), conf.SmileyStats.Blacklist)
+
+	var plugins []plugin.Plugin
+	// Faces are not needed only by stub backend
+	var confifyPlugin *confify.Confify
+	if conf.FacesDir != "" || conf.Confify.Backend == confify.BackendStub {
+		confifyPlugin, err = newConfify(conf)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to init confify")
+		}
+		plugins = append(plugins, confifyPlugin)
+	} else {
+		log.Println("Confify is disabled, FacesDir is not set")
+	}
+
+	plugins = append(plugins,
+		homog.NewHomog(),
+		haiku.NewHaiku(),
+		textToSpeech,
+		smileyStats,
+	)
+
+	// Racing is deprecated, it's registered only on request
+	var racingStore racing.Store
+	if conf.Racing.Enabled {
+		racingStore = racing.NewStore(db)
+		plugins = append(plugins, racing.NewRacing(racingStore))
+	} else {
+		log.Println("Racing is disabled, set Racing.Enabled to enable it")
+	}
+
+	texts, gifts, err := loadSDR(conf)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load sdr data")
+	}
+
+	sdrPlugin, err := sdr.NewSDR(texts, gifts)
+	if err != nil {
+		log.Println(err)
+	} else {
+		plugins = append(plugins, sdrPlugin)
+	}
+
+	plugins = append(plugins,
+		guildsettings.NewConfig(settings, registry),
+		permissions.NewPermissions(checker, settings, rt),
+		scheduler.NewJobs(sched),
+		audit.NewAudit(auditLog, rt),
+		help.NewHelp(registry),
+	)
+	for _, p := range plugins {
+		if err := registry.Register(p); err != nil {
+			return nil, errors.Wrap(err, "failed to register plugin")
+		}
+	}
+
+	reload := &reloader{
+		conf:        conf,
+		smileyStats: smileyStats,
+		sdr:         sdrPlugin,
+		tts:         ttsClient,
+		confify:     confifyPlugin,
+	}
+	if err := rt.Add("PandaBot", &router.Command{
+		Name:        "reload",
+		Description: "Reloads config and data files of plugins, available to bot admins",
+		Handler:     reload.command,
+	}); err != nil {
+		return nil, errors.Wrap(err, "failed to add command")
+	}
+
+	return &bot{
+		router:      rt,
+		registry:    registry,
+		settings:    settings,
+		reloader:    reload,
+		racing:      racingStore,
+		smileyStats: smileyStats,
+		reporter:    reporter,
+		scheduler:   sched,
+		audit:       auditLog,
+		confify:     confifyPlugin,
+	}, nil
+}
+
+// newConfify returns confify plugin with queue, backend and output from config
+func newConfify(conf Config) (*confify.Confify, error) {
+	swapper, err := newFaceSwapper(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	c := confify.NewConfify(conf.BasePath, conf.BaseUrl, conf.FacesDir)
+	c.SetQueue(confify.NewQueue(conf.Confify.Workers, conf.Confify.UserLimit, conf.Confify.QueueLimit, conf.Confify.Timeout))
+	c.SetSwapper(swapper)
+	c.SetOutput(conf.Confify.Output, conf.Confify.UploadLimit)
+	c.SetInputLimits(conf.Confify.DownloadLimit, conf.Confify.MaxPixels)
+	c.SetRetention(conf.Confify.TTL, conf.Confify.MaxSize)
+	c.SetAdmins(conf.Admins)
+
+	return c, nil
+}
+
+// newFaceSwapper returns confify backend chosen in config
+func newFaceSwapper(conf Config) (confify.FaceSwapper, error) {
+	switch conf.Confify.Backend {
+	case confify.BackendChrisify:
+		return confify.NewChrisify(), nil
+	case confify.BackendCascade:
+		cs, err := confify.NewCascadeSwapper(conf.Confify.Cascade)
+		if err != nil {
+			return nil, err
+		}
+		cs.MaxFrames = conf.Confify.MaxFrames
+		cs.MaxPixels = conf.Confify.MaxPixels
+		cs.MaxBytes = int(conf.Confify.UploadLimit)
+
+		return cs, nil
+	case confify.BackendStub:
+		return &confify.StubSwapper{}, nil
+	default:
+		return nil, errors.Errorf("unknown confify backend %q", conf.Confify.Backend)
+	}
+}
+
+// newOfflineBot returns bot with initialized plugins and in-memory SQLite database, it doesn't connect to discord.
+// Database is closed by lifecycle shutdown
+func newOfflineBot(conf Config) (*bot, *lifecycle.Manager, error) {
+	db, err := storage.Open(storage.SQLite, ":memory:")
+	if err != nil {
+		return nil, nil, err
+	}
+	lc := lifecycle.NewManager()
+	lc.OnShutdown("storage", func(ctx context.Context) error {
+		return db.Close()
+	})
+
+	runner, err := migrations.NewRunner(db.DB, db.Driver)
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	if _, err := runner.Up(); err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+
+	b, err := newBot(conf, db, lc)
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	// Session is never opened, it is only needed to init plugins
+	dg, err := discordgo.New("")
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	if err := b.registry.Init(dg); err != nil {
+		db.Close()
+		return nil, nil, errors.Wrap(err, "failed to init plugins")
+	}
+
+	return b, lc, nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/paulvasilenko/discordbot/discordbot/console"
+)
+
+// runConsole runs console subcommand: lines of input are passed through plugins as messages
+// of fake user with in-memory database, replies of bot are printed to output
+func runConsole(conf Config, args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("console", flag.ContinueOnError)
+	username := fs.String("user", "developer", "name of fake user")
+	userID := fs.String("id", "1", "id of fake user")
+	channelID := fs.String("channel", "console", "id of channel messages are sent to")
+	filesDir := fs.String("files", filepath.Join(os.TempDir(), "discordbot-console"), "directory where files sent by bot are saved")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	b, lc, err := newOfflineBot(conf)
+	if err != nil {
+		return err
+	}
+
+	c := console.New(b.registry, &discordgo.User{ID: *userID, Username: *username}, *channelID, *filesDir)
+	fmt.Fprintf(out, "Type messages as %s, %s for help, %s to exit\n", *username, console.CommandHelp, console.CommandQuit)
+
+	err = c.Run(in, out)
+	if shutdownErr := lc.Shutdown(conf.ShutdownTimeout); err == nil {
+		err = shutdownErr
+	}
+
+	return err
+}
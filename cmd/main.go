@@ -2,40 +2,89 @@ package main
 
 import (
 	"context"
-	"database/sql"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	stdlog "log"
 	"net/http"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
-	_ "github.com/go-sql-driver/mysql"
 	"github.com/jinzhu/configor"
-	"github.com/paulvasilenko/discordbot/discordbot/confify"
-	"github.com/paulvasilenko/discordbot/discordbot/haiku"
-	"github.com/paulvasilenko/discordbot/discordbot/homog"
+	"github.com/paulvasilenko/discordbot/discordbot/admin"
+	"github.com/paulvasilenko/discordbot/discordbot/discord"
+	"github.com/paulvasilenko/discordbot/discordbot/lifecycle"
+	"github.com/paulvasilenko/discordbot/discordbot/metrics"
+	"github.com/paulvasilenko/discordbot/discordbot/ratelimit"
+	"github.com/paulvasilenko/discordbot/discordbot/recorder"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
 	"github.com/paulvasilenko/discordbot/discordbot/sdr"
-	"github.com/paulvasilenko/discordbot/discordbot/smileystats"
-	"github.com/paulvasilenko/discordbot/discordbot/tts"
+	"github.com/paulvasilenko/discordbot/discordbot/secret"
+	"github.com/paulvasilenko/discordbot/discordbot/storage"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/sync/errgroup"
 )
 
+// envPrefix is prefix of environment variables overriding config, e.g. DISCORDBOT_MYSQL_PASSWORD
+const envPrefix = "DISCORDBOT"
+
+// redactor hides secrets from config in logs
+var redactor = secret.NewRedactor(&log.TextFormatter{})
+
 type Config struct {
-	Token          string `required:"true" yaml:"Token"`
-	BaseUrl        string `required:"true" yaml:"BaseUrl"`
-	BasePath       string `required:"true" yaml:"BasePath"`
+	// Token is required to connect to discord, console and replay subcommands work without it
+	Token  string `yaml:"Token"`
+	Prefix string `default:"!" yaml:"Prefix"`
+	// BaseUrl is public url of BasePath, links to processed images are sent only when it's set
+	BaseUrl string `yaml:"BaseUrl"`
+	// BasePath keeps images of confify and is served by file server, temporary directory is used if it's empty
+	BasePath       string `yaml:"BasePath"`
 	FileServerPort string `default:":80" yaml:"FileServerPort"`
-	FacesDir       string `required:"true" yaml:"FacesDir"`
-	Mongo          struct {
-		Host string `yaml:"Host"`
-		Port string `yaml:"Port"`
-	} `yaml:"Mongo"`
+	// ShutdownTimeout is time given to running commands to finish on stop, then the same time is given to cleanup
+	ShutdownTimeout time.Duration `default:"30s" yaml:"ShutdownTimeout"`
+	// FacesDir is directory with faces of confify, confify is disabled if it's empty
+	FacesDir string `yaml:"FacesDir"`
+	Confify  struct {
+		// Workers is number of images processed at the same time, the rest wait in queue
+		Workers int `default:"2" yaml:"Workers"`
+		// UserLimit is number of images one user may have in queue, 0 disables limit
+		UserLimit int `default:"2" yaml:"UserLimit"`
+		// QueueLimit is number of images waiting for free worker, 0 disables limit
+		QueueLimit int `default:"20" yaml:"QueueLimit"`
+		// Timeout is time given to process one image
+		Timeout time.Duration `default:"2m" yaml:"Timeout"`
+		// Backend swaps faces: chrisify runs external binary, cascade detects faces in process, stub returns image as is
+		Backend string `default:"chrisify" yaml:"Backend"`
+		// Cascade is face finder file in pico format used by cascade backend
+		Cascade string `yaml:"Cascade"`
+		// Output is attachment to upload processed images or url to send links to them
+		Output string `default:"attachment" yaml:"Output"`
+		// UploadLimit is maximal size of uploaded image in bytes, larger ones are sent as links
+		UploadLimit int64 `default:"10485760" yaml:"UploadLimit"`
+		// MaxFrames is number of frames in animation processed by cascade backend, 0 keeps every frame
+		MaxFrames int `default:"100" yaml:"MaxFrames"`
+		// MaxPixels is width × height of image, multiplied by number of frames of animation processed by cascade backend,
+		// larger ones are rejected before decoding, 0 disables limit
+		MaxPixels int64 `default:"67108864" yaml:"MaxPixels"`
+		// DownloadLimit is maximal size of downloaded image in bytes, larger ones are rejected, 0 disables limit
+		DownloadLimit int64 `default:"26214400" yaml:"DownloadLimit"`
+		// TTL is time processed images are kept in BasePath, 0 keeps them until they are evicted
+		TTL time.Duration `default:"168h" yaml:"TTL"`
+		// MaxSize is total size of images in BasePath in bytes, least recently used are evicted above it, 0 disables limit
+		MaxSize int64 `default:"1073741824" yaml:"MaxSize"`
+	} `yaml:"Confify"`
+	Storage struct {
+		// Driver is mysql or sqlite, sqlite needs no database server
+		Driver string `default:"mysql" yaml:"Driver"`
+		// Path is file of sqlite database
+		Path string `default:"pandabot.db" yaml:"Path"`
+		// AutoMigrate applies pending migrations on start
+		AutoMigrate bool `yaml:"AutoMigrate"`
+	} `yaml:"Storage"`
 	Mysql struct {
 		Host     string `yaml:"Host"`
 		Port     string `yaml:"Port"`
@@ -43,32 +92,101 @@ type Config struct {
 		Password string `yaml:"Password"`
 		Name     string `default:"pandabot" yaml:"Name"`
 	} `yaml:"Mysql"`
+	Racing struct {
+		// Enabled registers deprecated racing plugin
+		Enabled bool `yaml:"Enabled"`
+	} `yaml:"Racing"`
+	// Mongo is deprecated and ignored, it's kept so old configs are loaded. Racing lobby is kept in Storage
+	Mongo struct {
+		Host string `yaml:"Host"`
+		Port string `yaml:"Port"`
+	} `yaml:"Mongo"`
 	TTS struct {
 		RequestURL string `yaml:"RequestURL"`
 	} `yaml:"TTS"`
 	SmileyStats struct {
 		Blacklist map[string]string `yaml:"Blacklist"`
 	} `yaml:"SmileyStats"`
+	// RateLimits are rules by command names, e.g. confify or speak for voice messages
+	RateLimits    map[string][]ratelimit.Rule `yaml:"RateLimits"`
+	SlashCommands struct {
+		Disabled bool `yaml:"Disabled"`
+		// GuildID registers commands only in this guild, they are updated instantly there
+		GuildID string `yaml:"GuildID"`
+	} `yaml:"SlashCommands"`
 	SDR struct {
 		Texts string `yaml:"Texts"`
 		Gifts string `yaml:"Gifts"`
 	} `yaml:"SDR"`
+	// Admins are ids of users allowed to call bot wide commands, e.g. !reload
+	Admins []string `yaml:"Admins"`
+	Admin  struct {
+		// Token enables admin api on file server, it is passed in Authorization: Bearer header
+		Token string `yaml:"Token"`
+	} `yaml:"Admin"`
+	Recorder struct {
+		// Path is JSONL file gateway events are appended to, events are not recorded if it's empty
+		Path string `yaml:"Path"`
+		// Events are types of recorded events, e.g. MESSAGE_CREATE, events handled by plugins are recorded by default
+		Events []string `yaml:"Events"`
+	} `yaml:"Recorder"`
+	Reports struct {
+		// ChannelID is admin channel where panics and errors of handlers are posted, they are only logged if it's empty
+		ChannelID string `yaml:"ChannelID"`
+		// Limit is number of reports posted per Period, same reports are posted once per Period
+		Limit  int           `default:"5" yaml:"Limit"`
+		Period time.Duration `default:"10m" yaml:"Period"`
+	} `yaml:"Reports"`
+	Audit struct {
+		// Retention is how long commands are kept in audit log, 0 keeps them forever
+		Retention time.Duration `default:"2160h" yaml:"Retention"`
+	} `yaml:"Audit"`
 }
 
 func main() {
-	log.SetFormatter(&log.TextFormatter{})
+	log.SetFormatter(redactor)
+	// discordgo logs with standard logger
+	stdlog.SetFlags(0)
+	stdlog.SetOutput(log.StandardLogger().Writer())
 
-	conf := Config{}
-	err := configor.
-		New(&configor.Config{
-			ErrorOnUnmatchedKeys: true,
-		}).
-		Load(&conf, "config.yaml", "conf/config.yaml", "/etc/discordbot/config.yaml")
+	conf, err := loadConfig()
 	if err != nil {
 		log.Fatalf("failed to load config: %v", err)
 	}
 
-	log.Println("Opening connection with token: ", conf.Token)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			if err := migrate(initStorage(conf), os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "replay":
+			if err := replay(conf, os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "console":
+			if err := runConsole(conf, os.Args[2:], os.Stdin, os.Stdout); err != nil {
+				log.Fatal(err)
+			}
+			return
+		default:
+			log.Fatalf("unknown command %q, usage: discordbot [migrate up|down|status | replay [-expect file] recording | console]", os.Args[1])
+		}
+	}
+
+	if conf.Token == "" {
+		log.Fatal("Token is required to connect to discord")
+	}
+	if err := validateConfig(conf); err != nil {
+		log.Fatalf("invalid config: %v", err)
+	}
+	if conf.Mongo.Host != "" {
+		log.Println("Mongo config is deprecated and ignored, racing lobby is kept in Storage, racers joined before upgrade must join again")
+	}
+
+	log.Println("Opening connection to discord")
 
 	dg, err := discordgo.New(conf.Token)
 	if err != nil {
@@ -76,37 +194,134 @@ func main() {
 	}
 
 	dg.AddHandler(ready)
-	dg.AddHandler(messageCreate)
+	dg.AddHandler(metrics.Event)
+
+	lc := lifecycle.NewManager()
 
-	c := confify.NewConfify(conf.BasePath, conf.BaseUrl, conf.FacesDir)
-	dg.AddHandler(c.MessageCreate)
+	db := initStorage(conf)
+	lc.OnShutdown("storage", func(ctx context.Context) error {
+		return db.Close()
+	})
+	if conf.Storage.AutoMigrate {
+		if err := migrate(db, []string{"up"}); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if conf.Recorder.Path != "" {
+		rec, err := recorder.Open(conf.Recorder.Path, conf.Recorder.Events)
+		if err != nil {
+			log.Fatal(err)
+		}
+		dg.AddHandler(rec.Event)
+		lc.OnShutdown("recorder", func(ctx context.Context) error {
+			return rec.Close()
+		})
+	}
+
+	b, err := newBot(conf, db, lc)
+	if err != nil {
+		log.Fatal(err)
+	}
+	rt, registry, reload := b.router, b.registry, b.reloader
+	b.reporter.SetSession(discord.Wrap(dg))
 
-	http.Handle("/", http.FileServer(http.Dir(conf.BasePath)))
+	mux := http.NewServeMux()
+	if conf.BasePath != "" && b.confify != nil {
+		mux.Handle("/", b.confify.FileServer())
+	} else if conf.BasePath != "" {
+		mux.Handle("/", http.FileServer(http.Dir(conf.BasePath)))
+	}
+	mux.Handle("/metrics", promhttp.Handler())
+	fileServer := &http.Server{Addr: conf.FileServerPort, Handler: mux}
 	go func() {
-		if err := http.ListenAndServe(conf.FileServerPort, nil); err != nil {
+		if err := fileServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Println("failed to run fileserver: ", err)
 		}
 	}()
 
-	h := homog.NewHomog()
-	dg.AddHandler(h.MessageCreate)
+	if conf.Admin.Token != "" {
+		mux.Handle(admin.Prefix, admin.NewAPI(conf.Admin.Token, dg, registry, b.settings, b.racing, b.smileyStats, b.audit))
+	}
+
+	if err := registry.Init(dg); err != nil {
+		log.Fatalf("failed to init plugins: %v", err)
+	}
+
+	if err := rt.ValidateApplicationCommands(); err != nil {
+		log.Fatalf("invalid application commands: %v", err)
+	}
+	if !conf.SlashCommands.Disabled {
+		dg.AddHandler(func(s *discordgo.Session, r *discordgo.Ready) {
+			if err := rt.SyncCommands(s, r.User.ID, conf.SlashCommands.GuildID); err != nil {
+				log.Println("failed to sync application commands: ", err)
+			}
+		})
+	}
+
+	if err = dg.Open(); err != nil {
+		log.Fatalf("error opening Discord session: %v", err)
+	}
 
-	haiku := haiku.NewHaiku()
-	dg.AddHandler(haiku.MessageCreate)
+	// Jobs missed while bot was stopped are run after session is opened
+	b.scheduler.Start(discord.Wrap(dg))
 
-	textToSpeech := tts.NewTTS(&tts.TTSClient{
-		Client:     &http.Client{},
-		RequestURL: conf.TTS.RequestURL,
+	// Hooks are called in reverse order: scheduler, file server, plugins, discord session, recorder and storage
+	lc.OnShutdown("discord session", func(ctx context.Context) error {
+		return dg.Close()
 	})
+	lc.OnShutdown("plugins", func(ctx context.Context) error {
+		return registry.Close()
+	})
+	lc.OnShutdown("file server", fileServer.Shutdown)
+	lc.OnShutdown("scheduler", b.scheduler.Stop)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go signalHandler(cancel, reload.Reload)
+
+	log.Println("PandaBot is now running, Version 0.0.1.  Press CTRL-C to exit.")
+	<-ctx.Done()
+
+	if err := lc.Shutdown(conf.ShutdownTimeout); err != nil {
+		log.Println("failed to shutdown: ", err)
+	}
+	log.Println("PandaBot is stopped")
+}
+
+// loadConfig loads config file, values are overridden by environment variables with envPrefix,
+// then secrets are read from files named in <variable>_FILE ones
+func loadConfig() (Config, error) {
+	conf := Config{}
+	err := configor.
+		New(&configor.Config{
+			ENVPrefix:            envPrefix,
+			ErrorOnUnmatchedKeys: true,
+		}).
+		Load(&conf, "config.yaml", "conf/config.yaml", "/etc/discordbot/config.yaml")
+	if err != nil {
+		return conf, err
+	}
 
-	dg.AddHandler(textToSpeech.MessageReactionAdd)
+	for env, value := range conf.secrets() {
+		if err := secret.FromFile(env, value); err != nil {
+			return conf, err
+		}
+		redactor.Add(*value)
+	}
 
-	mysqlConn := initMysql(conf)
+	return conf, nil
+}
 
-	emotesStats := smileystats.NewSmileyStats(mysqlConn, conf.SmileyStats.Blacklist)
-	dg.AddHandler(emotesStats.MessageCreate)
-	dg.AddHandler(emotesStats.MessageReactionAdd)
+// secrets returns config fields hidden in logs by their environment variables
+func (c *Config) secrets() map[string]*string {
+	return map[string]*string{
+		envPrefix + "_TOKEN":          &c.Token,
+		envPrefix + "_MYSQL_PASSWORD": &c.Mysql.Password,
+		envPrefix + "_ADMIN_TOKEN":    &c.Admin.Token,
+	}
+}
 
+func loadSDR(conf Config) (map[int]string, map[int]sdr.Gift, error) {
 	var (
 		texts map[int]string
 		gifts map[int]sdr.Gift
@@ -126,45 +341,24 @@ func main() {
 		}
 		return json.Unmarshal(bytes, &gifts)
 	})
-	if err := gr.Wait(); err != nil {
-		log.Fatal(err)
-	}
-
-	sdrPlugin, err := sdr.NewSDR(texts, gifts)
-	if err != nil {
-		log.Println(err)
-	} else {
-		dg.AddHandler(sdrPlugin.MessageCreate)
-	}
-
-	if err = dg.Open(); err != nil {
-		log.Fatalf("error opening Discord session: %v", err)
-	}
-	defer dg.Close()
-
-	ctx, cancel := context.WithCancel(context.Background())
-	go signalHandler(cancel)
 
-	log.Println("PandaBot is now running, Version 0.0.1.  Press CTRL-C to exit.")
-	<-ctx.Done()
-	return
+	return texts, gifts, gr.Wait()
 }
 
-func initMysql(conf Config) *sql.DB {
-	dsn := conf.Mysql.User + ":" +
-		conf.Mysql.Password +
-		"@tcp(" + conf.Mysql.Host + ":" + conf.Mysql.Port + ")/" +
-		conf.Mysql.Name
+func initStorage(conf Config) *storage.DB {
+	dsn := conf.Storage.Path
+	if conf.Storage.Driver == storage.MySQL {
+		dsn = conf.Mysql.User + ":" +
+			conf.Mysql.Password +
+			"@tcp(" + conf.Mysql.Host + ":" + conf.Mysql.Port + ")/" +
+			conf.Mysql.Name
+	}
 
-	db, err := sql.Open("mysql", dsn)
+	db, err := storage.Open(conf.Storage.Driver, dsn)
 	if err != nil {
-		log.Fatalf("failed to open mysql connection: %v", err)
+		log.Fatalf("failed to open storage: %v", err)
 	}
 
-	db.SetMaxIdleConns(1)
-	db.SetMaxOpenConns(50)
-	db.SetConnMaxLifetime(1 * time.Second)
-
 	return db
 }
 
@@ -175,21 +369,22 @@ func ready(s *discordgo.Session, event *discordgo.Ready) {
 	}
 }
 
-func messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
-	if strings.HasPrefix(m.Content, "!pandabot") {
-		_, err := s.ChannelMessageSend(m.ChannelID, "PandaBot")
-		if err != nil {
-			fmt.Println("ChannelMessageSend error:", err)
-		}
-		return
-	}
+func pandabot(ctx *router.Context) error {
+	_, err := ctx.Reply("PandaBot")
+	return err
 }
 
-func signalHandler(cancel context.CancelFunc) {
+func signalHandler(cancel context.CancelFunc, reload func() error) {
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGHUP, syscall.SIGTERM, syscall.SIGINT)
 	for {
-		<-sigCh
+		if sig := <-sigCh; sig == syscall.SIGHUP {
+			if err := reload(); err != nil {
+				log.Println("failed to reload config, old one is kept: ", err)
+			}
+			continue
+		}
+
 		fmt.Println("Got stop signal, safely shutting down")
 		cancel()
 		return
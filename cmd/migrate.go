@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/paulvasilenko/discordbot/discordbot/storage"
+	"github.com/paulvasilenko/discordbot/migrations"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// migrate runs migrate subcommand: up applies pending migrations,
+// down reverts last applied one and status prints state of all of them
+func migrate(db *storage.DB, args []string) error {
+	runner, err := migrations.NewRunner(db.DB, db.Driver)
+	if err != nil {
+		return err
+	}
+
+	action := "status"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	switch action {
+	case "up":
+		versions, err := runner.Up()
+		for _, v := range versions {
+			log.Println("Applied migration ", v)
+		}
+		if err != nil {
+			return err
+		}
+		if len(versions) == 0 {
+			log.Println("Database is up to date")
+		}
+	case "down":
+		version, err := runner.Down()
+		if err != nil {
+			return err
+		}
+		if version == "" {
+			log.Println("No applied migrations")
+			return nil
+		}
+		log.Println("Reverted migration ", version)
+	case "status":
+		statuses, err := runner.Status()
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.Applied() {
+				appliedAt = s.AppliedAt
+			}
+			fmt.Fprintf(w, "%s\t%s\n", s.Version, appliedAt)
+		}
+		return w.Flush()
+	default:
+		return errors.Errorf("unknown migrate action %q, expected up, down or status", action)
+	}
+
+	return nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"net/url"
+	"os"
+	"reflect"
+	"sync"
+
+	"github.com/paulvasilenko/discordbot/discordbot/confify"
+	"github.com/paulvasilenko/discordbot/discordbot/ratelimit"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+	"github.com/paulvasilenko/discordbot/discordbot/sdr"
+	"github.com/paulvasilenko/discordbot/discordbot/smileystats"
+	"github.com/paulvasilenko/discordbot/discordbot/storage"
+	"github.com/paulvasilenko/discordbot/discordbot/tts"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// minAdminTokenLength makes admin api token hard to guess
+const minAdminTokenLength = 16
+
+// reloadable are config fields which are swapped into running plugins,
+// other fields are applied after restart
+var reloadable = map[string]bool{
+	"FacesDir":    true,
+	"SmileyStats": true,
+	"TTS":         true,
+	"SDR":         true,
+	"Admins":      true,
+}
+
+// reloader re-reads config and data files of plugins and swaps them into running plugins
+type reloader struct {
+	mu   sync.Mutex
+	conf Config
+
+	smileyStats *smileystats.SmileyStats
+	// sdr is nil when plugin failed to start
+	sdr     *sdr.SDR
+	tts     *tts.TTSClient
+	confify *confify.Confify
+}
+
+// Reload loads and validates config, it keeps old values if new config is invalid
+func (r *reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	conf, err := loadConfig()
+	if err != nil {
+		return errors.Wrap(err, "failed to load config")
+	}
+	if err := validateConfig(conf); err != nil {
+		return errors.Wrap(err, "invalid config")
+	}
+	texts, gifts, err := loadSDR(conf)
+	if err != nil {
+		return errors.Wrap(err, "failed to load sdr data")
+	}
+	if r.sdr != nil {
+		if err := r.sdr.SetData(texts, gifts); err != nil {
+			return err
+		}
+	}
+
+	r.smileyStats.SetBlacklist(conf.SmileyStats.Blacklist)
+	r.tts.SetRequestURL(conf.TTS.RequestURL)
+	if r.confify != nil {
+		r.confify.SetFaces(conf.FacesDir)
+		r.confify.SetAdmins(conf.Admins)
+	}
+
+	for _, field := range changedFields(r.conf, conf) {
+		if !r.applied(field) {
+			log.Printf("Config option %s is changed, restart bot to apply it", field)
+		}
+	}
+	r.conf = conf
+
+	log.Println("Config is reloaded")
+
+	return nil
+}
+
+// applied returns true if changed config field is swapped into running plugin. Fields of plugins
+// which were not started, e.g. FacesDir of disabled confify, are applied after restart
+func (r *reloader) applied(field string) bool {
+	switch field {
+	case "FacesDir":
+		return r.confify != nil
+	case "SDR":
+		return r.sdr != nil
+	}
+
+	return reloadable[field]
+}
+
+// command reloads config by !reload, it's allowed only to bot admins from config
+func (r *reloader) command(ctx *router.Context) error {
+	r.mu.Lock()
+	admins := r.conf.Admins
+	r.mu.Unlock()
+
+	if !contains(admins, ctx.Message.Author.ID) {
+		return &router.DeniedError{Reason: "Only bot admins can reload config"}
+	}
+
+	if err := r.Reload(); err != nil {
+		_, replyErr := ctx.Reply("Config is not reloaded, old one is kept: " + err.Error())
+		if replyErr != nil {
+			return replyErr
+		}
+		return err
+	}
+
+	_, err := ctx.Reply("Config is reloaded")
+	return err
+}
+
+// validateConfig checks values which configor can't check
+func validateConfig(conf Config) error {
+	if conf.Storage.Driver != storage.MySQL && conf.Storage.Driver != storage.SQLite {
+		return errors.Errorf("unknown storage driver %q, expected %s or %s", conf.Storage.Driver, storage.MySQL, storage.SQLite)
+	}
+	if conf.Storage.Driver == storage.MySQL && (conf.Mysql.Host == "" || conf.Mysql.User == "") {
+		return errors.New("mysql host and user are required")
+	}
+
+	if conf.Admin.Token != "" && len(conf.Admin.Token) < minAdminTokenLength {
+		return errors.Errorf("admin token must be at least %d characters", minAdminTokenLength)
+	}
+
+	if conf.Reports.ChannelID != "" && (conf.Reports.Limit <= 0 || conf.Reports.Period <= 0) {
+		return errors.New("reports limit and period must be positive")
+	}
+
+	if conf.Confify.Workers <= 0 || conf.Confify.UserLimit < 0 || conf.Confify.QueueLimit < 0 || conf.Confify.MaxFrames < 0 || conf.Confify.MaxPixels < 0 ||
+		conf.Confify.DownloadLimit < 0 || conf.Confify.TTL < 0 || conf.Confify.MaxSize < 0 {
+		return errors.New("confify workers must be positive, user and queue limits, max frames, max pixels, download limit, ttl and max size must not be negative")
+	}
+	if _, err := newFaceSwapper(conf); err != nil {
+		return errors.Wrap(err, "invalid confify backend")
+	}
+
+	if conf.Confify.Output != confify.OutputAttachment && conf.Confify.Output != confify.OutputURL {
+		return errors.Errorf("unknown confify output %q, expected %s or %s", conf.Confify.Output, confify.OutputAttachment, confify.OutputURL)
+	}
+	if conf.Confify.Output == confify.OutputURL && (conf.BaseUrl == "" || conf.BasePath == "") {
+		return errors.New("base url and base path are required by url output of confify")
+	}
+
+	if conf.FacesDir != "" {
+		if info, err := os.Stat(conf.FacesDir); err != nil || !info.IsDir() {
+			return errors.Errorf("faces dir %s is not a directory", conf.FacesDir)
+		}
+	}
+
+	if conf.TTS.RequestURL != "" {
+		u, err := url.Parse(conf.TTS.RequestURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return errors.Errorf("tts request url %q is not http url", conf.TTS.RequestURL)
+		}
+	}
+
+	_, err := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), conf.RateLimits)
+
+	return err
+}
+
+// changedFields returns names of top level config fields which differ
+func changedFields(old, new Config) []string {
+	var changed []string
+	oldValue, newValue := reflect.ValueOf(old), reflect.ValueOf(new)
+	for i := 0; i < oldValue.NumField(); i++ {
+		if !reflect.DeepEqual(oldValue.Field(i).Interface(), newValue.Field(i).Interface()) {
+			changed = append(changed, oldValue.Type().Field(i).Name)
+		}
+	}
+
+	return changed
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/paulvasilenko/discordbot/discordbot/recorder"
+	"github.com/pkg/errors"
+)
+
+// replay runs replay subcommand: it passes recorded events through plugins with fake session
+// and in-memory database and prints responses of bot or compares them with expected ones
+func replay(conf Config, args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	expect := fs.String("expect", "", "file with expected output of replay, differences fail replay")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: discordbot replay [-expect file] recording")
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return errors.Wrap(err, "failed to open recording")
+	}
+	events, err := recorder.Read(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	b, lc, err := newOfflineBot(conf)
+	if err != nil {
+		return err
+	}
+
+	out := &bytes.Buffer{}
+	err = recorder.Replay(events, b.registry, out)
+	if shutdownErr := lc.Shutdown(conf.ShutdownTimeout); err == nil {
+		err = shutdownErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if *expect == "" {
+		_, err := os.Stdout.Write(out.Bytes())
+		return err
+	}
+
+	expected, err := os.ReadFile(*expect)
+	if err != nil {
+		return errors.Wrap(err, "failed to read expected output")
+	}
+	diff := recorder.Diff(string(expected), out.String())
+	for _, line := range diff {
+		fmt.Println(line)
+	}
+	if len(diff) > 0 {
+		return errors.Errorf("replay differs from %s", *expect)
+	}
+
+	return nil
+}
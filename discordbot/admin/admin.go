@@ -0,0 +1,405 @@
+// Package admin provides JSON API to watch and control the bot over HTTP,
+// every request must have "Authorization: Bearer <token>" header
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/paulvasilenko/discordbot/discordbot/audit"
+	"github.com/paulvasilenko/discordbot/discordbot/guildsettings"
+	"github.com/paulvasilenko/discordbot/discordbot/plugin"
+	"github.com/paulvasilenko/discordbot/discordbot/racing"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Prefix is path where API is mounted
+const Prefix = "/api/"
+
+// Lobby returns racers joined to next race
+type Lobby interface {
+	Racers() ([]*racing.Racer, error)
+}
+
+// Blacklist manages smileys which are not counted by SmileyStats
+type Blacklist interface {
+	Blacklist() map[string]string
+	SetBlacklist(blacklist map[string]string)
+}
+
+// AuditLog returns entries of command audit log
+type AuditLog interface {
+	Query(f audit.Filter) ([]*audit.Entry, error)
+}
+
+// API is admin HTTP API of the bot
+type API struct {
+	token   string
+	started time.Time
+
+	session   *discordgo.Session
+	registry  *plugin.Registry
+	settings  *guildsettings.Manager
+	lobby     Lobby
+	blacklist Blacklist
+	auditLog  AuditLog
+
+	mux *http.ServeMux
+}
+
+// Status is bot status returned by /api/status
+type Status struct {
+	Uptime         string  `json:"uptime"`
+	UptimeSeconds  float64 `json:"uptimeSeconds"`
+	Guilds         int     `json:"guilds"`
+	GatewayLatency float64 `json:"gatewayLatencyMs"`
+}
+
+// Plugin is registered plugin returned by /api/plugins
+type Plugin struct {
+	Name     string   `json:"name"`
+	Commands []string `json:"commands"`
+	Enabled  bool     `json:"enabled"`
+}
+
+// Racer is racer in lobby returned by /api/racing/lobby
+type Racer struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+// Message is message sent by /api/messages
+type Message struct {
+	ChannelID string `json:"channelId"`
+	Content   string `json:"content"`
+	ID        string `json:"id,omitempty"`
+}
+
+// AuditEntry is command call returned by /api/audit
+type AuditEntry struct {
+	ID         int64     `json:"id"`
+	Time       time.Time `json:"time"`
+	GuildID    string    `json:"guildId"`
+	ChannelID  string    `json:"channelId"`
+	UserID     string    `json:"userId"`
+	UserName   string    `json:"userName"`
+	Command    string    `json:"command"`
+	Args       string    `json:"args"`
+	DurationMs int64     `json:"durationMs"`
+	Outcome    string    `json:"outcome"`
+	Error      string    `json:"error,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// NewAPI is a constructor function for admin API, lobby, blacklist and audit log are optional
+func NewAPI(
+	token string,
+	s *discordgo.Session,
+	registry *plugin.Registry,
+	settings *guildsettings.Manager,
+	lobby Lobby,
+	blacklist Blacklist,
+	auditLog AuditLog,
+) *API {
+	a := &API{
+		token:     token,
+		started:   time.Now(),
+		session:   s,
+		registry:  registry,
+		settings:  settings,
+		lobby:     lobby,
+		blacklist: blacklist,
+		auditLog:  auditLog,
+		mux:       http.NewServeMux(),
+	}
+
+	a.mux.HandleFunc(Prefix+"status", a.status)
+	a.mux.HandleFunc(Prefix+"plugins", a.plugins)
+	a.mux.HandleFunc(Prefix+"plugins/", a.plugin)
+	a.mux.HandleFunc(Prefix+"racing/lobby", a.racingLobby)
+	a.mux.HandleFunc(Prefix+"smileystats/blacklist", a.smileyBlacklist)
+	a.mux.HandleFunc(Prefix+"messages", a.messages)
+	a.mux.HandleFunc(Prefix+"audit", a.audit)
+
+	return a
+}
+
+// ServeHTTP checks token and serves API request
+func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if a.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+		writeError(w, http.StatusUnauthorized, errors.New("invalid token"))
+		return
+	}
+
+	a.mux.ServeHTTP(w, r)
+}
+
+// status handles GET /api/status
+func (a *API) status(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	uptime := time.Since(a.started)
+	status := Status{
+		Uptime:         uptime.Round(time.Second).String(),
+		UptimeSeconds:  uptime.Seconds(),
+		GatewayLatency: float64(a.session.HeartbeatLatency()) / float64(time.Millisecond),
+	}
+	if a.session.State != nil {
+		a.session.State.RLock()
+		status.Guilds = len(a.session.State.Guilds)
+		a.session.State.RUnlock()
+	}
+
+	writeJSON(w, http.StatusOK, status)
+}
+
+// plugins handles GET /api/plugins?guild=<id>, plugins are enabled when guild is not passed
+func (a *API) plugins(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	guildID := r.URL.Query().Get("guild")
+	settings := a.settings.Get(guildID)
+
+	plugins := []Plugin{}
+	for _, p := range a.registry.Plugins() {
+		plugins = append(plugins, Plugin{
+			Name:     p.Name(),
+			Commands: plugin.Commands(p),
+			Enabled:  !settings.Plugin(p.Name()).Disabled,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, plugins)
+}
+
+// plugin handles PUT /api/plugins/<name>?guild=<id> with {"enabled": true} body
+func (a *API) plugin(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPut) {
+		return
+	}
+
+	p, ok := a.registry.Get(strings.TrimPrefix(r.URL.Path, Prefix+"plugins/"))
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("plugin not found"))
+		return
+	}
+
+	guildID := r.URL.Query().Get("guild")
+	if guildID == "" {
+		writeError(w, http.StatusBadRequest, errors.New("guild is required"))
+		return
+	}
+
+	var body struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, errors.Wrap(err, "invalid body"))
+		return
+	}
+
+	if err := a.settings.SetEnabled(guildID, p.Name(), body.Enabled); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Plugin{Name: p.Name(), Commands: plugin.Commands(p), Enabled: body.Enabled})
+}
+
+// racingLobby handles GET /api/racing/lobby
+func (a *API) racingLobby(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	if a.lobby == nil {
+		writeError(w, http.StatusNotFound, errors.New("racing is not running"))
+		return
+	}
+
+	racers, err := a.lobby.Racers()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	lobby := []Racer{}
+	for _, racer := range racers {
+		lobby = append(lobby, Racer{ID: racer.ID, Username: racer.Username})
+	}
+
+	writeJSON(w, http.StatusOK, lobby)
+}
+
+// smileyBlacklist handles GET and PUT /api/smileystats/blacklist,
+// blacklist is map of smiley names to ids, it is replaced by config on reload
+func (a *API) smileyBlacklist(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet, http.MethodPut) {
+		return
+	}
+	if a.blacklist == nil {
+		writeError(w, http.StatusNotFound, errors.New("smileystats is not running"))
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		blacklist := map[string]string{}
+		if err := json.NewDecoder(r.Body).Decode(&blacklist); err != nil {
+			writeError(w, http.StatusBadRequest, errors.Wrap(err, "invalid body"))
+			return
+		}
+
+		a.blacklist.SetBlacklist(blacklist)
+		log.Printf("SmileyStats blacklist is replaced by admin api, %d smileys", len(blacklist))
+	}
+
+	writeJSON(w, http.StatusOK, a.blacklist.Blacklist())
+}
+
+// messages handles POST /api/messages with {"channelId": "...", "content": "..."} body
+func (a *API) messages(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var message Message
+	if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+		writeError(w, http.StatusBadRequest, errors.Wrap(err, "invalid body"))
+		return
+	}
+	if message.ChannelID == "" || message.Content == "" {
+		writeError(w, http.StatusBadRequest, errors.New("channelId and content are required"))
+		return
+	}
+
+	sent, err := a.session.ChannelMessageSend(message.ChannelID, message.Content)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, errors.Wrap(err, "failed to send message"))
+		return
+	}
+	message.ID = sent.ID
+
+	writeJSON(w, http.StatusCreated, message)
+}
+
+// audit handles GET /api/audit?guild=<id>&user=<id>&command=<name>&since=<RFC3339 time>&limit=<n>&format=csv,
+// entries are returned newest first as json or csv
+func (a *API) audit(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	if a.auditLog == nil {
+		writeError(w, http.StatusNotFound, errors.New("audit log is not running"))
+		return
+	}
+
+	q := r.URL.Query()
+	f := audit.Filter{
+		GuildID: q.Get("guild"),
+		UserID:  q.Get("user"),
+		Command: strings.ToLower(q.Get("command")),
+	}
+	if since := q.Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errors.New("since must be RFC3339 time"))
+			return
+		}
+		f.Since = t
+	}
+	if limit := q.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, errors.New("limit must be positive number"))
+			return
+		}
+		f.Limit = n
+	}
+
+	entries, err := a.auditLog.Query(f)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	result := []AuditEntry{}
+	for _, e := range entries {
+		result = append(result, AuditEntry{
+			ID:         e.ID,
+			Time:       e.Time.UTC(),
+			GuildID:    e.GuildID,
+			ChannelID:  e.ChannelID,
+			UserID:     e.UserID,
+			UserName:   e.UserName,
+			Command:    e.Command,
+			Args:       e.Args,
+			DurationMs: e.Duration.Milliseconds(),
+			Outcome:    e.Outcome,
+			Error:      e.Error,
+		})
+	}
+
+	if q.Get("format") == "csv" {
+		writeAuditCSV(w, result)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func writeAuditCSV(w http.ResponseWriter, entries []AuditEntry) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit.csv"`)
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "time", "guildId", "channelId", "userId", "userName", "command", "args", "durationMs", "outcome", "error"})
+	for _, e := range entries {
+		cw.Write([]string{
+			strconv.FormatInt(e.ID, 10), e.Time.Format(time.RFC3339), e.GuildID, e.ChannelID, e.UserID, e.UserName,
+			e.Command, e.Args, strconv.FormatInt(e.DurationMs, 10), e.Outcome, e.Error,
+		})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		log.Println("failed to write admin api response: ", err)
+	}
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, m := range methods {
+		if r.Method == m {
+			return true
+		}
+	}
+
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, http.StatusMethodNotAllowed, errors.Errorf("method %s is not allowed", r.Method))
+
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("failed to write admin api response: ", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, errorResponse{Error: err.Error()})
+}
@@ -0,0 +1,206 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/paulvasilenko/discordbot/discordbot/audit"
+	"github.com/paulvasilenko/discordbot/discordbot/guildsettings"
+	"github.com/paulvasilenko/discordbot/discordbot/plugin"
+	"github.com/paulvasilenko/discordbot/discordbot/racing"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+)
+
+type testPlugin struct{}
+
+func (testPlugin) Name() string                    { return "Racing" }
+func (testPlugin) GetInfo() map[string]string      { return map[string]string{"!rjoin": "Joins to race"} }
+func (testPlugin) Handlers() []interface{}         { return nil }
+func (testPlugin) Init(s *discordgo.Session) error { return nil }
+func (testPlugin) Close() error                    { return nil }
+
+type memoryStore map[string]*guildsettings.Settings
+
+func (m memoryStore) Load(guildID string) (*guildsettings.Settings, error) { return m[guildID], nil }
+func (m memoryStore) Save(s *guildsettings.Settings) error                 { m[s.GuildID] = s; return nil }
+
+type lobby []*racing.Racer
+
+func (l lobby) Racers() ([]*racing.Racer, error) { return l, nil }
+
+type blacklist map[string]string
+
+func (b blacklist) Blacklist() map[string]string { return b }
+func (b blacklist) SetBlacklist(bl map[string]string) {
+	for k := range b {
+		delete(b, k)
+	}
+	for k, v := range bl {
+		b[k] = v
+	}
+}
+
+type auditLog []*audit.Entry
+
+func (l auditLog) Query(f audit.Filter) ([]*audit.Entry, error) {
+	var entries []*audit.Entry
+	for _, e := range l {
+		if f.UserID == "" || e.UserID == f.UserID {
+			entries = append(entries, e)
+		}
+	}
+
+	return entries, nil
+}
+
+func Test_API(t *testing.T) {
+	registry := plugin.NewRegistry(router.New("!"))
+	if err := registry.Register(testPlugin{}); err != nil {
+		t.Fatal(err)
+	}
+
+	api := NewAPI(
+		"secret",
+		&discordgo.Session{},
+		registry,
+		guildsettings.NewManager(memoryStore{}, "!"),
+		lobby{{ID: "10", Username: "panda", Emoticon: racing.RacerEmoji}},
+		blacklist{"kappa": "1"},
+		auditLog{
+			{ID: 2, Time: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC), UserID: "10", Command: "rreset", Duration: 20 * time.Millisecond, Outcome: "ok"},
+			{ID: 1, Time: time.Date(2026, 10, 18, 11, 0, 0, 0, time.UTC), UserID: "20", Command: "sdr", Args: "<@10>", Outcome: "usage"},
+		},
+	)
+
+	testCases := []struct {
+		name     string
+		method   string
+		path     string
+		token    string
+		body     string
+		code     int
+		expected string
+	}{
+		{
+			name:     "without token",
+			method:   http.MethodGet,
+			path:     "/api/plugins",
+			code:     http.StatusUnauthorized,
+			expected: `{"error":"invalid token"}`,
+		},
+		{
+			name:     "plugins",
+			method:   http.MethodGet,
+			path:     "/api/plugins",
+			token:    "secret",
+			code:     http.StatusOK,
+			expected: `[{"name":"Racing","commands":["!rjoin"],"enabled":true}]`,
+		},
+		{
+			name:     "disable plugin",
+			method:   http.MethodPut,
+			path:     "/api/plugins/racing?guild=1",
+			token:    "secret",
+			body:     `{"enabled":false}`,
+			code:     http.StatusOK,
+			expected: `{"name":"Racing","commands":["!rjoin"],"enabled":false}`,
+		},
+		{
+			name:     "plugins of guild",
+			method:   http.MethodGet,
+			path:     "/api/plugins?guild=1",
+			token:    "secret",
+			code:     http.StatusOK,
+			expected: `[{"name":"Racing","commands":["!rjoin"],"enabled":false}]`,
+		},
+		{
+			name:     "unknown plugin",
+			method:   http.MethodPut,
+			path:     "/api/plugins/quoter?guild=1",
+			token:    "secret",
+			code:     http.StatusNotFound,
+			expected: `{"error":"plugin not found"}`,
+		},
+		{
+			name:     "lobby",
+			method:   http.MethodGet,
+			path:     "/api/racing/lobby",
+			token:    "secret",
+			code:     http.StatusOK,
+			expected: `[{"id":"10","username":"panda"}]`,
+		},
+		{
+			name:     "replace blacklist",
+			method:   http.MethodPut,
+			path:     "/api/smileystats/blacklist",
+			token:    "secret",
+			body:     `{"wright":"2"}`,
+			code:     http.StatusOK,
+			expected: `{"wright":"2"}`,
+		},
+		{
+			name:     "wrong method",
+			method:   http.MethodDelete,
+			path:     "/api/smileystats/blacklist",
+			token:    "secret",
+			code:     http.StatusMethodNotAllowed,
+			expected: `{"error":"method DELETE is not allowed"}`,
+		},
+		{
+			name:     "audit of user",
+			method:   http.MethodGet,
+			path:     "/api/audit?user=10",
+			token:    "secret",
+			code:     http.StatusOK,
+			expected: `[{"id":2,"time":"2026-10-18T12:00:00Z","guildId":"","channelId":"","userId":"10","userName":"","command":"rreset","args":"","durationMs":20,"outcome":"ok"}]`,
+		},
+		{
+			name:     "audit with invalid time",
+			method:   http.MethodGet,
+			path:     "/api/audit?since=yesterday",
+			token:    "secret",
+			code:     http.StatusBadRequest,
+			expected: `{"error":"since must be RFC3339 time"}`,
+		},
+		{
+			name:     "message without content",
+			method:   http.MethodPost,
+			path:     "/api/messages",
+			token:    "secret",
+			body:     `{"channelId":"1"}`,
+			code:     http.StatusBadRequest,
+			expected: `{"error":"channelId and content are required"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			if tc.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			w := httptest.NewRecorder()
+
+			api.ServeHTTP(w, r)
+
+			if w.Code != tc.code {
+				t.Errorf("expected code %d, got %d", tc.code, w.Code)
+			}
+
+			var got, expected interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("invalid response %q: %v", w.Body.String(), err)
+			}
+			json.Unmarshal([]byte(tc.expected), &expected)
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("expected %s, got %s", tc.expected, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+// Package audit records commands called through router and provides plugin to query them
+package audit
+
+import (
+	"strings"
+	"time"
+
+	"github.com/paulvasilenko/discordbot/discordbot/metrics"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultLimit is number of entries returned by query without limit
+	DefaultLimit = 100
+	// MaxLimit is maximal number of entries returned by query
+	MaxLimit = 10000
+
+	// maxArgsLength keeps long arguments, e.g. text of !speak, from bloating the log
+	maxArgsLength  = 255
+	maxErrorLength = 255
+)
+
+// Entry is a single command call
+type Entry struct {
+	ID        int64
+	Time      time.Time
+	GuildID   string
+	ChannelID string
+	UserID    string
+	UserName  string
+	// Command is lowercase name of command without prefix
+	Command  string
+	Args     string
+	Duration time.Duration
+	// Outcome is ok, error, denied, usage or not_allowed
+	Outcome string
+	Error   string
+}
+
+// Filter selects entries, empty fields match any value
+type Filter struct {
+	GuildID string
+	UserID  string
+	Command string
+	Since   time.Time
+	// Limit is DefaultLimit if it's not set, it can't exceed MaxLimit
+	Limit int
+}
+
+func (f Filter) limit() int {
+	switch {
+	case f.Limit <= 0:
+		return DefaultLimit
+	case f.Limit > MaxLimit:
+		return MaxLimit
+	default:
+		return f.Limit
+	}
+}
+
+// Log records every command passed through its middleware and deletes entries older than retention
+type Log struct {
+	store     Store
+	retention time.Duration
+	now       func() time.Time
+}
+
+// NewLog is a constructor function for audit log, entries are kept forever if retention is 0
+func NewLog(store Store, retention time.Duration) *Log {
+	return &Log{store: store, retention: retention, now: time.Now}
+}
+
+// Middleware is router middleware which records command call with its duration and outcome.
+// Failed writes are logged, they don't fail command
+func (l *Log) Middleware(next router.HandlerFunc) router.HandlerFunc {
+	return func(ctx *router.Context) error {
+		start := l.now()
+		err := next(ctx)
+
+		m := ctx.Message
+		e := &Entry{
+			Time:      start,
+			GuildID:   m.GuildID,
+			ChannelID: m.ChannelID,
+			Command:   strings.ToLower(ctx.Command.Name),
+			Args:      truncate(ctx.RawArgs, maxArgsLength),
+			Duration:  l.now().Sub(start),
+			Outcome:   metrics.Status(err),
+		}
+		if m.Author != nil {
+			e.UserID, e.UserName = m.Author.ID, m.Author.Username
+		}
+		if err != nil {
+			e.Error = truncate(err.Error(), maxErrorLength)
+		}
+		if addErr := l.store.Add(e); addErr != nil {
+			log.Println("failed to write audit log: ", addErr)
+		}
+
+		return err
+	}
+}
+
+// Query returns entries matching filter, newest first
+func (l *Log) Query(f Filter) ([]*Entry, error) {
+	return l.store.Query(f)
+}
+
+// Purge deletes entries older than retention
+func (l *Log) Purge() (int64, error) {
+	if l.retention <= 0 {
+		return 0, nil
+	}
+
+	return l.store.DeleteBefore(l.now().Add(-l.retention))
+}
+
+func truncate(s string, length int) string {
+	if runes := []rune(s); len(runes) > length {
+		return string(runes[:length-1]) + "…"
+	}
+
+	return s
+}
@@ -0,0 +1,134 @@
+package audit
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/paulvasilenko/discordbot/discordbot/discord/discordtest"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+	"github.com/paulvasilenko/discordbot/discordbot/storage"
+	"github.com/paulvasilenko/discordbot/migrations"
+)
+
+func newSQLiteStore(t *testing.T) Store {
+	db, err := storage.Open(storage.SQLite, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	runner, err := migrations.NewRunner(db.DB, db.Driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runner.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	return NewSQLStore(db)
+}
+
+func Test_Log(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.Local)
+	l := NewLog(newSQLiteStore(t), 24*time.Hour)
+	l.now = func() time.Time { return now }
+
+	rt := router.New("!")
+	rt.Use(l.Middleware)
+	if err := rt.Add("Audit", NewAudit(l, rt).Commands()...); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.Add("Racing", &router.Command{
+		Name: "rreset",
+		Args: []router.Arg{{Name: "force", Type: router.ArgString, Optional: true}},
+		Handler: func(ctx *router.Context) error {
+			if ctx.Args.Has("force") {
+				return errors.New("lobby is locked")
+			}
+			return nil
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.Add("TTS", &router.Command{
+		Name:    "Speak",
+		Aliases: []string{"say"},
+		Args:    []router.Arg{{Name: "text", Type: router.ArgString, Optional: true}},
+		Handler: func(ctx *router.Context) error { return nil },
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := discordtest.NewSession()
+	calls := []struct {
+		userID  string
+		message string
+		time    time.Time
+	}{
+		{userID: "10", message: "!rreset", time: now.Add(-48 * time.Hour)},
+		{userID: "10", message: "!rreset force", time: now.Add(-time.Hour)},
+		{userID: "20", message: "!rreset", time: now.Add(-time.Minute)},
+		{userID: "20", message: "!SAY hello", time: now.Add(-30 * time.Minute)},
+	}
+	for _, c := range calls {
+		now = c.time
+		rt.HandleMessage(s, discordtest.Message("general", c.userID, c.message))
+	}
+	now = time.Date(2026, 10, 18, 12, 0, 0, 0, time.Local)
+
+	if n, err := l.Purge(); err != nil || n != 1 {
+		t.Errorf("expected 1 entry to be purged, got %d, %v", n, err)
+	}
+
+	// Test cases share log, so they depend on previous ones
+	testCases := []struct {
+		message  string
+		expected []string
+	}{
+		{message: "!audit <@10>", expected: []string{"Last commands:\n" +
+			"2026-10-18 11:00 <@10> `!rreset force` error in 0s"}},
+		{message: "!audit", expected: []string{"Last commands:\n" +
+			"2026-10-18 12:00 <@30> `!audit <@10>` ok in 0s\n" +
+			"2026-10-18 11:59 <@20> `!rreset` ok in 0s\n" +
+			"2026-10-18 11:30 <@20> `!speak hello` ok in 0s\n" +
+			"2026-10-18 11:00 <@10> `!rreset force` error in 0s"}},
+		{message: "!audit <@20> !rreset", expected: []string{"Last commands:\n" +
+			"2026-10-18 11:59 <@20> `!rreset` ok in 0s"}},
+		// Command is matched by any case of its name or alias
+		{message: "!audit speak", expected: []string{"Last commands:\n" +
+			"2026-10-18 11:30 <@20> `!speak hello` ok in 0s"}},
+		{message: "!audit !Say", expected: []string{"Last commands:\n" +
+			"2026-10-18 11:30 <@20> `!speak hello` ok in 0s"}},
+		{message: "!audit rjoin", expected: []string{"Audit log is empty"}},
+	}
+
+	for _, tc := range testCases {
+		s.Reset()
+		rt.HandleMessage(s, discordtest.Message("general", "30", tc.message))
+		if actual := s.Contents(); !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("%s: expected %q, actual %q", tc.message, tc.expected, actual)
+		}
+	}
+
+	entries, err := l.Query(Filter{UserID: "10"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*Entry{{
+		ID:        2,
+		Time:      time.Date(2026, 10, 18, 11, 0, 0, 0, time.Local),
+		GuildID:   discordtest.GuildID,
+		ChannelID: "general",
+		UserID:    "10",
+		UserName:  "10",
+		Command:   "rreset",
+		Args:      "force",
+		Outcome:   "error",
+		Error:     "lobby is locked",
+	}}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected %+v, actual %+v", expected[0], entries[0])
+	}
+}
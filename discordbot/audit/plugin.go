@@ -0,0 +1,159 @@
+package audit
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/paulvasilenko/discordbot/discordbot/discord"
+	"github.com/paulvasilenko/discordbot/discordbot/response"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+	"github.com/paulvasilenko/discordbot/discordbot/scheduler"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	CommandAudit = "audit"
+
+	// JobRetention is handler of bot wide job which deletes old entries
+	JobRetention = "audit.retention"
+
+	// commandLimit is number of entries printed by !audit
+	commandLimit = 15
+	timeLayout   = "2006-01-02 15:04"
+)
+
+// Audit is a plugin which prints audit log of server to admins and purges old entries daily
+type Audit struct {
+	log       *Log
+	router    *router.Router
+	scheduler *scheduler.Scheduler
+}
+
+// NewAudit is a constructor function for audit plugin, router resolves aliases of commands in filter
+func NewAudit(l *Log, rt *router.Router) *Audit {
+	return &Audit{log: l, router: rt}
+}
+
+func (a *Audit) Name() string {
+	return "Audit"
+}
+
+// GetInfo returns map of info message
+func (a *Audit) GetInfo() map[string]string {
+	return map[string]string{
+		"!audit [@user] [command]": "Prints last commands called on this server, optionally by user or only given command",
+	}
+}
+
+func (a *Audit) Handlers() []interface{} {
+	return nil
+}
+
+// SetScheduler sets scheduler of retention job
+func (a *Audit) SetScheduler(s *scheduler.Scheduler) {
+	a.scheduler = s
+}
+
+// Init registers retention job, it's scheduled once and kept in database
+func (a *Audit) Init(s *discordgo.Session) error {
+	if a.scheduler == nil || a.log.retention <= 0 {
+		return nil
+	}
+	a.scheduler.Handle(JobRetention, a.purge)
+
+	jobs, err := a.scheduler.Jobs("")
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if job.Handler == JobRetention {
+			return nil
+		}
+	}
+
+	return a.scheduler.Schedule(&scheduler.Job{
+		Handler:     JobRetention,
+		Description: "Audit log retention",
+		Schedule:    "@daily",
+	})
+}
+
+func (a *Audit) Close() error {
+	return nil
+}
+
+// Commands returns commands of plugin handled by router
+func (a *Audit) Commands() []*router.Command {
+	return []*router.Command{
+		{
+			Name:        CommandAudit,
+			Description: "Prints last commands called on this server",
+			Args: []router.Arg{
+				{Name: "user", Type: router.ArgUser, Optional: true},
+				{Name: "command", Type: router.ArgString, Optional: true},
+			},
+			Handler:    a.audit,
+			Permission: discordgo.PermissionViewAuditLogs,
+		},
+	}
+}
+
+func (a *Audit) audit(ctx *router.Context) error {
+	m := ctx.Message
+	if m.GuildID == "" {
+		_, err := ctx.Reply("Audit log is available only on servers")
+		return err
+	}
+
+	// Commands are recorded by lowercase name, alias is resolved to its command
+	command := strings.TrimPrefix(ctx.Args.String("command"), ctx.Prefix)
+	if c, ok := a.router.Command(command); ok {
+		command = c.Name
+	}
+	f := Filter{
+		GuildID: m.GuildID,
+		Command: strings.ToLower(command),
+		Limit:   commandLimit,
+	}
+	if user := ctx.Args.User("user"); user != nil {
+		f.UserID = user.ID
+	}
+
+	entries, err := a.log.Query(f)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		_, err := ctx.Reply("Audit log is empty")
+		return err
+	}
+
+	lines := []string{"Last commands:"}
+	for _, e := range entries {
+		call := ctx.Prefix + e.Command
+		if e.Args != "" {
+			call += " " + e.Args
+		}
+		lines = append(lines, fmt.Sprintf("%s <@%s> `%s` %s in %s",
+			e.Time.In(time.Local).Format(timeLayout), e.UserID, strings.ReplaceAll(call, "`", "'"), e.Outcome, e.Duration))
+	}
+
+	return response.Reply(ctx, &response.Response{
+		Text:            strings.Join(lines, "\n"),
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+}
+
+func (a *Audit) purge(s discord.Session, job *scheduler.Job) error {
+	n, err := a.log.Purge()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		log.Printf("Audit log retention deleted %d entries", n)
+	}
+
+	return nil
+}
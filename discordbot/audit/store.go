@@ -0,0 +1,110 @@
+package audit
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/paulvasilenko/discordbot/discordbot/metrics"
+	"github.com/paulvasilenko/discordbot/discordbot/storage"
+)
+
+// Store keeps audit log entries
+type Store interface {
+	// Add saves entry and sets its ID
+	Add(e *Entry) error
+	// Query returns entries matching filter, newest first
+	Query(f Filter) ([]*Entry, error)
+	// DeleteBefore deletes entries older than t and returns their number
+	DeleteBefore(t time.Time) (int64, error)
+}
+
+// SQLStore stores entries in commandAudit table, time of call is unix time,
+// its queries are the same for MySQL and SQLite
+type SQLStore struct {
+	db *sql.DB
+}
+
+// NewSQLStore returns store working with given connection
+func NewSQLStore(db *storage.DB) *SQLStore {
+	return &SQLStore{db: db.DB}
+}
+
+func (st *SQLStore) Add(e *Entry) error {
+	res, err := st.db.Exec(
+		`INSERT INTO commandAudit (callTime, guildId, channelId, userId, userName, command, args, durationMs, outcome, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.Time.Unix(), e.GuildID, e.ChannelID, e.UserID, e.UserName, e.Command, e.Args,
+		e.Duration.Milliseconds(), e.Outcome, e.Error,
+	)
+	if err != nil {
+		return metrics.DBError("audit", "add", err)
+	}
+
+	e.ID, err = res.LastInsertId()
+
+	return metrics.DBError("audit", "add", err)
+}
+
+func (st *SQLStore) Query(f Filter) ([]*Entry, error) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+	add := func(condition string, arg interface{}) {
+		conditions = append(conditions, condition)
+		args = append(args, arg)
+	}
+
+	if f.GuildID != "" {
+		add("guildId = ?", f.GuildID)
+	}
+	if f.UserID != "" {
+		add("userId = ?", f.UserID)
+	}
+	if f.Command != "" {
+		add("command = ?", f.Command)
+	}
+	if !f.Since.IsZero() {
+		add("callTime >= ?", f.Since.Unix())
+	}
+
+	query := `SELECT id, callTime, guildId, channelId, userId, userName, command, args, durationMs, outcome, error FROM commandAudit`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += ` ORDER BY callTime DESC, id DESC LIMIT ?`
+	args = append(args, f.limit())
+
+	rows, err := st.db.Query(query, args...)
+	if err != nil {
+		return nil, metrics.DBError("audit", "query", err)
+	}
+	defer rows.Close()
+
+	var entries []*Entry
+	for rows.Next() {
+		var (
+			e                  Entry
+			callTime, duration int64
+		)
+		if err := rows.Scan(&e.ID, &callTime, &e.GuildID, &e.ChannelID, &e.UserID, &e.UserName,
+			&e.Command, &e.Args, &duration, &e.Outcome, &e.Error); err != nil {
+			return nil, metrics.DBError("audit", "query", err)
+		}
+		e.Time = time.Unix(callTime, 0)
+		e.Duration = time.Duration(duration) * time.Millisecond
+		entries = append(entries, &e)
+	}
+
+	return entries, metrics.DBError("audit", "query", rows.Err())
+}
+
+func (st *SQLStore) DeleteBefore(t time.Time) (int64, error) {
+	res, err := st.db.Exec(`DELETE FROM commandAudit WHERE callTime < ?`, t.Unix())
+	if err != nil {
+		return 0, metrics.DBError("audit", "delete", err)
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,327 @@
+package confify
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// DefaultMaxFrames is number of frames in processed animation, longer ones lose frames evenly
+	DefaultMaxFrames = 100
+	// DefaultMaxPixels is width × height × number of frames of animation which is decoded,
+	// larger ones are rejected, so they don't exhaust memory
+	DefaultMaxPixels = 64 << 20
+
+	// detectEvery is number of frames faces are tracked between detections
+	detectEvery = 5
+	// trackThreshold is intersection over union of detections treated as the same face
+	trackThreshold = 0.3
+	// maxResizeAttempts is number of times animation is scaled down to fit into size budget
+	maxResizeAttempts = 3
+
+	typeGIF  = "image/gif"
+	typeWebP = "image/webp"
+)
+
+// ErrAnimationTooLarge is returned when animation exceeds pixel budget
+var ErrAnimationTooLarge = errors.New("animation is too large")
+
+// animated is implemented by backends which process every frame of animated GIF
+type animated interface {
+	Animated() bool
+}
+
+// frame is a frame of animation composed over previous ones
+type frame struct {
+	image   *image.RGBA
+	palette color.Palette
+	// delay is in 100ths of second
+	delay int
+}
+
+// track is a face followed across frames, it keeps the same pasted face
+type track struct {
+	detection Detection
+	face      image.Image
+}
+
+// Animated returns true, every frame of GIF is processed and faces are tracked between frames
+func (cs *CascadeSwapper) Animated() bool {
+	return true
+}
+
+// swapAnimation pastes faces on frames of GIF. Faces are detected on every few frames
+// and matched with previous detections, so they keep their images
+func (cs *CascadeSwapper) swapAnimation(ctx context.Context, input, output string, faces []image.Image) error {
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return errors.Wrap(err, "failed to read image")
+	}
+
+	// Size is checked before frames are decoded
+	config, err := gif.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return errors.Wrap(err, "failed to decode gif")
+	}
+	n := countFrames(data)
+	if pixels := int64(config.Width) * int64(config.Height) * int64(n); cs.MaxPixels > 0 && pixels > cs.MaxPixels {
+		return errors.Wrapf(ErrAnimationTooLarge, "%dx%d animation of %d frames", config.Width, config.Height, n)
+	}
+
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return errors.Wrap(err, "failed to decode gif")
+	}
+
+	frames := composeFrames(g, cs.MaxFrames)
+
+	var tracks []track
+	for i, fr := range frames {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if i%detectEvery == 0 {
+			tracks = cs.updateTracks(tracks, cs.cascade.Detect(fr.image), faces)
+		}
+		for _, t := range tracks {
+			pasteFace(fr.image, t.face, t.detection)
+		}
+	}
+
+	return encodeAnimation(output, frames, g.LoopCount, cs.MaxBytes)
+}
+
+// updateTracks matches detections with tracks of previous frames, new faces get random images
+func (cs *CascadeSwapper) updateTracks(tracks []track, detections []Detection, faces []image.Image) []track {
+	var updated []track
+	for _, d := range detections {
+		if d.Quality < cs.MinQuality {
+			continue
+		}
+
+		t := track{detection: d}
+		best := trackThreshold
+		for _, previous := range tracks {
+			if overlap := iou(previous.detection, d); overlap > best {
+				best, t.face = overlap, previous.face
+			}
+		}
+		if t.face == nil {
+			t.face = cs.pick(faces)
+		}
+		updated = append(updated, t)
+	}
+
+	return updated
+}
+
+// composeFrames renders every frame of GIF over previous ones according to their disposal.
+// Frames are dropped evenly, so no more than max of them are kept, delays of dropped frames
+// are added to kept ones, so duration of animation doesn't change
+func composeFrames(g *gif.GIF, max int) []*frame {
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if bounds.Empty() && len(g.Image) > 0 {
+		bounds = g.Image[0].Bounds()
+	}
+	canvas := image.NewRGBA(bounds)
+
+	step := 1
+	if max > 0 && len(g.Image) > max {
+		step = (len(g.Image) + max - 1) / max
+	}
+
+	frames := make([]*frame, 0, (len(g.Image)+step-1)/step)
+	for i, img := range g.Image {
+		var disposal byte
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+
+		var previous *image.RGBA
+		if disposal == gif.DisposalPrevious {
+			previous = clone(canvas)
+		}
+
+		draw.Draw(canvas, img.Bounds(), img, img.Bounds().Min, draw.Over)
+		var delay int
+		if i < len(g.Delay) {
+			delay = g.Delay[i]
+		}
+		// Only kept frames are copied
+		if i%step == 0 {
+			frames = append(frames, &frame{image: clone(canvas), palette: img.Palette, delay: delay})
+		} else {
+			frames[len(frames)-1].delay += delay
+		}
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, img.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+
+	return frames
+}
+
+// countFrames counts image descriptors of GIF without decoding them, truncated data ends counting
+func countFrames(data []byte) int {
+	const (
+		extension       = 0x21
+		imageDescriptor = 0x2c
+		trailer         = 0x3b
+	)
+
+	if len(data) < 13 {
+		return 0
+	}
+	// Header and logical screen descriptor are followed by optional global color table
+	pos := 13
+	if data[10]&0x80 != 0 {
+		pos += 3 << (data[10]&7 + 1)
+	}
+
+	var n int
+	for pos < len(data) {
+		switch data[pos] {
+		case extension:
+			// Introducer and label
+			pos += 2
+		case imageDescriptor:
+			if pos+10 > len(data) {
+				return n
+			}
+			flags := data[pos+9]
+			pos += 10
+			if flags&0x80 != 0 {
+				pos += 3 << (flags&7 + 1)
+			}
+			// Minimal code size of LZW
+			pos++
+			n++
+		default:
+			return n
+		}
+
+		// Data sub-blocks end with empty block
+		for pos < len(data) {
+			size := int(data[pos])
+			pos += size + 1
+			if size == 0 {
+				break
+			}
+		}
+	}
+
+	return n
+}
+
+// encodeAnimation writes frames as GIF with their own palettes. When file exceeds maxBytes,
+// frames are scaled down and encoded again
+func encodeAnimation(path string, frames []*frame, loopCount, maxBytes int) error {
+	scale := 1.0
+	for attempt := 0; attempt <= maxResizeAttempts; attempt++ {
+		g := &gif.GIF{LoopCount: loopCount}
+		for _, fr := range frames {
+			img := fr.image
+			if scale < 1 {
+				b := img.Bounds()
+				img = resize(img, int(math.Max(1, float64(b.Dx())*scale)), int(math.Max(1, float64(b.Dy())*scale)))
+			}
+
+			p := image.NewPaletted(img.Bounds(), fr.palette)
+			draw.FloydSteinberg.Draw(p, p.Bounds(), img, img.Bounds().Min)
+			g.Image = append(g.Image, p)
+			g.Delay = append(g.Delay, fr.delay)
+		}
+
+		var buf bytes.Buffer
+		if err := gif.EncodeAll(&buf, g); err != nil {
+			return errors.Wrap(err, "failed to encode gif")
+		}
+		if maxBytes <= 0 || buf.Len() <= maxBytes {
+			return errors.Wrap(os.WriteFile(path, buf.Bytes(), 0644), "failed to save processed image")
+		}
+
+		// Size of GIF is roughly proportional to area of frames
+		scale *= math.Sqrt(float64(maxBytes)/float64(buf.Len())) * 0.9
+	}
+
+	return errors.Errorf("processed animation doesn't fit into %d bytes", maxBytes)
+}
+
+// prepareInput converts image into format backend can process: WebP is converted to PNG,
+// GIF is converted to PNG of its first frame unless backend processes animations. Converted images
+// above maxPixels are rejected. It returns path of image and extension of processed file
+func prepareInput(path string, swapper FaceSwapper, maxPixels int64) (string, string, error) {
+	typ, err := contentType(path)
+	if err != nil {
+		return "", "", err
+	}
+
+	switch typ {
+	case typeGIF:
+		if a, ok := swapper.(animated); ok && a.Animated() {
+			return path, ".gif", nil
+		}
+	case typeWebP:
+	default:
+		// Backend decodes image itself, so its size is checked here
+		f, err := os.Open(path)
+		if err != nil {
+			return "", "", errors.Wrap(err, "failed to open image")
+		}
+		defer f.Close()
+		if err := checkPixels(f, filepath.Base(path), maxPixels); err != nil {
+			return "", "", err
+		}
+
+		return path, "." + strings.TrimPrefix(typ, "image/"), nil
+	}
+
+	img, err := decodeFile(path, maxPixels)
+	if err != nil {
+		return "", "", err
+	}
+	converted := path + ".png"
+
+	return converted, ".png", encodeFile(converted, img)
+}
+
+// contentType detects type of file by its first bytes
+func contentType(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to open image")
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", errors.Wrap(err, "failed to read image")
+	}
+
+	return http.DetectContentType(head[:n]), nil
+}
+
+func clone(img *image.RGBA) *image.RGBA {
+	c := image.NewRGBA(img.Bounds())
+	copy(c.Pix, img.Pix)
+
+	return c
+}
@@ -0,0 +1,285 @@
+package confify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeGIF(t *testing.T, path string, g *gif.GIF) {
+	t.Helper()
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := gif.EncodeAll(f, g); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_SwapAnimation(t *testing.T) {
+	dir := t.TempDir()
+	cascadePath := filepath.Join(dir, "cascade")
+	if err := os.WriteFile(cascadePath, testCascade(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	gray := color.RGBA{R: 128, G: 128, B: 128, A: 255}
+	red := color.RGBA{R: 255, A: 255}
+	p := color.Palette{gray, color.White, color.Black, red}
+
+	// Face moves to the right by 2 pixels every frame
+	input := filepath.Join(dir, "input.gif")
+	g := &gif.GIF{}
+	for i := 0; i < 7; i++ {
+		img := image.NewPaletted(image.Rect(0, 0, 100, 100), p)
+		draw.Draw(img, image.Rect(30+2*i, 30, 70+2*i, 50), image.White, image.Point{}, draw.Src)
+		draw.Draw(img, image.Rect(30+2*i, 50, 70+2*i, 70), image.Black, image.Point{}, draw.Src)
+		g.Image = append(g.Image, img)
+		g.Delay = append(g.Delay, 10)
+	}
+	writeGIF(t, input, g)
+
+	faces := filepath.Join(dir, "faces")
+	if err := os.Mkdir(faces, 0755); err != nil {
+		t.Fatal(err)
+	}
+	face := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	draw.Draw(face, face.Bounds(), image.NewUniform(red), image.Point{}, draw.Src)
+	if err := encodeFile(filepath.Join(faces, "red.png"), face); err != nil {
+		t.Fatal(err)
+	}
+
+	swapper, err := NewCascadeSwapper(cascadePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	swapper.MaxPixels = 100 * 100 * 6
+	if err := swapper.Swap(context.Background(), input, filepath.Join(dir, "large.gif"), faces); !errors.Is(err, ErrAnimationTooLarge) {
+		t.Errorf("expected animation of 7 frames to exceed budget, actual error %v", err)
+	}
+
+	swapper.MaxPixels = DefaultMaxPixels
+	swapper.MaxFrames = 5
+	output := filepath.Join(dir, "output.gif")
+	if err := swapper.Swap(context.Background(), input, output, faces); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	result, err := gif.DecodeAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Image) != 4 {
+		t.Fatalf("expected 4 frames, actual %d", len(result.Image))
+	}
+	if total := result.Delay[0] + result.Delay[1] + result.Delay[2] + result.Delay[3]; total != 70 {
+		t.Errorf("expected duration to be kept, actual %d", total)
+	}
+	for i, img := range result.Image {
+		if c := color.RGBAModel.Convert(img.At(52, 50)); c != red {
+			t.Errorf("expected face to be pasted on frame %d, actual %v", i, c)
+		}
+		if c := color.RGBAModel.Convert(img.At(2, 2)); c != gray {
+			t.Errorf("expected corner of frame %d to be unchanged, actual %v", i, c)
+		}
+	}
+}
+
+func Test_ComposeFrames(t *testing.T) {
+	testCases := []struct {
+		name     string
+		frames   int
+		max      int
+		expected []int
+	}{
+		{
+			name:     "no limit",
+			frames:   3,
+			max:      0,
+			expected: []int{10, 10, 10},
+		},
+		{
+			name:     "below limit",
+			frames:   3,
+			max:      5,
+			expected: []int{10, 10, 10},
+		},
+		{
+			name:     "above limit",
+			frames:   10,
+			max:      4,
+			expected: []int{30, 30, 30, 10},
+		},
+	}
+
+	p := color.Palette{color.Transparent, color.White}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Every frame draws its own pixel over previous frames
+			g := &gif.GIF{Config: image.Config{Width: tc.frames, Height: 1}}
+			for i := 0; i < tc.frames; i++ {
+				img := image.NewPaletted(image.Rect(i, 0, i+1, 1), p)
+				img.SetColorIndex(i, 0, 1)
+				g.Image = append(g.Image, img)
+				g.Delay = append(g.Delay, 10)
+			}
+
+			var buf bytes.Buffer
+			if err := gif.EncodeAll(&buf, g); err != nil {
+				t.Fatal(err)
+			}
+			if n := countFrames(buf.Bytes()); n != tc.frames {
+				t.Errorf("expected %d frames to be counted, actual %d", tc.frames, n)
+			}
+
+			frames := composeFrames(g, tc.max)
+			if len(frames) != len(tc.expected) {
+				t.Fatalf("expected %d frames, actual %d", len(tc.expected), len(frames))
+			}
+			step := (tc.frames + len(frames) - 1) / len(frames)
+			for i, fr := range frames {
+				if fr.delay != tc.expected[i] {
+					t.Errorf("expected delay %d of frame %d, actual %d", tc.expected[i], i, fr.delay)
+				}
+				last := i * step
+				if _, _, _, a := fr.image.At(last, 0).RGBA(); a == 0 {
+					t.Errorf("expected frame %d to include source frame %d", i, last)
+				}
+				if _, _, _, a := fr.image.At(last+1, 0).RGBA(); last+1 < tc.frames && a != 0 {
+					t.Errorf("expected frame %d not to include later frames", i)
+				}
+			}
+		})
+	}
+}
+
+func Test_EncodeAnimation(t *testing.T) {
+	dir := t.TempDir()
+	r := rand.New(rand.NewSource(1))
+
+	var frames []*frame
+	for i := 0; i < 3; i++ {
+		img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+		r.Read(img.Pix)
+		frames = append(frames, &frame{image: img, palette: palette.Plan9, delay: 10})
+	}
+
+	full := filepath.Join(dir, "full.gif")
+	if err := encodeAnimation(full, frames, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(full)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	budget := int(info.Size() / 2)
+	limited := filepath.Join(dir, "limited.gif")
+	if err := encodeAnimation(limited, frames, 0, budget); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(limited); err != nil {
+		t.Fatal(err)
+	} else if info.Size() > int64(budget) {
+		t.Errorf("expected animation to fit into %d bytes, actual %d", budget, info.Size())
+	}
+
+	if err := encodeAnimation(filepath.Join(dir, "tiny.gif"), frames, 0, 10); err == nil {
+		t.Error("expected error when animation doesn't fit into budget")
+	}
+}
+
+func Test_PrepareInput(t *testing.T) {
+	dir := t.TempDir()
+	// First frame is black, second one is white
+	img := image.NewPaletted(image.Rect(0, 0, 10, 10), palette.Plan9)
+	white := image.NewPaletted(img.Bounds(), palette.Plan9)
+	draw.Draw(white, white.Bounds(), image.White, image.Point{}, draw.Src)
+	gifPath := filepath.Join(dir, "image.gif")
+	writeGIF(t, gifPath, &gif.GIF{Image: []*image.Paletted{img, white}, Delay: []int{10, 10}})
+	pngPath := filepath.Join(dir, "image.png")
+	if err := encodeFile(pngPath, img); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name         string
+		input        string
+		swapper      FaceSwapper
+		expectedPath string
+		expectedExt  string
+	}{
+		{
+			name:         "png",
+			input:        pngPath,
+			swapper:      NewChrisify(),
+			expectedPath: pngPath,
+			expectedExt:  ".png",
+		},
+		{
+			name:         "gif with animated backend",
+			input:        gifPath,
+			swapper:      &StubSwapper{},
+			expectedPath: gifPath,
+			expectedExt:  ".gif",
+		},
+		{
+			// Chrisify processes the first frame of animation
+			name:         "gif with static backend",
+			input:        gifPath,
+			swapper:      NewChrisify(),
+			expectedPath: gifPath + ".png",
+			expectedExt:  ".png",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path, ext, err := prepareInput(tc.input, tc.swapper, DefaultMaxPixels)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if path != tc.expectedPath || ext != tc.expectedExt {
+				t.Errorf("expected %s %s, actual %s %s", tc.expectedPath, tc.expectedExt, path, ext)
+			}
+			if typ, err := contentType(path); err != nil {
+				t.Fatal(err)
+			} else if typ != "image/"+ext[1:] {
+				t.Errorf("expected %s file, actual %s", ext, typ)
+			}
+
+			converted, err := decodeFile(path, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r, g, b, _ := converted.At(5, 5).RGBA(); r != 0 || g != 0 || b != 0 {
+				t.Errorf("expected the first frame, actual %v", converted.At(5, 5))
+			}
+		})
+	}
+
+	// Images of 10x10 are rejected before they are decoded by bot or backend
+	for _, input := range []string{pngPath, gifPath} {
+		if _, _, err := prepareInput(input, NewChrisify(), 99); !errors.Is(err, ErrImageTooLarge) {
+			t.Errorf("expected %s to exceed pixel budget, actual error %v", filepath.Base(input), err)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package confify
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// Detection parameters of cascade, they are the usual ones for pico face finder
+const (
+	minFaceSize  = 20
+	shiftFactor  = 0.1
+	scaleFactor  = 1.1
+	iouThreshold = 0.2
+)
+
+// Cascade is a face detector in pico format: every tree compares intensity of pixel pairs
+// inside of region and adds prediction of its leaf, region is rejected when sum falls below threshold of tree.
+// Files of pico and pigo face finder, e.g. facefinder, can be loaded
+type Cascade struct {
+	depth      int
+	codes      []int8
+	preds      []float32
+	thresholds []float32
+}
+
+// Detection is a square region of face, Row and Col are its center
+type Detection struct {
+	Row, Col, Scale int
+	// Quality is a sum of scores of clustered regions
+	Quality float32
+}
+
+// Rect returns bounds of detection
+func (d Detection) Rect() image.Rectangle {
+	half := d.Scale / 2
+	return image.Rect(d.Col-half, d.Row-half, d.Col+half, d.Row+half)
+}
+
+// ParseCascade parses cascade file: 8 bytes of header, depth and number of trees,
+// then every tree with 4 codes per node, predictions of leaves and threshold
+func ParseCascade(data []byte) (*Cascade, error) {
+	if len(data) < 16 {
+		return nil, errors.New("cascade is too short")
+	}
+
+	depth := binary.LittleEndian.Uint32(data[8:])
+	trees := binary.LittleEndian.Uint32(data[12:])
+	if depth == 0 || depth > 16 {
+		return nil, errors.Errorf("invalid depth of cascade trees %d", depth)
+	}
+
+	leaves := 1 << depth
+	treeSize := 4*leaves - 4 + 4*leaves + 4
+	if uint64(len(data)-16) != uint64(trees)*uint64(treeSize) {
+		return nil, errors.Errorf("cascade of %d trees of depth %d has invalid size %d", trees, depth, len(data))
+	}
+
+	c := &Cascade{depth: int(depth)}
+	pos := 16
+	for t := 0; t < int(trees); t++ {
+		// Codes of tree start from node 1, first four are padding
+		c.codes = append(c.codes, 0, 0, 0, 0)
+		for _, b := range data[pos : pos+4*leaves-4] {
+			c.codes = append(c.codes, int8(b))
+		}
+		pos += 4*leaves - 4
+
+		for i := 0; i < leaves; i++ {
+			c.preds = append(c.preds, math.Float32frombits(binary.LittleEndian.Uint32(data[pos:])))
+			pos += 4
+		}
+		c.thresholds = append(c.thresholds, math.Float32frombits(binary.LittleEndian.Uint32(data[pos:])))
+		pos += 4
+	}
+
+	return c, nil
+}
+
+// Detect finds faces on image, detections overlapping each other are clustered
+func (c *Cascade) Detect(img image.Image) []Detection {
+	pixels, rows, cols := grayscale(img)
+	maxSize := rows
+	if cols < maxSize {
+		maxSize = cols
+	}
+
+	var detections []Detection
+	for scale := minFaceSize; scale <= maxSize; scale = int(float64(scale) * scaleFactor) {
+		step := int(math.Max(shiftFactor*float64(scale), 1))
+		offset := scale/2 + 1
+
+		for row := offset; row <= rows-offset; row += step {
+			for col := offset; col <= cols-offset; col += step {
+				if q := c.classify(row, col, scale, pixels, cols); q > 0 {
+					detections = append(detections, Detection{Row: row, Col: col, Scale: scale, Quality: q})
+				}
+			}
+		}
+	}
+
+	return cluster(detections)
+}
+
+// classify returns score of region or -1 when region is rejected by one of trees
+func (c *Cascade) classify(row, col, scale int, pixels []uint8, cols int) float32 {
+	leaves := 1 << c.depth
+	row, col = row*256, col*256
+
+	var out float32
+	root := 0
+	for i, threshold := range c.thresholds {
+		idx := 1
+		for j := 0; j < c.depth; j++ {
+			code := c.codes[root+4*idx:]
+			p1 := ((row+int(code[0])*scale)>>8)*cols + ((col + int(code[1])*scale) >> 8)
+			p2 := ((row+int(code[2])*scale)>>8)*cols + ((col + int(code[3])*scale) >> 8)
+
+			idx *= 2
+			if pixels[p1] <= pixels[p2] {
+				idx++
+			}
+		}
+
+		out += c.preds[leaves*i+idx-leaves]
+		if out <= threshold {
+			return -1
+		}
+		root += 4 * leaves
+	}
+
+	return out - c.thresholds[len(c.thresholds)-1]
+}
+
+// cluster merges detections with intersection over union above threshold into their average
+func cluster(detections []Detection) []Detection {
+	assigned := make([]bool, len(detections))
+
+	var clusters []Detection
+	for i := range detections {
+		if assigned[i] {
+			continue
+		}
+
+		var row, col, scale, n int
+		var quality float32
+		for j := i; j < len(detections); j++ {
+			if assigned[j] || iou(detections[i], detections[j]) <= iouThreshold {
+				continue
+			}
+			assigned[j] = true
+			row, col, scale, n = row+detections[j].Row, col+detections[j].Col, scale+detections[j].Scale, n+1
+			quality += detections[j].Quality
+		}
+		clusters = append(clusters, Detection{Row: row / n, Col: col / n, Scale: scale / n, Quality: quality})
+	}
+
+	return clusters
+}
+
+func iou(a, b Detection) float64 {
+	intersection := a.Rect().Intersect(b.Rect())
+	if intersection.Empty() {
+		return 0
+	}
+
+	i := float64(intersection.Dx() * intersection.Dy())
+	return i / (float64(a.Scale*a.Scale+b.Scale*b.Scale) - i)
+}
+
+// grayscale returns luminance of image pixels row by row
+func grayscale(img image.Image) ([]uint8, int, int) {
+	bounds := img.Bounds()
+	rows, cols := bounds.Dy(), bounds.Dx()
+
+	pixels := make([]uint8, rows*cols)
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			pixels[y*cols+x] = color.GrayModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.Gray).Y
+		}
+	}
+
+	return pixels, rows, cols
+}
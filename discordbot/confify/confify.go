@@ -1,32 +1,55 @@
 package confify
 
 import (
-	"crypto/md5"
+	"context"
 	"fmt"
 	"io"
 	"mime"
 	"net/http"
-	"net/url"
 	"os"
-	"os/exec"
 	"path"
 	"path/filepath"
 	"regexp"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
+	"github.com/paulvasilenko/discordbot/discordbot/discord"
+	"github.com/paulvasilenko/discordbot/discordbot/lifecycle"
+	"github.com/paulvasilenko/discordbot/discordbot/metrics"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+	"github.com/paulvasilenko/discordbot/discordbot/scheduler"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 )
 
 var (
-	// generated by chatgpt
-	imageRegex = regexp.MustCompile(`(?i)(https?://[^\s?]+\.(?:jpg|jpeg|png)\?(?:[^&\s]+=[^&\s]+&?)*)`)
+	// imageRegex matches links to images with supported extension and optional query string
+	imageRegex = regexp.MustCompile(`(?i)(https?://[^\s?]+\.(?:jpg|jpeg|png|gif|webp)(?:\?[^\s]*)?)`)
+
+	// imageTypes are types of downloaded images which can be decoded
+	imageTypes = map[string]bool{"image/jpeg": true, "image/png": true, typeGIF: true, typeWebP: true}
+
+	// ErrImageTooLarge is returned when image exceeds download limit or pixel budget
+	ErrImageTooLarge = errors.New("image is too large")
 )
 
 const (
 	downloadTimeout int = 60
+
+	// OutputAttachment uploads processed images to discord, images over upload limit are sent as links
+	OutputAttachment = "attachment"
+	// OutputURL sends links to processed images served by file server
+	OutputURL = "url"
+
+	// DefaultUploadLimit is maximal size of file uploaded to discord server without boosts
+	DefaultUploadLimit = 10 << 20
+	// DefaultDownloadLimit is maximal size of downloaded image, larger ones are rejected before they are read
+	DefaultDownloadLimit = 25 << 20
+
+	// JobCleanup is handler of bot wide job which deletes expired images
+	JobCleanup = "confify.cleanup"
 )
 
 // Confify is struct which represents Confify plugin with it's configurations
@@ -34,42 +57,204 @@ type Confify struct {
 	BasePath string
 	BaseUrl  string
 	Faces    string
+
+	mu            sync.RWMutex
+	queue         *Queue
+	store         *Store
+	scheduler     *scheduler.Scheduler
+	swapper       FaceSwapper
+	output        string
+	uploadLimit   int64
+	downloadLimit int64
+	maxPixels     int64
+	admins        []string
 }
 
+// NewConfify is a constructor function for confify plugin. Images are kept in temporary directory
+// if base path is empty, links to them are sent only if base url is set
 func NewConfify(basePath, baseUrl, faces string) *Confify {
-	return &Confify{BasePath: basePath, BaseUrl: baseUrl, Faces: faces}
+	if basePath == "" {
+		basePath = filepath.Join(os.TempDir(), "discordbot-confify")
+	}
+
+	c := &Confify{
+		BasePath:      basePath,
+		BaseUrl:       baseUrl,
+		Faces:         faces,
+		store:         NewStore(basePath),
+		swapper:       NewChrisify(),
+		output:        OutputAttachment,
+		uploadLimit:   DefaultUploadLimit,
+		downloadLimit: DefaultDownloadLimit,
+		maxPixels:     DefaultMaxPixels,
+	}
+	c.SetQueue(NewQueue(DefaultWorkers, DefaultUserLimit, DefaultQueueLimit, DefaultTimeout))
+
+	return c
+}
+
+// SetQueue replaces queue of images, it must be called before images are queued
+func (c *Confify) SetQueue(q *Queue) {
+	if c.queue != nil {
+		q.lifecycle = c.queue.lifecycle
+	}
+	q.process = func(ctx context.Context, job *Job) (string, error) {
+		return c.processImage(ctx, job.Image, job.Spoiler)
+	}
+	c.queue = q
+}
+
+// SetFaces replaces directory with faces, safe to call while images are processed
+func (c *Confify) SetFaces(faces string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Faces = faces
+}
+
+// SetOutput sets how processed images are sent: OutputAttachment or OutputURL, and maximal size of uploaded file
+func (c *Confify) SetOutput(output string, uploadLimit int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.output, c.uploadLimit = output, uploadLimit
+}
+
+// SetInputLimits sets maximal size of downloaded image in bytes and width × height of decoded image,
+// larger images are rejected, 0 disables limits
+func (c *Confify) SetInputLimits(downloadLimit, maxPixels int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.downloadLimit, c.maxPixels = downloadLimit, maxPixels
+}
+
+// SetSwapper replaces backend which swaps faces, chrisify is used by default
+func (c *Confify) SetSwapper(swapper FaceSwapper) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.swapper = swapper
+}
+
+// SetRetention sets TTL of processed images and maximal total size of storage, 0 disables them
+func (c *Confify) SetRetention(ttl time.Duration, maxSize int64) {
+	c.store.SetRetention(ttl, maxSize)
+}
+
+// SetAdmins sets ids of users allowed to see storage usage, safe to call while commands are handled
+func (c *Confify) SetAdmins(admins []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.admins = admins
+}
+
+// SetScheduler sets scheduler of cleanup job
+func (c *Confify) SetScheduler(s *scheduler.Scheduler) {
+	c.scheduler = s
+}
+
+// FileServer serves processed images from base path
+func (c *Confify) FileServer() http.Handler {
+	return c.store.FileServer()
+}
+
+// SetLifecycle sets manager which tracks image processing, so chrisify is not killed on shutdown
+func (c *Confify) SetLifecycle(l *lifecycle.Manager) {
+	c.queue.mu.Lock()
+	defer c.queue.mu.Unlock()
+
+	c.queue.lifecycle = l
 }
 
 // GetInfo returns map of info message
 func (c *Confify) GetInfo() map[string]string {
 	return map[string]string{
-		"!confify": `!confify [imageurl] - Replaces faces on image to faces from folder, uses Google Vision API.`,
+		"!confify":         `!confify [imageurl] - Replaces faces on image to faces from folder, uses Google Vision API.`,
+		"!confify cancel":  "Cancels your last queued image",
+		"!confify storage": "Prints number and size of stored images, available to bot admins",
 	}
 }
 
-func (c *Confify) MessageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
-	if m.Author.Bot {
-		return
+func (c *Confify) Name() string {
+	return "Confify"
+}
+
+func (c *Confify) Handlers() []interface{} {
+	return nil
+}
+
+// Init registers cleanup job, it's scheduled once and kept in database
+func (c *Confify) Init(s *discordgo.Session) error {
+	if c.scheduler == nil || !c.store.Retained() {
+		return nil
 	}
+	c.scheduler.Handle(JobCleanup, c.cleanup)
 
-	if !strings.HasPrefix(m.Content, "!confify") {
-		return
+	jobs, err := c.scheduler.Jobs("")
+	if err != nil {
+		return err
 	}
+	for _, job := range jobs {
+		if job.Handler == JobCleanup {
+			return nil
+		}
+	}
+
+	return c.scheduler.Schedule(&scheduler.Job{
+		Handler:     JobCleanup,
+		Description: "Confify storage cleanup",
+		Schedule:    "@hourly",
+	})
+}
 
-	log.Println("Starting confify image")
-	defer log.Println("Finishing confify image")
+func (c *Confify) Close() error {
+	return nil
+}
+
+// Commands returns commands of plugin handled by router
+func (c *Confify) Commands() []*router.Command {
+	return []*router.Command{
+		{
+			Name:        "confify",
+			Description: c.GetInfo()["!confify"],
+			Args: []router.Arg{
+				{Name: "imageurl", Type: router.ArgString, Optional: true, Description: "link to PNG, JPEG, GIF or WebP image, cancel or storage"},
+				{Name: "image", Type: router.ArgAttachment, Optional: true, Description: "PNG, JPEG, GIF or WebP image"},
+			},
+			Handler:  c.confify,
+			Slash:    true,
+			Deferred: true,
+			// Cancel is allowed even when rate limit is reached
+			Unlimited: func(ctx *router.Context) bool {
+				return isCancel(ctx.RawArgs)
+			},
+		},
+	}
+}
 
-	imgCh := make(chan string)
-	ticksWaiting := 1
-	message, _ := s.ChannelMessageSend(m.ChannelID, "Processing"+strings.Repeat(".", ticksWaiting%4))
+func (c *Confify) confify(ctx *router.Context) error {
+	s, m := ctx.Session, ctx.Message
+
+	if isCancel(ctx.Args.String("imageurl")) {
+		return c.cancel(ctx)
+	}
+	if strings.EqualFold(strings.TrimSpace(ctx.Args.String("imageurl")), "storage") {
+		return c.storage(ctx)
+	}
+
+	message, err := ctx.Reply("Processing...")
+	if err != nil {
+		return err
+	}
 
 	var imageString string
 	messages, err := s.ChannelMessages(m.ChannelID, 10, message.ID, "", "")
 	if err != nil {
-		log.Println(err)
-		return
+		return err
 	}
-	messages = append([]*discordgo.Message{m.Message}, messages...)
+	messages = append([]*discordgo.Message{m, {Content: ctx.Args.String("imageurl")}}, messages...)
 
 loop:
 	for _, m := range messages {
@@ -84,151 +269,312 @@ loop:
 	}
 
 	if imageString == "" {
-		s.ChannelMessageEdit(m.ChannelID, message.ID, "Please, provide image link with PNG or JPEG extension")
+		_, err := ctx.Edit(message, "Please, provide image link with PNG, JPEG, GIF or WebP extension")
+
+		return err
+	}
 
-		return
+	job, err := c.queue.Add(m.Author.ID, imageString, strings.Contains(imageString, "SPOILER"))
+	switch {
+	case errors.Is(err, ErrStopping):
+		_, err := ctx.Edit(message, "Bot is restarting, please, try again in a minute")
+		return err
+	case errors.Is(err, ErrUserLimit):
+		_, err := ctx.Edit(message, fmt.Sprintf("You already have %d images in queue, please, wait until they are processed", c.queue.userLimit))
+		return err
+	case errors.Is(err, ErrQueueFull):
+		_, err := ctx.Edit(message, "Too many images are in queue, please, try again later")
+		return err
+	case err != nil:
+		return err
 	}
+	log.Printf("Confify job #%d is queued", job.ID)
+
+	// Message is edited only when job status changes
+	content := message.Content
+	for range job.Updates() {
+		status := c.queue.Status(job)
+		if status.State == StateDone {
+			log.Printf("Confify job #%d is finished", job.ID)
+			return c.deliver(ctx, message, job, status.Result)
+		}
 
-	spoiler := strings.Contains(imageString, "SPOILER")
-	go c.processImage(imgCh, imageString, spoiler)
+		if text := c.statusText(ctx.Prefix, status); text != content {
+			content = text
+			if _, err := ctx.Edit(message, text); err != nil {
+				log.Println("failed to edit message: ", err)
+			}
+		}
 
-	for {
-		time.Sleep(500 * time.Millisecond)
-		select {
-		case image := <-imgCh:
-			imageUrl := c.BaseUrl + image
-			if spoiler {
-				imageUrl = "||" + imageUrl + "||"
+		if status.Finished() {
+			log.Printf("Confify job #%d is finished", job.ID)
+			// Too large image is rejected with reply, it's not a failure of bot
+			if status.State == StateFailed && !errors.Is(status.Err, ErrAnimationTooLarge) && !errors.Is(status.Err, ErrImageTooLarge) {
+				return errors.Wrap(status.Err, "failed to process image")
 			}
-			s.ChannelMessageEdit(m.ChannelID, message.ID, "Processed file: "+imageUrl)
+			return nil
+		}
+	}
+
+	return nil
+}
 
-			if image == "" {
-				s.ChannelMessageEdit(
-					m.ChannelID,
-					message.ID,
-					"Error during processing, please, notify PandaSam about it")
+func (c *Confify) cancel(ctx *router.Context) error {
+	job, ok := c.queue.Cancel(ctx.Message.Author.ID)
+	if !ok {
+		_, err := ctx.Reply("You have no images in queue")
+		return err
+	}
+
+	_, err := ctx.Reply(fmt.Sprintf("Image #%d is cancelled", job.ID))
+	return err
+}
+
+func (c *Confify) storage(ctx *router.Context) error {
+	c.mu.RLock()
+	admin := contains(c.admins, ctx.Message.Author.ID)
+	c.mu.RUnlock()
+	if !admin {
+		return &router.DeniedError{Reason: "Only bot admins can see confify storage"}
+	}
+
+	u, err := c.store.Usage()
+	if err != nil {
+		return err
+	}
+
+	text := fmt.Sprintf("Confify storage: %d processed images, %d originals in processing, %s", u.Outputs, u.Originals, formatSize(u.Size))
+	if u.MaxSize > 0 {
+		text += " of " + formatSize(u.MaxSize)
+	}
+	if u.TTL > 0 {
+		text += fmt.Sprintf(", processed images are kept for %s", u.TTL)
+	}
+	_, err = ctx.Reply(text)
+	return err
+}
+
+func (c *Confify) cleanup(s discord.Session, job *scheduler.Job) error {
+	n, err := c.store.Cleanup()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		log.Printf("Confify storage cleanup deleted %d files", n)
+	}
+
+	return nil
+}
+
+// statusText returns message shown for job status
+func (c *Confify) statusText(prefix string, status Status) string {
+	switch status.State {
+	case StateQueued:
+		return fmt.Sprintf("#%d in queue, cancel with `%sconfify cancel`", status.Position, prefix)
+	case StateProcessing:
+		return "Processing..."
+	case StateCancelled:
+		if errors.Is(status.Err, ErrStopping) {
+			return "Bot is restarting, please, try again in a minute"
+		}
+		return "Processing is cancelled"
+	case StateFailed:
+		if errors.Is(status.Err, ErrAnimationTooLarge) {
+			return "Animation is too large, please, try shorter or smaller one"
+		}
+		if errors.Is(status.Err, ErrImageTooLarge) {
+			return "Image is too large, please, try smaller one"
+		}
+		return "Error during processing, please, try again later"
+	default:
+		return "Error during processing, please, try again later"
+	}
+}
+
+// deliver uploads processed image, link to it is sent when output is url or image exceeds upload limit
+func (c *Confify) deliver(ctx *router.Context, message *discordgo.Message, job *Job, name string) error {
+	c.mu.RLock()
+	output, uploadLimit := c.output, c.uploadLimit
+	c.mu.RUnlock()
+
+	path := c.store.Path(name)
+	c.store.Touch(name)
+	if output == OutputAttachment {
+		info, err := os.Stat(path)
+		if err != nil {
+			return errors.Wrap(err, "failed to read processed image")
+		}
+
+		if info.Size() <= uploadLimit {
+			f, err := os.Open(path)
+			if err != nil {
+				return errors.Wrap(err, "failed to read processed image")
 			}
+			defer f.Close()
 
-			return
-		default:
-			ticksWaiting += 1
-			s.ChannelMessageEdit(m.ChannelID, message.ID, "Processing"+strings.Repeat(".", ticksWaiting%4))
-			if ticksWaiting > 50 {
-				s.ChannelMessageEdit(m.ChannelID, message.ID, "Processing time exceeed")
-				return
+			if _, err := ctx.Edit(message, "Processed file:"); err != nil {
+				log.Println("failed to edit message: ", err)
 			}
+			// Discord hides attachments with SPOILER_ prefix, processed file keeps it
+			_, err = ctx.ReplyComplex(&discordgo.MessageSend{
+				Files: []*discordgo.File{{Name: name, ContentType: mime.TypeByExtension(filepath.Ext(name)), Reader: f}},
+			})
+			return err
 		}
+		log.Printf("Processed image %s of %d bytes exceeds upload limit", name, info.Size())
+	}
+
+	if c.BaseUrl == "" {
+		_, err := ctx.Edit(message, "Processed image is too large to upload")
+		return err
+	}
+
+	imageUrl := c.BaseUrl + name
+	if job.Spoiler {
+		imageUrl = "||" + imageUrl + "||"
 	}
+	_, err := ctx.Edit(message, "Processed file: "+imageUrl)
+	return err
+}
+
+func isCancel(arg string) bool {
+	return strings.EqualFold(strings.TrimSpace(arg), "cancel")
 }
 
-func (c *Confify) processImage(imgCh chan<- string, imageString string, spoiler bool) {
+func contains(ids []string, id string) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// formatSize returns size in bytes, KiB, MiB or GiB
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	value, units := float64(size)/unit, "KiB"
+	for _, u := range []string{"MiB", "GiB"} {
+		if value < unit {
+			break
+		}
+		value, units = value/unit, u
+	}
+
+	return fmt.Sprintf("%.1f %s", value, units)
+}
+
+// processImage downloads image, replaces faces and returns name of processed file.
+// Downloaded and converted images are deleted right after processing
+func (c *Confify) processImage(ctx context.Context, imageString string, spoiler bool) (string, error) {
 	log.Println("Started image processing")
 	defer log.Println("Finished image processing")
 
-	downloadedFilename, downloadedFilePath, err := downloadFromUrl(imageString, "", c.BasePath)
+	c.mu.RLock()
+	faces, swapper, downloadLimit, maxPixels := c.Faces, c.swapper, c.downloadLimit, c.maxPixels
+	c.mu.RUnlock()
+
+	original := c.store.NewName(strings.ToLower(path.Ext(filenameFromUrl(imageString))))
+	defer c.removeFile(original)
+	if err := downloadFromUrl(ctx, imageString, c.store.Path(original), downloadLimit); err != nil {
+		return "", err
+	}
+	if err := c.store.Add(original, KindOriginal); err != nil {
+		return "", err
+	}
 
+	input, fileExtension, err := prepareInput(c.store.Path(original), swapper, maxPixels)
 	if err != nil {
-		log.Println(err)
-		imgCh <- ""
-		return
+		return "", err
+	}
+	if converted := filepath.Base(input); converted != original {
+		defer c.removeFile(converted)
+		if err := c.store.Add(converted, KindOriginal); err != nil {
+			return "", err
+		}
 	}
-	splittedString := strings.Split(downloadedFilename, ".")
-	fileExtension := splittedString[len(splittedString)-1]
-	outputFileName := fmt.Sprintf("%x", md5.Sum([]byte("processed_"+downloadedFilename+time.Now().Format(time.RFC3339Nano)))) + "." + fileExtension
+
+	outputFileName := c.store.NewName(fileExtension)
 	if spoiler {
 		outputFileName = "SPOILER_" + outputFileName
 	}
-	outputFilePath := c.BasePath + "/" + outputFileName
-
-	args := []string{
-		"--faces", c.Faces,
-		downloadedFilePath}
 
-	cmd := exec.Command("chrisify", args...)
-
-	out, err := cmd.CombinedOutput()
+	start := time.Now()
+	err = swapper.Swap(ctx, input, c.store.Path(outputFileName), faces)
+	metrics.ChrisifyDuration.Observe(time.Since(start).Seconds())
 
 	if err != nil {
-		log.Println("Non-zero exit code: " + err.Error() + ", " + string(out))
+		// Backend may leave partially written file
+		c.removeFile(outputFileName)
+		return "", errors.Wrap(err, "failed to swap faces")
+	}
+	if err := c.store.Add(outputFileName, KindOutput); err != nil {
+		return "", err
 	}
-
-	err = os.WriteFile(outputFilePath, out, 0644)
 
 	log.Println("Image processed, putting in channel")
 
-	imgCh <- outputFileName
-	return
+	return outputFileName, nil
+}
+
+func (c *Confify) removeFile(name string) {
+	if err := c.store.Remove(name); err != nil {
+		log.Println(err)
+	}
 }
 
-func downloadFromUrl(dUrl string, filename string, path string) (string, string, error) {
-	err := os.MkdirAll(path, 755)
+// downloadFromUrl saves image to file, other content and images larger than limit are rejected, 0 disables limit
+func downloadFromUrl(ctx context.Context, dUrl string, filePath string, limit int64) error {
+	err := os.MkdirAll(filepath.Dir(filePath), 0755)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to create folder %v: %v", path, err)
+		return fmt.Errorf("failed to create folder %v: %v", filepath.Dir(filePath), err)
 	}
 
 	timeout := time.Duration(time.Duration(downloadTimeout) * time.Second)
 	client := &http.Client{
 		Timeout: timeout,
 	}
-	request, err := http.NewRequest("GET", dUrl, nil)
+	request, err := http.NewRequestWithContext(ctx, "GET", dUrl, nil)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to init request %v: %v", dUrl, err)
+		return fmt.Errorf("failed to init request %v: %v", dUrl, err)
 	}
 	request.Header.Add("Accept-Encoding", "identity")
 	response, err := client.Do(request)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to download %v: %v", dUrl, err)
+		return fmt.Errorf("failed to download %v: %v", dUrl, err)
 	}
 	defer response.Body.Close()
 
-	if filename == "" {
-		filename = filenameFromUrl(response.Request.URL.String())
-		for key, iHeader := range response.Header {
-			if key == "Content-Disposition" {
-				_, params, err := mime.ParseMediaType(iHeader[0])
-				if err == nil {
-					newFilename, err := url.QueryUnescape(params["filename"])
-					if err != nil {
-						newFilename = params["filename"]
-					}
-					if newFilename != "" {
-						filename = newFilename
-					}
-				}
-			}
-		}
-	}
-
-	completePath := path + string(os.PathSeparator) + filename
-	if _, err := os.Stat(completePath); err == nil {
-		tmpPath := completePath
-		i := 1
-		for {
-			completePath = tmpPath[0:len(tmpPath)-len(filepath.Ext(tmpPath))] +
-				"-" + strconv.Itoa(i) + filepath.Ext(tmpPath)
-			if _, err := os.Stat(completePath); os.IsNotExist(err) {
-				break
-			}
-			i = i + 1
+	// Size is checked before body is read, body without length is read up to the limit
+	body := io.Reader(response.Body)
+	if limit > 0 {
+		if response.ContentLength > limit {
+			return fmt.Errorf("%v is larger than %d bytes: %w", dUrl, limit, ErrImageTooLarge)
 		}
-		log.Printf("[%s] Saving possible duplicate (filenames match): %s to %s\n", time.Now().Format(time.Stamp), tmpPath, completePath)
+		body = io.LimitReader(body, limit+1)
 	}
-
-	bodyOfResp, err := io.ReadAll(response.Body)
+	bodyOfResp, err := io.ReadAll(body)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to read %v response: %v", dUrl, err)
+		return fmt.Errorf("failed to read %v response: %v", dUrl, err)
+	}
+	if limit > 0 && int64(len(bodyOfResp)) > limit {
+		return fmt.Errorf("%v is larger than %d bytes: %w", dUrl, limit, ErrImageTooLarge)
 	}
 
-	contentTypeParts := strings.Split(http.DetectContentType(bodyOfResp), "/")
-	if contentTypeParts[0] != "image" && contentTypeParts[0] != "video" {
-		return "", "", fmt.Errorf("image not found at %v", dUrl)
+	if !imageTypes[http.DetectContentType(bodyOfResp)] {
+		return fmt.Errorf("image not found at %v", dUrl)
 	}
 
-	if err = os.WriteFile(completePath, bodyOfResp, 0644); err != nil {
-		return "", "", fmt.Errorf("failed to write %v content to disk: %v", dUrl, err)
+	if err = os.WriteFile(filePath, bodyOfResp, 0644); err != nil {
+		return fmt.Errorf("failed to write %v content to disk: %v", dUrl, err)
 	}
 
-	return filename, completePath, err
+	return nil
 }
 
 func filenameFromUrl(dUrl string) string {
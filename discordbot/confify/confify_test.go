@@ -0,0 +1,229 @@
+package confify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/paulvasilenko/discordbot/discordbot/discord/discordtest"
+	"github.com/paulvasilenko/discordbot/discordbot/lifecycle"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+)
+
+func Test_Commands(t *testing.T) {
+	// Stopped lifecycle doesn't start processing, so chrisify is not needed
+	stopped := lifecycle.NewManager()
+	if err := stopped.Shutdown(0); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name     string
+		history  []string
+		message  string
+		expected []string
+	}{
+		{
+			name:     "no image",
+			history:  []string{"hello"},
+			message:  "!confify",
+			expected: []string{"Please, provide image link with PNG, JPEG, GIF or WebP extension"},
+		},
+		{
+			name:     "image without extension",
+			message:  "!confify http://example.com/image.bmp?size=1",
+			expected: []string{"Please, provide image link with PNG, JPEG, GIF or WebP extension"},
+		},
+		{
+			name:     "image in argument",
+			message:  "!confify http://example.com/image.webp",
+			expected: []string{"Bot is restarting, please, try again in a minute"},
+		},
+		{
+			name:     "cancel without images",
+			message:  "!confify cancel",
+			expected: []string{"You have no images in queue"},
+		},
+		{
+			name:     "image in history",
+			history:  []string{"http://example.com/image.jpg?size=1", "hello"},
+			message:  "!confify",
+			expected: []string{"Bot is restarting, please, try again in a minute"},
+		},
+	}
+
+	for _, v := range testCases {
+		c := NewConfify(t.TempDir(), "http://localhost/", "faces")
+		c.SetLifecycle(stopped)
+		rt := router.New("!")
+		if err := rt.Add(c.Name(), c.Commands()...); err != nil {
+			t.Fatal(err)
+		}
+
+		s := discordtest.NewSession()
+		for _, content := range v.history {
+			s.AddMessage(discordtest.Message("channel", "20", content))
+		}
+		rt.HandleMessage(s, discordtest.Message("channel", "10", v.message))
+
+		if actual := s.Contents(); !reflect.DeepEqual(actual, v.expected) {
+			t.Errorf("%s: expected %q, actual %q", v.name, v.expected, actual)
+		}
+	}
+}
+
+func Test_Process(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+	img := buf.Bytes()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(img)
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		name          string
+		output        string
+		baseUrl       string
+		uploadLimit   int64
+		downloadLimit int64
+		maxPixels     int64
+		swapErr       error
+		expected      []string
+		attached      bool
+	}{
+		{name: "attachment", output: OutputAttachment, uploadLimit: DefaultUploadLimit, expected: []string{"Processed file:", ""}, attached: true},
+		{name: "too large", output: OutputAttachment, baseUrl: "http://localhost/", uploadLimit: 10, expected: []string{"Processed file: ||http://localhost/SPOILER_"}},
+		{name: "too large without url", output: OutputAttachment, uploadLimit: 10, expected: []string{"Processed image is too large to upload"}},
+		{name: "url", output: OutputURL, baseUrl: "http://localhost/", expected: []string{"Processed file: ||http://localhost/SPOILER_"}},
+		{name: "too large animation", output: OutputAttachment, swapErr: ErrAnimationTooLarge, expected: []string{"Animation is too large, please, try shorter or smaller one"}},
+		{name: "too large download", output: OutputAttachment, downloadLimit: 10, expected: []string{"Image is too large, please, try smaller one"}},
+		{name: "too many pixels", output: OutputAttachment, maxPixels: 3, expected: []string{"Image is too large, please, try smaller one"}},
+	}
+
+	for _, tc := range testCases {
+		c := NewConfify(t.TempDir(), tc.baseUrl, "faces")
+		c.SetSwapper(&StubSwapper{Err: tc.swapErr})
+		c.SetOutput(tc.output, tc.uploadLimit)
+		c.SetInputLimits(tc.downloadLimit, tc.maxPixels)
+		rt := router.New("!")
+		if err := rt.Add(c.Name(), c.Commands()...); err != nil {
+			t.Fatal(err)
+		}
+
+		s := discordtest.NewSession()
+		rt.HandleMessage(s, discordtest.Message("channel", "10", "!confify "+server.URL+"/SPOILER_cat.png?size=1"))
+
+		contents := s.Contents()
+		if len(contents) != len(tc.expected) {
+			t.Errorf("%s: expected %q, actual %q", tc.name, tc.expected, contents)
+			continue
+		}
+		for i := range contents {
+			if !strings.HasPrefix(contents[i], tc.expected[i]) {
+				t.Errorf("%s: expected %q, actual %q", tc.name, tc.expected, contents)
+			}
+		}
+		// Originals are deleted right after processing
+		if u, err := c.store.Usage(); err != nil {
+			t.Fatal(err)
+		} else if (tc.swapErr == nil && tc.downloadLimit == 0 && tc.maxPixels == 0 && u.Outputs != 1) || u.Originals != 0 {
+			t.Errorf("%s: expected only processed image to be stored, actual %+v", tc.name, u)
+		}
+		if !tc.attached {
+			continue
+		}
+
+		attachment := s.Sent[1].Attachments[0]
+		if !strings.HasPrefix(attachment.Filename, "SPOILER_") || !strings.HasSuffix(attachment.Filename, ".png") {
+			t.Errorf("%s: expected spoiler png, actual %s", tc.name, attachment.Filename)
+		}
+		if actual, _ := s.File(s.Sent[1].ID, attachment.Filename); string(actual) != string(img) {
+			t.Errorf("%s: expected processed image to be attached, actual %q", tc.name, actual)
+		}
+	}
+}
+
+func Test_DownloadFromUrl(t *testing.T) {
+	body := []byte("\x89PNG\r\n\x1a\n image")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Flushed response has no length, so it's limited while it's read
+		if r.URL.Path == "/chunked.png" {
+			w.(http.Flusher).Flush()
+		}
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		name     string
+		path     string
+		limit    int64
+		tooLarge bool
+	}{
+		{name: "no limit", path: "/image.png"},
+		{name: "below limit", path: "/image.png", limit: int64(len(body))},
+		{name: "above limit", path: "/image.png", limit: int64(len(body)) - 1, tooLarge: true},
+		{name: "above limit without length", path: "/chunked.png", limit: int64(len(body)) - 1, tooLarge: true},
+	}
+
+	for _, tc := range testCases {
+		file := filepath.Join(t.TempDir(), "image.png")
+		err := downloadFromUrl(context.Background(), server.URL+tc.path, file, tc.limit)
+		if tc.tooLarge {
+			if !errors.Is(err, ErrImageTooLarge) {
+				t.Errorf("%s: expected too large error, actual %v", tc.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		} else if data, _ := os.ReadFile(file); !bytes.Equal(data, body) {
+			t.Errorf("%s: expected image to be saved, actual %q", tc.name, data)
+		}
+	}
+}
+
+func Test_Storage(t *testing.T) {
+	testCases := []struct {
+		name     string
+		authorID string
+		expected []string
+	}{
+		{name: "admin", authorID: "10", expected: []string{"Confify storage: 1 processed images, 0 originals in processing, 5 B of 1.0 GiB, processed images are kept for 168h0m0s"}},
+		{name: "not admin", authorID: "20", expected: []string{"Only bot admins can see confify storage"}},
+	}
+
+	for _, tc := range testCases {
+		c := NewConfify(t.TempDir(), "", "faces")
+		c.SetAdmins([]string{"10"})
+		if err := os.WriteFile(c.store.Path("output.png"), []byte("image"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.store.Add("output.png", KindOutput); err != nil {
+			t.Fatal(err)
+		}
+		rt := router.New("!")
+		if err := rt.Add(c.Name(), c.Commands()...); err != nil {
+			t.Fatal(err)
+		}
+
+		s := discordtest.NewSession()
+		rt.HandleMessage(s, discordtest.Message("channel", tc.authorID, "!confify storage"))
+
+		if contents := s.Contents(); !reflect.DeepEqual(contents, tc.expected) {
+			t.Errorf("%s: expected %q, actual %q", tc.name, tc.expected, contents)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package confify
+
+import (
+	"context"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultMinQuality is quality of detection which is treated as face, it fits pico face finder
+	DefaultMinQuality = 5
+
+	// faceScale makes pasted face bigger than detected region, because images of faces include hair and chin
+	faceScale   = 1.3
+	jpegQuality = 90
+)
+
+// CascadeSwapper detects faces with cascade and pastes random images from faces directory over them,
+// it doesn't need external binaries
+type CascadeSwapper struct {
+	cascade *Cascade
+	// MinQuality is minimal quality of detection which is treated as face
+	MinQuality float32
+	// MaxFrames is number of frames in processed animation, 0 keeps every frame
+	MaxFrames int
+	// MaxBytes is size budget of processed animation, it's scaled down to fit, 0 disables budget
+	MaxBytes int
+	// MaxPixels is width × height of image, multiplied by number of frames for animation, which is processed,
+	// 0 disables limit
+	MaxPixels int64
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// NewCascadeSwapper is a constructor function for cascade backend, it loads cascade in pico format from file
+func NewCascadeSwapper(cascadePath string) (*CascadeSwapper, error) {
+	data, err := os.ReadFile(cascadePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read cascade")
+	}
+	cascade, err := ParseCascade(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse cascade %s", cascadePath)
+	}
+
+	return &CascadeSwapper{
+		cascade:    cascade,
+		MinQuality: DefaultMinQuality,
+		MaxFrames:  DefaultMaxFrames,
+		MaxBytes:   DefaultUploadLimit,
+		MaxPixels:  DefaultMaxPixels,
+		rand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}, nil
+}
+
+// Swap pastes faces over detected ones, image without faces is written unchanged.
+// Every frame of GIF is processed
+func (cs *CascadeSwapper) Swap(ctx context.Context, input, output, faces string) error {
+	faceImages, err := loadFaces(faces)
+	if err != nil {
+		return err
+	}
+	if typ, err := contentType(input); err != nil {
+		return err
+	} else if typ == typeGIF {
+		return cs.swapAnimation(ctx, input, output, faceImages)
+	}
+
+	img, err := decodeFile(input, cs.MaxPixels)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	canvas := image.NewRGBA(img.Bounds())
+	draw.Draw(canvas, canvas.Bounds(), img, img.Bounds().Min, draw.Src)
+
+	for _, d := range cs.cascade.Detect(img) {
+		if d.Quality < cs.MinQuality {
+			continue
+		}
+
+		pasteFace(canvas, cs.pick(faceImages), d)
+	}
+
+	return encodeFile(output, canvas)
+}
+
+// pick returns random face
+func (cs *CascadeSwapper) pick(faces []image.Image) image.Image {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	return faces[cs.rand.Intn(len(faces))]
+}
+
+// pasteFace draws face scaled to width of detection and centered on it
+func pasteFace(dst draw.Image, face image.Image, d Detection) {
+	fb := face.Bounds()
+	width := int(float64(d.Scale) * faceScale)
+	height := width * fb.Dy() / fb.Dx()
+	if width == 0 || height == 0 {
+		return
+	}
+
+	min := dst.Bounds().Min.Add(image.Pt(d.Col-width/2, d.Row-height/2))
+	r := image.Rectangle{Min: min, Max: min.Add(image.Pt(width, height))}
+	draw.Draw(dst, r, resize(face, width, height), image.Point{}, draw.Over)
+}
+
+// resize scales image with nearest neighbour, it's enough for faces which are small anyway
+func resize(img image.Image, width, height int) *image.RGBA {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			dst.Set(x, y, img.At(b.Min.X+x*b.Dx()/width, b.Min.Y+y*b.Dy()/height))
+		}
+	}
+
+	return dst
+}
+
+// loadFaces decodes PNG and JPEG images from directory
+func loadFaces(dir string) ([]image.Image, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read faces dir")
+	}
+
+	var faces []image.Image
+	for _, e := range entries {
+		switch strings.ToLower(filepath.Ext(e.Name())) {
+		case ".png", ".jpg", ".jpeg":
+		default:
+			continue
+		}
+
+		face, err := decodeFile(filepath.Join(dir, e.Name()), 0)
+		if err != nil {
+			return nil, err
+		}
+		faces = append(faces, face)
+	}
+	if len(faces) == 0 {
+		return nil, errors.Errorf("no faces in %s", dir)
+	}
+
+	return faces, nil
+}
+
+// decodeFile decodes image, images with width × height above maxPixels are rejected before decoding, 0 disables limit
+func decodeFile(path string, maxPixels int64) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open image")
+	}
+	defer f.Close()
+
+	if err := checkPixels(f, filepath.Base(path), maxPixels); err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, errors.Wrap(err, "failed to read image")
+	}
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode image %s", filepath.Base(path))
+	}
+
+	return img, nil
+}
+
+// checkPixels reads header of image and rejects it when width × height is above maxPixels, 0 disables limit
+func checkPixels(r io.Reader, name string, maxPixels int64) error {
+	if maxPixels <= 0 {
+		return nil
+	}
+
+	config, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return errors.Wrapf(err, "failed to decode image %s", name)
+	}
+	if pixels := int64(config.Width) * int64(config.Height); pixels > maxPixels {
+		return errors.Wrapf(ErrImageTooLarge, "%dx%d image", config.Width, config.Height)
+	}
+
+	return nil
+}
+
+// encodeFile writes image in format of file extension, JPEG is used for unknown ones
+func encodeFile(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to create processed image")
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png":
+		err = png.Encode(f, img)
+	default:
+		err = jpeg.Encode(f, img, &jpeg.Options{Quality: jpegQuality})
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	return errors.Wrap(err, "failed to save processed image")
+}
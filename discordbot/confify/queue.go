@@ -0,0 +1,306 @@
+package confify
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/paulvasilenko/discordbot/discordbot/lifecycle"
+	"github.com/paulvasilenko/discordbot/discordbot/metrics"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultWorkers is number of images processed at the same time
+	DefaultWorkers = 2
+	// DefaultUserLimit is number of images one user may have queued or processed
+	DefaultUserLimit = 2
+	// DefaultQueueLimit is number of images waiting for free worker
+	DefaultQueueLimit = 20
+	// DefaultTimeout is time given to process one image
+	DefaultTimeout = 2 * time.Minute
+)
+
+var (
+	ErrQueueFull = errors.New("confify queue is full")
+	ErrUserLimit = errors.New("user limit of queued images is reached")
+	// ErrStopping is returned for new jobs and set to queued ones when bot is shutting down
+	ErrStopping = errors.New("bot is shutting down")
+)
+
+// State is a state of processing job
+type State int
+
+const (
+	StateQueued State = iota
+	StateProcessing
+	StateDone
+	StateFailed
+	StateCancelled
+)
+
+// Job is an image queued for processing
+type Job struct {
+	ID      int64
+	UserID  string
+	Image   string
+	Spoiler bool
+
+	// updates receives signal after every change of status, signals are coalesced
+	updates chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+	status  Status
+}
+
+// Status is a snapshot of job state
+type Status struct {
+	State State
+	// Position is 1-based position of queued job
+	Position int
+	// Result is name of processed file
+	Result string
+	Err    error
+}
+
+// Finished returns true when job won't change anymore
+func (s Status) Finished() bool {
+	return s.State >= StateDone
+}
+
+// Updates returns channel which receives signal when job status changes
+func (j *Job) Updates() <-chan struct{} {
+	return j.updates
+}
+
+func (j *Job) notify() {
+	select {
+	case j.updates <- struct{}{}:
+	default:
+	}
+}
+
+// ProcessFunc processes image of job and returns name of processed file
+type ProcessFunc func(ctx context.Context, job *Job) (string, error)
+
+// Queue runs jobs with limited number of workers, the rest of jobs wait in queue.
+// Workers are tracked by lifecycle, so shutdown waits for images being processed
+type Queue struct {
+	workers    int
+	userLimit  int
+	queueLimit int
+	timeout    time.Duration
+
+	mu        sync.Mutex
+	nextID    int64
+	running   []*Job
+	pending   []*Job
+	process   ProcessFunc
+	lifecycle *lifecycle.Manager
+}
+
+// NewQueue is a constructor function for queue, limits and timeout are not applied when they are 0
+func NewQueue(workers, userLimit, queueLimit int, timeout time.Duration) *Queue {
+	if workers <= 0 {
+		workers = 1
+	}
+
+	return &Queue{
+		workers:    workers,
+		userLimit:  userLimit,
+		queueLimit: queueLimit,
+		timeout:    timeout,
+		lifecycle:  lifecycle.NewManager(),
+	}
+}
+
+// Add queues image, it's processed right away when there is free worker
+func (q *Queue) Add(userID, image string, spoiler bool) (*Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.lifecycle.Stopping() {
+		return nil, ErrStopping
+	}
+	if q.userLimit > 0 && len(q.userJobs(userID)) >= q.userLimit {
+		return nil, ErrUserLimit
+	}
+	// New job waits behind queued ones even when worker is about to take next of them
+	busy := len(q.running) >= q.workers || len(q.pending) > 0
+	if busy && q.queueLimit > 0 && len(q.pending) >= q.queueLimit {
+		return nil, ErrQueueFull
+	}
+
+	q.nextID++
+	job := &Job{
+		ID:      q.nextID,
+		UserID:  userID,
+		Image:   image,
+		Spoiler: spoiler,
+		updates: make(chan struct{}, 1),
+	}
+
+	if busy {
+		q.pending = append(q.pending, job)
+		job.status = Status{State: StateQueued, Position: len(q.pending)}
+	} else {
+		q.start(job)
+		if !q.lifecycle.Go(func() { q.work(job) }) {
+			q.running = q.running[:len(q.running)-1]
+			job.cancel()
+			return nil, ErrStopping
+		}
+	}
+	metrics.ConfifyQueue.Inc()
+	job.notify()
+
+	return job, nil
+}
+
+// Status returns current status of job
+func (q *Queue) Status(job *Job) Status {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return job.status
+}
+
+// Cancel cancels last added job of user which is not finished yet, processing is interrupted through context
+func (q *Queue) Cancel(userID string) (*Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	jobs := q.userJobs(userID)
+	if len(jobs) == 0 {
+		return nil, false
+	}
+	job := jobs[len(jobs)-1]
+
+	if job.status.State == StateQueued {
+		q.removePending(job)
+		metrics.ConfifyQueue.Dec()
+	} else {
+		// Worker removes job from running ones when processing returns
+		job.cancel()
+	}
+	job.status = Status{State: StateCancelled}
+	job.notify()
+
+	return job, true
+}
+
+// Len returns number of running and queued jobs
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.running) + len(q.pending)
+}
+
+// work processes job and then next queued ones until queue is empty
+func (q *Queue) work(job *Job) {
+	for job != nil {
+		job = q.run(job)
+	}
+}
+
+// run processes job and returns next queued one which is started in the same critical section,
+// so new jobs can't take the worker in between
+func (q *Queue) run(job *Job) *Job {
+	q.mu.Lock()
+	ctx, process := job.ctx, q.process
+	q.mu.Unlock()
+
+	result, err := process(ctx, job)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job.cancel()
+	for i, j := range q.running {
+		if j == job {
+			q.running = append(q.running[:i], q.running[i+1:]...)
+			break
+		}
+	}
+	metrics.ConfifyQueue.Dec()
+
+	// Result of cancelled job is dropped
+	if job.status.State != StateCancelled {
+		if err != nil {
+			job.status = Status{State: StateFailed, Err: err}
+		} else {
+			job.status = Status{State: StateDone, Result: result}
+		}
+		job.notify()
+	}
+
+	return q.next()
+}
+
+// next starts first queued job, queued jobs are cancelled when bot is shutting down.
+// It must be called with lock held
+func (q *Queue) next() *Job {
+	if len(q.pending) == 0 {
+		return nil
+	}
+
+	if q.lifecycle.Stopping() {
+		for _, job := range q.pending {
+			job.status = Status{State: StateCancelled, Err: ErrStopping}
+			job.notify()
+			metrics.ConfifyQueue.Dec()
+		}
+		q.pending = nil
+		return nil
+	}
+
+	job := q.pending[0]
+	q.removePending(job)
+	q.start(job)
+	job.notify()
+
+	return job
+}
+
+// start marks job as running, it must be called with lock held
+func (q *Queue) start(job *Job) {
+	if q.timeout > 0 {
+		job.ctx, job.cancel = context.WithTimeout(context.Background(), q.timeout)
+	} else {
+		job.ctx, job.cancel = context.WithCancel(context.Background())
+	}
+	job.status = Status{State: StateProcessing}
+	q.running = append(q.running, job)
+}
+
+// removePending removes job from queue and updates positions of the next ones, it must be called with lock held
+func (q *Queue) removePending(job *Job) {
+	for i, j := range q.pending {
+		if j != job {
+			continue
+		}
+
+		q.pending = append(q.pending[:i], q.pending[i+1:]...)
+		for k := i; k < len(q.pending); k++ {
+			q.pending[k].status.Position = k + 1
+			q.pending[k].notify()
+		}
+		return
+	}
+}
+
+// userJobs returns running and queued jobs of user which are not cancelled, in order they were added.
+// It must be called with lock held
+func (q *Queue) userJobs(userID string) []*Job {
+	var jobs []*Job
+	for _, list := range [][]*Job{q.running, q.pending} {
+		for _, job := range list {
+			if job.UserID == userID && job.status.State != StateCancelled {
+				jobs = append(jobs, job)
+			}
+		}
+	}
+
+	return jobs
+}
@@ -0,0 +1,170 @@
+package confify
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitState waits until job gets into state
+func waitState(t *testing.T, q *Queue, job *Job, state State) Status {
+	t.Helper()
+
+	timeout := time.After(time.Second)
+	for {
+		if status := q.Status(job); status.State == state {
+			return status
+		}
+		select {
+		case <-job.Updates():
+		case <-timeout:
+			t.Fatalf("job #%d: expected state %d, actual %+v", job.ID, state, q.Status(job))
+		}
+	}
+}
+
+func Test_Queue(t *testing.T) {
+	q := NewQueue(1, 1, 1, 0)
+	release := make(chan struct{})
+	q.process = func(ctx context.Context, job *Job) (string, error) {
+		select {
+		case <-release:
+			return job.Image + ".png", nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+
+	first, err := q.Add("10", "first", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitState(t, q, first, StateProcessing)
+
+	second, err := q.Add("20", "second", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := q.Status(second); status.State != StateQueued || status.Position != 1 {
+		t.Errorf("expected second job to be #1 in queue, actual %+v", status)
+	}
+	if _, err := q.Add("20", "third", false); err != ErrUserLimit {
+		t.Errorf("expected user limit error, actual %v", err)
+	}
+	if _, err := q.Add("30", "third", false); err != ErrQueueFull {
+		t.Errorf("expected queue full error, actual %v", err)
+	}
+
+	if job, ok := q.Cancel("20"); !ok || job != second {
+		t.Fatalf("expected second job to be cancelled, actual %v", job)
+	}
+	waitState(t, q, second, StateCancelled)
+	if _, ok := q.Cancel("20"); ok {
+		t.Error("expected nothing to cancel")
+	}
+
+	third, err := q.Add("30", "third", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	release <- struct{}{}
+	if status := waitState(t, q, first, StateDone); status.Result != "first.png" {
+		t.Errorf("expected first.png, actual %q", status.Result)
+	}
+
+	// Running job is interrupted through context
+	waitState(t, q, third, StateProcessing)
+	if _, ok := q.Cancel("30"); !ok {
+		t.Fatal("expected third job to be cancelled")
+	}
+	waitState(t, q, third, StateCancelled)
+
+	for i := 0; q.Len() > 0; i++ {
+		if i > 100 {
+			t.Fatalf("expected queue to be empty, %d jobs left", q.Len())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func Test_QueueOrder(t *testing.T) {
+	const rounds, workers, adders, perAdder = 10, 2, 8, 200
+
+	// Workers and adders must run in parallel to race for free worker
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(8))
+
+	for round := 0; round < rounds && !t.Failed(); round++ {
+		q := NewQueue(workers, 0, 0, 0)
+		var (
+			mu        sync.Mutex
+			active    int
+			max       int
+			processed int
+			overtaken []string
+		)
+		q.process = func(ctx context.Context, job *Job) (string, error) {
+			// Job doesn't overtake jobs which were queued before it
+			q.mu.Lock()
+			for _, pending := range q.pending {
+				if pending.ID < job.ID {
+					overtaken = append(overtaken, fmt.Sprintf("#%d by #%d", pending.ID, job.ID))
+				}
+			}
+			q.mu.Unlock()
+
+			mu.Lock()
+			active++
+			if active > max {
+				max = active
+			}
+			processed++
+			mu.Unlock()
+
+			time.Sleep(time.Microsecond)
+
+			mu.Lock()
+			active--
+			mu.Unlock()
+			return job.Image, nil
+		}
+
+		// Jobs are added while workers finish previous ones and take queued ones
+		var wg sync.WaitGroup
+		jobs := make(chan *Job, adders*perAdder)
+		for a := 0; a < adders; a++ {
+			wg.Add(1)
+			go func(a int) {
+				defer wg.Done()
+				for i := 0; i < perAdder; i++ {
+					job, err := q.Add(fmt.Sprint(a), fmt.Sprint(i), false)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					jobs <- job
+				}
+			}(a)
+		}
+		wg.Wait()
+		close(jobs)
+		for job := range jobs {
+			waitState(t, q, job, StateDone)
+		}
+
+		mu.Lock()
+		if max > workers {
+			t.Errorf("expected at most %d jobs processed at the same time, actual %d", workers, max)
+		}
+		if processed != adders*perAdder {
+			t.Errorf("expected %d jobs to be processed, actual %d", adders*perAdder, processed)
+		}
+		if len(overtaken) > 0 {
+			t.Errorf("expected jobs to be processed in order, %d overtaken, e.g. %s", len(overtaken), overtaken[0])
+		}
+		mu.Unlock()
+	}
+}
@@ -0,0 +1,325 @@
+package confify
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/paulvasilenko/discordbot/discordbot/metrics"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// KindOriginal is downloaded image, it's deleted right after processing
+	KindOriginal = "original"
+	// KindOutput is processed image, it's deleted after TTL or evicted when storage is full
+	KindOutput = "output"
+
+	// DefaultTTL is time processed images are kept
+	DefaultTTL = 7 * 24 * time.Hour
+	// DefaultMaxSize is total size of storage, least recently used processed images are evicted above it
+	DefaultMaxSize = 1 << 30
+
+	// indexName is file of index in storage directory, it's not served by file server
+	indexName = ".index.json"
+	// staleOriginal is age of originals left by interrupted processing
+	staleOriginal = time.Hour
+)
+
+// Artifact is a file of confify in storage directory
+type Artifact struct {
+	Name     string    `json:"name"`
+	Kind     string    `json:"kind"`
+	Size     int64     `json:"size"`
+	Created  time.Time `json:"created"`
+	Accessed time.Time `json:"accessed"`
+}
+
+// Usage is summary of storage
+type Usage struct {
+	Originals int
+	Outputs   int
+	Size      int64
+	MaxSize   int64
+	TTL       time.Duration
+}
+
+// Store keeps index of confify files in storage directory. Expired processed images are deleted by Cleanup,
+// least recently used ones are evicted when total size exceeds limit. Files missing in index are never deleted
+type Store struct {
+	dir string
+	now func() time.Time
+
+	mu        sync.Mutex
+	ttl       time.Duration
+	maxSize   int64
+	loaded    bool
+	artifacts map[string]*Artifact
+}
+
+// NewStore is a constructor function for storage of directory, index is loaded on first use
+func NewStore(dir string) *Store {
+	return &Store{
+		dir:       dir,
+		now:       time.Now,
+		ttl:       DefaultTTL,
+		maxSize:   DefaultMaxSize,
+		artifacts: map[string]*Artifact{},
+	}
+}
+
+// SetRetention sets TTL of processed images and maximal total size of storage, 0 disables them
+func (s *Store) SetRetention(ttl time.Duration, maxSize int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ttl, s.maxSize = ttl, maxSize
+}
+
+// Retained returns true if processed images are deleted by TTL or size of storage
+func (s *Store) Retained() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.ttl > 0 || s.maxSize > 0
+}
+
+// Path returns path of file in storage
+func (s *Store) Path(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+// NewName returns unique name of file with extension
+func (s *Store) NewName(ext string) string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand doesn't fail on supported platforms, time keeps name unique anyway
+		return s.now().Format("20060102150405.000000000") + ext
+	}
+
+	return hex.EncodeToString(b) + ext
+}
+
+// Add puts written file into index. Least recently used processed images are evicted
+// when storage exceeds maximal size, added file is kept
+func (s *Store) Add(name, kind string) error {
+	info, err := os.Stat(s.Path(name))
+	if err != nil {
+		return errors.Wrap(err, "failed to add file to storage")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+	now := s.now()
+	s.artifacts[name] = &Artifact{Name: name, Kind: kind, Size: info.Size(), Created: now, Accessed: now}
+	s.evict(name)
+
+	return s.save()
+}
+
+// Remove deletes file and its index entry
+func (s *Store) Remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+	if err := s.remove(name); err != nil {
+		return err
+	}
+
+	return s.save()
+}
+
+// Touch marks file as used, so it's evicted after files which were not used since.
+// It's called for every served file, so time of use is saved to index with the next change or cleanup
+func (s *Store) Touch(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		log.Println("failed to load confify storage index: ", err)
+		return
+	}
+	if a, ok := s.artifacts[name]; ok {
+		a.Accessed = s.now()
+	}
+}
+
+// Cleanup deletes expired processed images and originals left by interrupted processing,
+// then evicts processed images above maximal size. It returns number of deleted files
+func (s *Store) Cleanup() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return 0, err
+	}
+
+	now := s.now()
+	var n int
+	for name, a := range s.artifacts {
+		age := now.Sub(a.Created)
+		if (a.Kind == KindOutput && s.ttl > 0 && age > s.ttl) || (a.Kind == KindOriginal && age > staleOriginal) {
+			if err := s.remove(name); err != nil {
+				log.Println(err)
+				continue
+			}
+			n++
+		}
+	}
+	n += s.evict("")
+
+	return n, s.save()
+}
+
+// Usage returns number and total size of files in storage
+func (s *Store) Usage() (Usage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return Usage{}, err
+	}
+
+	u := Usage{MaxSize: s.maxSize, TTL: s.ttl}
+	for _, a := range s.artifacts {
+		if a.Kind == KindOriginal {
+			u.Originals++
+		} else {
+			u.Outputs++
+		}
+		u.Size += a.Size
+	}
+
+	return u, nil
+}
+
+// FileServer serves files of storage and marks them as used. Dotfiles, e.g. index and its temporary file, are hidden
+func (s *Store) FileServer() http.Handler {
+	fs := http.FileServer(http.Dir(s.dir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, part := range strings.Split(r.URL.Path, "/") {
+			if strings.HasPrefix(part, ".") {
+				http.NotFound(w, r)
+				return
+			}
+		}
+
+		// Only files in index are marked
+		s.Touch(path.Base(r.URL.Path))
+		fs.ServeHTTP(w, r)
+	})
+}
+
+// evict deletes least recently used processed images until storage fits maximal size, keep is never deleted.
+// It returns number of deleted files
+func (s *Store) evict(keep string) int {
+	if s.maxSize <= 0 {
+		return 0
+	}
+
+	var size int64
+	var outputs []*Artifact
+	for _, a := range s.artifacts {
+		size += a.Size
+		if a.Kind == KindOutput && a.Name != keep {
+			outputs = append(outputs, a)
+		}
+	}
+	sort.Slice(outputs, func(i, j int) bool {
+		return outputs[i].Accessed.Before(outputs[j].Accessed)
+	})
+
+	var n int
+	for _, a := range outputs {
+		if size <= s.maxSize {
+			break
+		}
+		if err := s.remove(a.Name); err != nil {
+			log.Println(err)
+			continue
+		}
+		size -= a.Size
+		n++
+	}
+
+	return n
+}
+
+func (s *Store) remove(name string) error {
+	if err := os.Remove(s.Path(name)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "failed to delete %s from storage", name)
+	}
+	delete(s.artifacts, name)
+
+	return nil
+}
+
+// load reads index once, entries of files deleted while bot was stopped are dropped
+func (s *Store) load() error {
+	if s.loaded {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.Path(indexName))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to read confify storage index")
+	}
+	if err == nil {
+		var artifacts []*Artifact
+		if err := json.Unmarshal(data, &artifacts); err != nil {
+			return errors.Wrap(err, "failed to parse confify storage index")
+		}
+		for _, a := range artifacts {
+			if _, err := os.Stat(s.Path(a.Name)); err == nil {
+				s.artifacts[a.Name] = a
+			}
+		}
+	}
+	s.loaded = true
+
+	return nil
+}
+
+// save writes index to temporary file and renames it, so index is not corrupted by crash
+func (s *Store) save() error {
+	artifacts := make([]*Artifact, 0, len(s.artifacts))
+	var size int64
+	for _, a := range s.artifacts {
+		artifacts = append(artifacts, a)
+		size += a.Size
+	}
+	sort.Slice(artifacts, func(i, j int) bool {
+		return artifacts[i].Created.Before(artifacts[j].Created)
+	})
+	metrics.ConfifyStorage.Set(float64(size))
+
+	data, err := json.Marshal(artifacts)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode confify storage index")
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return errors.Wrap(err, "failed to create confify storage")
+	}
+	tmp := s.Path(indexName + ".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return errors.Wrap(err, "failed to write confify storage index")
+	}
+
+	return errors.Wrap(os.Rename(tmp, s.Path(indexName)), "failed to write confify storage index")
+}
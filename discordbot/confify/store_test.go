@@ -0,0 +1,96 @@
+package confify
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func Test_Store(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+	s := NewStore(dir)
+	s.now = func() time.Time { return now }
+	s.SetRetention(time.Hour, 10)
+
+	add := func(name, kind string, size int) {
+		t.Helper()
+		if err := os.WriteFile(s.Path(name), make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Add(name, kind); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(time.Minute)
+	}
+	exists := func(name string) bool {
+		_, err := os.Stat(s.Path(name))
+		return err == nil
+	}
+
+	if err := os.WriteFile(s.Path("untracked.png"), make([]byte, 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+	add("old.png", KindOutput, 4)
+	add("used.png", KindOutput, 4)
+	index, err := os.ReadFile(s.Path(indexName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Touch("old.png")
+	if touched, err := os.ReadFile(s.Path(indexName)); err != nil || string(touched) != string(index) {
+		t.Errorf("expected index not to be written on use, actual error %v", err)
+	}
+	// Least recently used output is evicted when storage exceeds 10 bytes
+	add("new.png", KindOutput, 4)
+	if exists("used.png") || !exists("old.png") || !exists("new.png") {
+		t.Error("expected least recently used image to be evicted")
+	}
+
+	// Index is loaded by new store
+	s = NewStore(dir)
+	s.now = func() time.Time { return now }
+	s.SetRetention(time.Hour, 10)
+	add("original.png", KindOriginal, 1)
+	if u, err := s.Usage(); err != nil {
+		t.Fatal(err)
+	} else if u.Outputs != 2 || u.Originals != 1 || u.Size != 9 {
+		t.Errorf("expected 2 outputs and 1 original of 9 bytes, actual %+v", u)
+	}
+
+	now = now.Add(time.Hour - 2*time.Minute)
+	if n, err := s.Cleanup(); err != nil {
+		t.Fatal(err)
+	} else if n != 1 || exists("old.png") || !exists("new.png") {
+		t.Errorf("expected only expired image to be deleted, actual %d", n)
+	}
+	now = now.Add(time.Hour)
+	if n, err := s.Cleanup(); err != nil {
+		t.Fatal(err)
+	} else if n != 2 || exists("new.png") || exists("original.png") {
+		t.Errorf("expected expired image and stale original to be deleted, actual %d", n)
+	}
+	if !exists("untracked.png") {
+		t.Error("expected untracked file to be kept")
+	}
+
+	// Temporary file of index exists while index is written
+	if err := os.WriteFile(s.Path(indexName+".tmp"), []byte("[]"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{indexName, indexName + ".tmp", "../" + indexName} {
+		rec := httptest.NewRecorder()
+		s.FileServer().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/"+name, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("expected %s to be hidden, actual status %d", name, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	s.FileServer().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/untracked.png", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected image to be served, actual status %d", rec.Code)
+	}
+}
@@ -0,0 +1,99 @@
+package confify
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	BackendChrisify = "chrisify"
+	BackendCascade  = "cascade"
+	BackendStub     = "stub"
+
+	// DefaultChrisifyTimeout is time given to one run of chrisify
+	DefaultChrisifyTimeout = time.Minute
+	// maxStderrLength keeps chrisify errors short in logs and reports
+	maxStderrLength = 512
+)
+
+// FaceSwapper replaces faces on input image with faces from directory and writes result to output file
+type FaceSwapper interface {
+	Swap(ctx context.Context, input, output, faces string) error
+}
+
+// Chrisify runs external chrisify binary, processed image is read from its stdout.
+// It doesn't process animations, GIF is converted to PNG of its first frame
+type Chrisify struct {
+	// Path of binary, found in PATH if it's not absolute
+	Path    string
+	Timeout time.Duration
+}
+
+// NewChrisify is a constructor function for chrisify backend
+func NewChrisify() *Chrisify {
+	return &Chrisify{Path: "chrisify", Timeout: DefaultChrisifyTimeout}
+}
+
+// Swap runs chrisify, output file is written only when it exits successfully
+func (c *Chrisify) Swap(ctx context.Context, input, output, faces string) error {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.Path, "--faces", faces, input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return errors.Wrap(ctx.Err(), "chrisify is interrupted")
+		}
+		return errors.Wrapf(err, "chrisify failed: %s", truncate(strings.TrimSpace(stderr.String()), maxStderrLength))
+	}
+	if stdout.Len() == 0 {
+		return errors.Errorf("chrisify returned empty image: %s", truncate(strings.TrimSpace(stderr.String()), maxStderrLength))
+	}
+
+	return errors.Wrap(os.WriteFile(output, stdout.Bytes(), 0644), "failed to save processed image")
+}
+
+// StubSwapper copies input image to output as is, it's used in tests and on hosts without face swapping
+type StubSwapper struct {
+	// Err is returned instead of processing when it's set
+	Err error
+}
+
+// Animated returns true, animations are copied as well
+func (s *StubSwapper) Animated() bool {
+	return true
+}
+
+func (s *StubSwapper) Swap(ctx context.Context, input, output, faces string) error {
+	if s.Err != nil {
+		return s.Err
+	}
+
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return errors.Wrap(err, "failed to read image")
+	}
+
+	return errors.Wrap(os.WriteFile(output, data, 0644), "failed to save processed image")
+}
+
+func truncate(s string, length int) string {
+	if runes := []rune(s); len(runes) > length {
+		return string(runes[:length-1]) + "…"
+	}
+
+	return s
+}
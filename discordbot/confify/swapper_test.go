@@ -0,0 +1,128 @@
+package confify
+
+import (
+	"context"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testCascade is a single tree which finds light region above dark one
+func testCascade() []byte {
+	data := make([]byte, 8)
+	data = binary.LittleEndian.AppendUint32(data, 1)
+	data = binary.LittleEndian.AppendUint32(data, 1)
+	// Compares pixel at quarter of region above center with pixel at quarter below it
+	data = append(data, 0xc0, 0, 64, 0)
+	for _, f := range []float32{1, -1, 0} {
+		data = binary.LittleEndian.AppendUint32(data, math.Float32bits(f))
+	}
+
+	return data
+}
+
+func Test_CascadeSwapper(t *testing.T) {
+	dir := t.TempDir()
+	cascadePath := filepath.Join(dir, "cascade")
+	if err := os.WriteFile(cascadePath, testCascade(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	gray := color.RGBA{R: 128, G: 128, B: 128, A: 255}
+	img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	draw.Draw(img, img.Bounds(), image.NewUniform(gray), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(30, 30, 70, 50), image.White, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(30, 50, 70, 70), image.Black, image.Point{}, draw.Src)
+	input := filepath.Join(dir, "input.png")
+	if err := encodeFile(input, img); err != nil {
+		t.Fatal(err)
+	}
+
+	faces := filepath.Join(dir, "faces")
+	if err := os.Mkdir(faces, 0755); err != nil {
+		t.Fatal(err)
+	}
+	red := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	draw.Draw(red, red.Bounds(), image.NewUniform(color.RGBA{R: 255, A: 255}), image.Point{}, draw.Src)
+	if err := encodeFile(filepath.Join(faces, "red.png"), red); err != nil {
+		t.Fatal(err)
+	}
+
+	swapper, err := NewCascadeSwapper(cascadePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "output.png")
+	if err := swapper.Swap(context.Background(), input, output, faces); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := decodeFile(output, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, g, b, _ := result.At(50, 50).RGBA(); r>>8 != 255 || g != 0 || b != 0 {
+		t.Errorf("expected face to be pasted at center, actual %v", result.At(50, 50))
+	}
+	if c := color.RGBAModel.Convert(result.At(2, 2)); c != gray {
+		t.Errorf("expected corner to be unchanged, actual %v", c)
+	}
+}
+
+func Test_ParseCascade(t *testing.T) {
+	if _, err := ParseCascade(testCascade()[:20]); err == nil {
+		t.Error("expected error for truncated cascade")
+	}
+	if _, err := ParseCascade(testCascade()); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_Chrisify(t *testing.T) {
+	dir := t.TempDir()
+	testCases := []struct {
+		name     string
+		script   string
+		expected string
+		err      string
+	}{
+		{name: "success", script: "echo image; echo warning >&2", expected: "image\n"},
+		{name: "failure", script: "echo partial; echo no faces found >&2; exit 1", err: "no faces found"},
+		{name: "empty", script: "true", err: "empty image"},
+	}
+
+	for _, tc := range testCases {
+		path := filepath.Join(dir, tc.name+".sh")
+		if err := os.WriteFile(path, []byte("#!/bin/sh\n"+tc.script+"\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		output := filepath.Join(dir, tc.name+".png")
+
+		c := NewChrisify()
+		c.Path = path
+		err := c.Swap(context.Background(), "input.png", output, "faces")
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%s: expected error %q, actual %v", tc.name, tc.err, err)
+			}
+			if _, statErr := os.Stat(output); !os.IsNotExist(statErr) {
+				t.Errorf("%s: expected output not to be written", tc.name)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if actual, _ := os.ReadFile(output); string(actual) != tc.expected {
+			t.Errorf("%s: expected %q, actual %q", tc.name, tc.expected, actual)
+		}
+	}
+}
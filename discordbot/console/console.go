@@ -0,0 +1,224 @@
+// Package console runs plugins with fake discord session, lines read from input
+// are messages of fake user and replies of bot are printed to output
+package console
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/paulvasilenko/discordbot/discordbot/discord"
+	"github.com/paulvasilenko/discordbot/discordbot/discord/discordtest"
+	"github.com/pkg/errors"
+)
+
+const (
+	CommandReact = "/react"
+	CommandHelp  = "/help"
+	CommandQuit  = "/quit"
+)
+
+var customEmojiRegex = regexp.MustCompile(`^<a?:([^:>]+):(\d+)>$`)
+
+// Handler handles gateway event, plugin.Registry is a handler of all plugins
+type Handler interface {
+	Handle(s discord.Session, event interface{})
+}
+
+// Console passes messages and reactions of fake user to handler
+type Console struct {
+	handler   Handler
+	session   *discordtest.Session
+	user      *discordgo.User
+	channelID string
+	// filesDir is a directory where files sent by bot are saved
+	filesDir string
+
+	// mu guards output, messages are printed by handlers and their background work
+	mu  sync.Mutex
+	out io.Writer
+
+	lastMessageID string
+}
+
+// New is a constructor function for console, user is an administrator of fake guild
+func New(h Handler, user *discordgo.User, channelID, filesDir string) *Console {
+	s := discordtest.NewSession()
+	s.Users[user.ID] = user
+	s.Members[user.ID] = &discordgo.Member{GuildID: discordtest.GuildID, User: user}
+	s.Permissions[user.ID] = discordgo.PermissionAdministrator
+
+	c := &Console{
+		handler:   h,
+		session:   s,
+		user:      user,
+		channelID: channelID,
+		filesDir:  filesDir,
+		out:       io.Discard,
+	}
+	s.OnMessage = c.printMessage
+
+	return c
+}
+
+// Run reads input line by line until it ends or /quit is entered
+func (c *Console) Run(in io.Reader, out io.Writer) error {
+	c.mu.Lock()
+	c.out = out
+	c.mu.Unlock()
+
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		switch fields[0] {
+		case CommandQuit:
+			return nil
+		case CommandHelp:
+			c.printf("Type messages as %s. %s <emoji> [message id] adds reaction to your last message or message with id, %s exits\n",
+				c.user.Username, CommandReact, CommandQuit)
+		case CommandReact:
+			if err := c.react(fields[1:]); err != nil {
+				c.printf("%v\n", err)
+			}
+		default:
+			c.message(line)
+		}
+	}
+
+	return scanner.Err()
+}
+
+func (c *Console) message(content string) {
+	m := c.session.AddMessage(&discordgo.Message{
+		ChannelID: c.channelID,
+		GuildID:   discordtest.GuildID,
+		Author:    c.user,
+		Member:    &discordgo.Member{GuildID: discordtest.GuildID, User: c.user},
+		Content:   content,
+	})
+	c.lastMessageID = m.ID
+
+	c.handle(&discordgo.MessageCreate{Message: m})
+}
+
+func (c *Console) react(args []string) error {
+	if len(args) == 0 {
+		return errors.Errorf("usage: %s <emoji> [message id]", CommandReact)
+	}
+
+	messageID := c.lastMessageID
+	if len(args) > 1 {
+		messageID = args[1]
+	}
+	if messageID == "" {
+		return errors.New("there is no message to react to")
+	}
+	if _, err := c.session.ChannelMessage(c.channelID, messageID); err != nil {
+		return err
+	}
+
+	emoji := discordgo.Emoji{Name: args[0]}
+	if match := customEmojiRegex.FindStringSubmatch(args[0]); match != nil {
+		emoji = discordgo.Emoji{Name: match[1], ID: match[2]}
+	}
+
+	c.handle(&discordgo.MessageReactionAdd{
+		MessageReaction: &discordgo.MessageReaction{
+			UserID:    c.user.ID,
+			MessageID: messageID,
+			ChannelID: c.channelID,
+			GuildID:   discordtest.GuildID,
+			Emoji:     emoji,
+		},
+		Member: &discordgo.Member{GuildID: discordtest.GuildID, User: c.user},
+	})
+
+	return nil
+}
+
+// handle passes event to handler, then prints reactions of bot and saves sent files
+func (c *Console) handle(event interface{}) {
+	c.handler.Handle(c.session, event)
+
+	sent, reactions := c.session.Flush()
+	for _, r := range reactions {
+		c.printf("bot reacted %s to [%s]\n", r.EmojiID, r.MessageID)
+	}
+	for _, m := range sent {
+		for _, a := range m.Attachments {
+			path, err := c.saveFile(m.ID, a.Filename)
+			if err != nil {
+				c.printf("failed to save file %s: %v\n", a.Filename, err)
+				continue
+			}
+			c.printf("file %s is saved to %s\n", a.Filename, path)
+		}
+	}
+}
+
+func (c *Console) saveFile(messageID, name string) (string, error) {
+	data, ok := c.session.File(messageID, name)
+	if !ok {
+		return "", errors.New("file not found")
+	}
+	if err := os.MkdirAll(c.filesDir, 0755); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(c.filesDir, messageID+"-"+filepath.Base(name))
+	return path, os.WriteFile(path, data, 0644)
+}
+
+// printMessage prints sent or edited message, it's called by session
+func (c *Console) printMessage(m *discordgo.Message, edited bool) {
+	prefix := fmt.Sprintf("bot [%s]", m.ID)
+	if edited {
+		prefix += " edited"
+	}
+
+	lines := []string{prefix + ": " + m.Content}
+	for _, e := range m.Embeds {
+		lines = append(lines, embedLines(e)...)
+	}
+	for _, a := range m.Attachments {
+		lines = append(lines, fmt.Sprintf("  [file %s, %d bytes]", a.Filename, a.Size))
+	}
+
+	c.printf("%s\n", strings.Join(lines, "\n"))
+}
+
+func embedLines(e *discordgo.MessageEmbed) []string {
+	lines := []string{"  [embed] " + e.Title}
+	if e.Description != "" {
+		lines = append(lines, "  "+e.Description)
+	}
+	for _, f := range e.Fields {
+		lines = append(lines, fmt.Sprintf("  %s: %s", f.Name, f.Value))
+	}
+	if e.Image != nil {
+		lines = append(lines, "  "+e.Image.URL)
+	}
+	if e.Footer != nil {
+		lines = append(lines, "  "+e.Footer.Text)
+	}
+
+	return lines
+}
+
+func (c *Console) printf(format string, args ...interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fmt.Fprintf(c.out, format, args...)
+}
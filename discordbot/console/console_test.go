@@ -0,0 +1,89 @@
+package console
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/paulvasilenko/discordbot/discordbot/discord"
+)
+
+type handlerFunc func(s discord.Session, event interface{})
+
+func (f handlerFunc) Handle(s discord.Session, event interface{}) {
+	f(s, event)
+}
+
+// echo repeats messages, edits !edit replies and sends emoji of reaction as file
+func echo(s discord.Session, event interface{}) {
+	switch e := event.(type) {
+	case *discordgo.MessageCreate:
+		m, _ := s.ChannelMessageSend(e.ChannelID, e.Author.Username+" said "+e.Content)
+		if e.Content == "!edit" {
+			s.ChannelMessageEdit(m.ChannelID, m.ID, "edited")
+		}
+	case *discordgo.MessageReactionAdd:
+		s.ChannelMessageSendComplex(e.ChannelID, &discordgo.MessageSend{
+			Files: []*discordgo.File{{Name: "emoji.txt", Reader: strings.NewReader(e.Emoji.Name + e.Emoji.ID)}},
+		})
+	}
+}
+
+func Test_Run(t *testing.T) {
+	dir := t.TempDir()
+
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+		file     string
+	}{
+		{
+			name:     "message",
+			input:    "hello\n\n",
+			expected: "bot [2]: panda said hello\n",
+		},
+		{
+			name:     "edit",
+			input:    "!edit",
+			expected: "bot [2]: panda said !edit\nbot [2] edited: edited\n",
+		},
+		{
+			name:     "reaction",
+			input:    "hello\n/react <:kappa:10>",
+			expected: "bot [2]: panda said hello\nbot [3]: \n  [file emoji.txt, 7 bytes]\nfile emoji.txt is saved to " + filepath.Join(dir, "3-emoji.txt") + "\n",
+			file:     "kappa10",
+		},
+		{
+			name:     "reaction without message",
+			input:    "/react 🔈",
+			expected: "there is no message to react to\n",
+		},
+		{
+			name:     "quit",
+			input:    "/quit\nhello",
+			expected: "",
+		},
+	}
+
+	for _, v := range testCases {
+		c := New(handlerFunc(echo), &discordgo.User{ID: "10", Username: "panda"}, "console", dir)
+		out := &bytes.Buffer{}
+		if err := c.Run(strings.NewReader(v.input), out); err != nil {
+			t.Fatal(err)
+		}
+
+		if out.String() != v.expected {
+			t.Errorf("%s: expected %q, actual %q", v.name, v.expected, out.String())
+		}
+		if v.file == "" {
+			continue
+		}
+		if data, err := os.ReadFile(filepath.Join(dir, "3-emoji.txt")); err != nil || string(data) != v.file {
+			t.Errorf("%s: expected file with %q, actual %q, %v", v.name, v.file, data, err)
+		}
+	}
+}
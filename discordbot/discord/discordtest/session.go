@@ -0,0 +1,418 @@
+// Package discordtest provides in-memory discord session which records outgoing messages,
+// so plugin commands can be tested without connection to discord
+package discordtest
+
+import (
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/paulvasilenko/discordbot/discordbot/discord"
+	"github.com/pkg/errors"
+)
+
+const (
+	// GuildID is guild of messages built by Message
+	GuildID = "guild"
+	// BotID is id of user sending messages of fake session
+	BotID = "bot"
+)
+
+var _ discord.Session = &Session{}
+
+// Reaction is a reaction added by bot to message
+type Reaction struct {
+	ChannelID string
+	MessageID string
+	EmojiID   string
+}
+
+// Session is a fake discord session. Channels, users, members, permissions and roles
+// are set by test, messages sent through session are added to channels and recorded
+type Session struct {
+	// Channels are messages of channels by channel id in order they were sent
+	Channels map[string][]*discordgo.Message
+	// Users are users by id
+	Users map[string]*discordgo.User
+	// Members are guild members by user id
+	Members map[string]*discordgo.Member
+	// Permissions are channel permissions of users by user id
+	Permissions map[string]int64
+	// Roles are guild roles by role id
+	Roles map[string]*discordgo.Role
+
+	// Sent are messages sent by bot including interaction responses
+	Sent []*discordgo.Message
+	// Edits are messages edited by bot, every edit is recorded separately
+	Edits []*discordgo.Message
+	// Reactions are reactions added by bot
+	Reactions []Reaction
+	// Responses are responses to interactions
+	Responses []*discordgo.InteractionResponse
+
+	// OnMessage is called with copy of every sent or edited message if it's set,
+	// session is locked during the call, so it must not call session methods
+	OnMessage func(m *discordgo.Message, edited bool)
+
+	mu        sync.Mutex
+	lastID    int
+	files     map[string][]byte
+	responses map[string]*discordgo.Message
+}
+
+// NewSession is a constructor function for fake session
+func NewSession() *Session {
+	return &Session{
+		Channels:    map[string][]*discordgo.Message{},
+		Users:       map[string]*discordgo.User{},
+		Members:     map[string]*discordgo.Member{},
+		Permissions: map[string]int64{},
+		Roles:       map[string]*discordgo.Role{},
+		files:       map[string][]byte{},
+		responses:   map[string]*discordgo.Message{},
+	}
+}
+
+// Message returns message of user sent to channel of GuildID
+func Message(channelID, authorID, content string) *discordgo.Message {
+	return &discordgo.Message{
+		ChannelID: channelID,
+		GuildID:   GuildID,
+		Author:    &discordgo.User{ID: authorID, Username: authorID},
+		Content:   content,
+	}
+}
+
+// AddMessage adds message to its channel, id is generated if message has none
+func (s *Session) AddMessage(m *discordgo.Message) *discordgo.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if m.ID == "" {
+		m.ID = s.nextID()
+	}
+	s.Channels[m.ChannelID] = append(s.Channels[m.ChannelID], m)
+
+	return m
+}
+
+// Contents returns contents of sent messages
+func (s *Session) Contents() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var contents []string
+	for _, m := range s.Sent {
+		contents = append(contents, m.Content)
+	}
+
+	return contents
+}
+
+// File returns content of file attached to sent message
+func (s *Session) File(messageID, name string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.files[messageID+"/"+name]
+	return data, ok
+}
+
+// Reset removes recorded messages, edits, reactions and responses
+func (s *Session) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Sent, s.Edits, s.Reactions, s.Responses = nil, nil, nil, nil
+}
+
+// Flush returns recorded messages and reactions and removes all recordings,
+// it's safe to call while handlers are sending messages
+func (s *Session) Flush() ([]*discordgo.Message, []Reaction) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sent, reactions := s.Sent, s.Reactions
+	s.Sent, s.Edits, s.Reactions, s.Responses = nil, nil, nil, nil
+
+	return sent, reactions
+}
+
+func (s *Session) ChannelMessage(channelID, messageID string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.message(channelID, messageID)
+}
+
+// ChannelMessages returns messages of channel from newest to oldest like discord does
+func (s *Session) ChannelMessages(channelID string, limit int, beforeID, afterID, aroundID string, options ...discordgo.RequestOption) ([]*discordgo.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	channel := s.Channels[channelID]
+	from, to := 0, len(channel)
+	for i, m := range channel {
+		if m.ID == beforeID {
+			to = i
+		}
+		if m.ID == afterID {
+			from = i + 1
+		}
+	}
+
+	var messages []*discordgo.Message
+	for i := to - 1; i >= from && (limit <= 0 || len(messages) < limit); i-- {
+		messages = append(messages, channel[i])
+	}
+
+	return messages, nil
+}
+
+func (s *Session) ChannelMessageSend(channelID string, content string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	return s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{Content: content})
+}
+
+func (s *Session) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files := data.Files
+	if data.File != nil {
+		files = append(files, data.File)
+	}
+
+	return s.send(channelID, data.Content, data.Embeds, files, data.Reference)
+}
+
+func (s *Session) ChannelMessageSendEmbed(channelID string, embed *discordgo.MessageEmbed, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	return s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{Embeds: []*discordgo.MessageEmbed{embed}})
+}
+
+func (s *Session) ChannelMessageEdit(channelID, messageID, content string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, err := s.message(channelID, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.edit(m, &content, nil), nil
+}
+
+func (s *Session) MessageReactionAdd(channelID, messageID, emojiID string, options ...discordgo.RequestOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Reactions = append(s.Reactions, Reaction{ChannelID: channelID, MessageID: messageID, EmojiID: emojiID})
+	return nil
+}
+
+func (s *Session) User(userID string, options ...discordgo.RequestOption) (*discordgo.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.Users[userID]; ok {
+		return u, nil
+	}
+	return nil, errors.Errorf("unknown user %s", userID)
+}
+
+func (s *Session) GuildMember(guildID, userID string, options ...discordgo.RequestOption) (*discordgo.Member, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if m, ok := s.Members[userID]; ok {
+		return m, nil
+	}
+	return nil, errors.Errorf("unknown member %s of guild %s", userID, guildID)
+}
+
+func (s *Session) UserChannelPermissions(userID, channelID string, fetchOptions ...discordgo.RequestOption) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.Permissions[userID], nil
+}
+
+func (s *Session) Role(guildID, roleID string) (*discordgo.Role, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r, ok := s.Roles[roleID]; ok {
+		return r, nil
+	}
+	return nil, discordgo.ErrStateNotFound
+}
+
+// InteractionRespond records response, response with message data is sent to channel of interaction
+func (s *Session) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Responses = append(s.Responses, resp)
+	if resp.Data == nil {
+		return nil
+	}
+
+	m, err := s.send(interaction.ChannelID, resp.Data.Content, resp.Data.Embeds, resp.Data.Files, nil)
+	if err != nil {
+		return err
+	}
+	m.Flags = resp.Data.Flags
+	s.responses[interaction.ID] = m
+
+	return nil
+}
+
+func (s *Session) InteractionResponse(interaction *discordgo.Interaction, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.response(interaction)
+}
+
+func (s *Session) InteractionResponseEdit(interaction *discordgo.Interaction, newresp *discordgo.WebhookEdit, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Deferred response has no message until it is edited
+	if _, ok := s.responses[interaction.ID]; !ok {
+		m, err := s.send(interaction.ChannelID, "", nil, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		s.responses[interaction.ID] = m
+	}
+
+	m, err := s.response(interaction)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.webhookEdit(m, newresp)
+}
+
+func (s *Session) FollowupMessageCreate(interaction *discordgo.Interaction, wait bool, data *discordgo.WebhookParams, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, err := s.send(interaction.ChannelID, data.Content, data.Embeds, data.Files, nil)
+	if err != nil {
+		return nil, err
+	}
+	m.Flags = data.Flags
+
+	return m, nil
+}
+
+func (s *Session) FollowupMessageEdit(interaction *discordgo.Interaction, messageID string, data *discordgo.WebhookEdit, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, err := s.message(interaction.ChannelID, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.webhookEdit(m, data)
+}
+
+func (s *Session) send(channelID, content string, embeds []*discordgo.MessageEmbed, files []*discordgo.File, ref *discordgo.MessageReference) (*discordgo.Message, error) {
+	m := &discordgo.Message{
+		ID:               s.nextID(),
+		ChannelID:        channelID,
+		GuildID:          GuildID,
+		Author:           &discordgo.User{ID: BotID, Username: BotID, Bot: true},
+		Content:          content,
+		Embeds:           embeds,
+		MessageReference: ref,
+	}
+
+	if err := s.attach(m, files); err != nil {
+		return nil, err
+	}
+
+	s.Channels[channelID] = append(s.Channels[channelID], m)
+	s.Sent = append(s.Sent, m)
+	s.notify(m, false)
+
+	return m, nil
+}
+
+// attach reads files and adds them to message as attachments
+func (s *Session) attach(m *discordgo.Message, files []*discordgo.File) error {
+	for _, f := range files {
+		data, err := io.ReadAll(f.Reader)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read file %s", f.Name)
+		}
+		s.files[m.ID+"/"+f.Name] = data
+		m.Attachments = append(m.Attachments, &discordgo.MessageAttachment{
+			ID:          s.nextID(),
+			Filename:    f.Name,
+			ContentType: f.ContentType,
+			Size:        len(data),
+		})
+	}
+
+	return nil
+}
+
+func (s *Session) webhookEdit(m *discordgo.Message, data *discordgo.WebhookEdit) (*discordgo.Message, error) {
+	var embeds []*discordgo.MessageEmbed
+	if data.Embeds != nil {
+		embeds = *data.Embeds
+	}
+	if err := s.attach(m, data.Files); err != nil {
+		return nil, err
+	}
+
+	return s.edit(m, data.Content, embeds), nil
+}
+
+// edit changes message in place and records its copy
+func (s *Session) edit(m *discordgo.Message, content *string, embeds []*discordgo.MessageEmbed) *discordgo.Message {
+	if content != nil {
+		m.Content = *content
+	}
+	if embeds != nil {
+		m.Embeds = embeds
+	}
+
+	edited := *m
+	s.Edits = append(s.Edits, &edited)
+	s.notify(m, true)
+
+	return m
+}
+
+func (s *Session) notify(m *discordgo.Message, edited bool) {
+	if s.OnMessage != nil {
+		copied := *m
+		s.OnMessage(&copied, edited)
+	}
+}
+
+func (s *Session) message(channelID, messageID string) (*discordgo.Message, error) {
+	for _, m := range s.Channels[channelID] {
+		if m.ID == messageID {
+			return m, nil
+		}
+	}
+	return nil, errors.Errorf("unknown message %s in channel %s", messageID, channelID)
+}
+
+func (s *Session) response(interaction *discordgo.Interaction) (*discordgo.Message, error) {
+	if m, ok := s.responses[interaction.ID]; ok {
+		return m, nil
+	}
+	return nil, errors.Errorf("no response to interaction %s", interaction.ID)
+}
+
+func (s *Session) nextID() string {
+	s.lastID++
+	return strconv.Itoa(s.lastID)
+}
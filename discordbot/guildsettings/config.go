@@ -0,0 +1,209 @@
+package guildsettings
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/paulvasilenko/discordbot/discordbot/plugin"
+	"github.com/paulvasilenko/discordbot/discordbot/response"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+)
+
+const (
+	CommandConfig = "config"
+
+	maxPrefixLength = 5
+)
+
+// Config is a plugin which provides admin commands to change guild settings
+type Config struct {
+	manager  *Manager
+	registry *plugin.Registry
+}
+
+// NewConfig is a constructor function for config plugin
+func NewConfig(manager *Manager, registry *plugin.Registry) *Config {
+	return &Config{manager: manager, registry: registry}
+}
+
+func (c *Config) Name() string {
+	return "Config"
+}
+
+// GetInfo returns map of info message
+func (c *Config) GetInfo() map[string]string {
+	return map[string]string{
+		"!config":                                "Prints settings of this server",
+		"!config set prefix [prefix]":            "Changes command prefix on this server",
+		"!config enable|disable [plugin]":        "Turns plugin on or off on this server",
+		"!config allow|deny [plugin] [#channel]": "Allows plugin only in channel or denies plugin in channel, current channel is used if not passed",
+		"!config clear [plugin]":                 "Removes channel restrictions of plugin",
+	}
+}
+
+func (c *Config) Handlers() []interface{} {
+	return nil
+}
+
+func (c *Config) Init(s *discordgo.Session) error {
+	return nil
+}
+
+func (c *Config) Close() error {
+	return nil
+}
+
+// Commands returns commands of plugin handled by router
+func (c *Config) Commands() []*router.Command {
+	return []*router.Command{
+		{
+			Name:        CommandConfig,
+			Description: "Changes settings of this server",
+			Args: []router.Arg{
+				{Name: "action", Type: router.ArgString, Optional: true},
+				{Name: "name", Type: router.ArgString, Optional: true},
+				{Name: "channel", Type: router.ArgChannel, Optional: true},
+				{Name: "value", Type: router.ArgString, Optional: true},
+			},
+			Handler:    c.config,
+			Permission: discordgo.PermissionManageServer,
+		},
+	}
+}
+
+func (c *Config) config(ctx *router.Context) error {
+	m := ctx.Message
+	if m.GuildID == "" {
+		_, err := ctx.Reply("Settings are available only on servers")
+		return err
+	}
+
+	var err error
+	action := strings.ToLower(ctx.Args.String("action"))
+	name := ctx.Args.String("name")
+
+	var reply string
+	switch action {
+	case "", "show":
+		reply = c.show(ctx.Prefix, c.manager.Get(m.GuildID))
+	case "set":
+		if strings.ToLower(name) != "prefix" {
+			return ctx.UsageError("Only prefix can be set")
+		}
+		prefix := ctx.Args.String("value")
+		if prefix == "" || len(prefix) > maxPrefixLength {
+			return ctx.UsageError(fmt.Sprintf("Prefix must be from 1 to %d symbols long", maxPrefixLength))
+		}
+		err = c.manager.Update(m.GuildID, func(s *Settings) error {
+			s.Prefix = prefix
+			return nil
+		})
+		reply = fmt.Sprintf("Prefix is changed to `%s`", prefix)
+	case "enable", "disable", "allow", "deny", "clear":
+		p, ok := c.registry.Get(name)
+		if !ok {
+			return ctx.UsageError(fmt.Sprintf("Unknown plugin %s", name))
+		}
+		if p == plugin.Plugin(c) {
+			return ctx.UsageError("Config plugin cannot be restricted")
+		}
+
+		channelID := ctx.Args.Channel("channel")
+		if channelID == "" {
+			channelID = m.ChannelID
+		}
+
+		err = c.manager.Update(m.GuildID, func(s *Settings) error {
+			updatePlugin(s, p.Name(), action, channelID)
+			return nil
+		})
+		reply = fmt.Sprintf("Settings of %s are updated", p.Name())
+	default:
+		return ctx.UsageError(fmt.Sprintf("Unknown action %s", action))
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return response.Reply(ctx, response.Text(reply))
+}
+
+func updatePlugin(s *Settings, name, action, channelID string) {
+	key := strings.ToLower(name)
+	p := s.Plugin(key)
+
+	switch action {
+	case "enable":
+		p.Disabled = false
+	case "disable":
+		p.Disabled = true
+	case "allow":
+		p.DeniedChannels = remove(p.DeniedChannels, channelID)
+		if !contains(p.AllowedChannels, channelID) {
+			p.AllowedChannels = append(p.AllowedChannels, channelID)
+		}
+	case "deny":
+		p.AllowedChannels = remove(p.AllowedChannels, channelID)
+		if !contains(p.DeniedChannels, channelID) {
+			p.DeniedChannels = append(p.DeniedChannels, channelID)
+		}
+	case "clear":
+		p.AllowedChannels = nil
+		p.DeniedChannels = nil
+	}
+
+	if s.Plugins == nil {
+		s.Plugins = map[string]*PluginSettings{}
+	}
+	s.Plugins[key] = p
+}
+
+func (c *Config) show(prefix string, s *Settings) string {
+	lines := []string{fmt.Sprintf("Prefix: `%s`", prefix)}
+
+	names := make([]string, 0, len(s.Plugins))
+	for name := range s.Plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		p := s.Plugins[name]
+		line := name + ":"
+		if p.Disabled {
+			line += " disabled"
+		} else {
+			line += " enabled"
+		}
+		if len(p.AllowedChannels) > 0 {
+			line += "; only in " + channelMentions(p.AllowedChannels)
+		}
+		if len(p.DeniedChannels) > 0 {
+			line += "; not in " + channelMentions(p.DeniedChannels)
+		}
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func channelMentions(ids []string) string {
+	mentions := make([]string, len(ids))
+	for i, id := range ids {
+		mentions[i] = "<#" + id + ">"
+	}
+	return strings.Join(mentions, ", ")
+}
+
+func remove(list []string, value string) []string {
+	result := list[:0]
+	for _, v := range list {
+		if v != value {
+			result = append(result, v)
+		}
+	}
+	return result
+}
@@ -0,0 +1,60 @@
+package guildsettings
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/paulvasilenko/discordbot/discordbot/discord/discordtest"
+	"github.com/paulvasilenko/discordbot/discordbot/plugin"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+)
+
+type testPlugin struct{}
+
+func (testPlugin) Name() string                    { return "Haiku" }
+func (testPlugin) GetInfo() map[string]string      { return nil }
+func (testPlugin) Handlers() []interface{}         { return nil }
+func (testPlugin) Init(s *discordgo.Session) error { return nil }
+func (testPlugin) Close() error                    { return nil }
+
+func Test_Config(t *testing.T) {
+	m := NewManager(memoryStore{}, "!")
+	rt := router.New("!")
+	rt.SetPrefixFunc(m.Prefix)
+	registry := plugin.NewRegistry(rt)
+	c := NewConfig(m, registry)
+	for _, p := range []plugin.Plugin{testPlugin{}, c} {
+		if err := registry.Register(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rt.Add(c.Name(), c.Commands()...); err != nil {
+		t.Fatal(err)
+	}
+
+	// Test cases share settings, so they depend on previous ones
+	testCases := []struct {
+		message  string
+		expected []string
+	}{
+		{message: "!config", expected: []string{"Prefix: `!`"}},
+		{message: "!config disable haiku", expected: []string{"Settings of Haiku are updated"}},
+		{message: "!config deny haiku <#5>", expected: []string{"Settings of Haiku are updated"}},
+		{message: "!config show", expected: []string{"Prefix: `!`\nhaiku: disabled; not in <#5>"}},
+		{message: "!config enable config", expected: []string{"Config plugin cannot be restricted\nUsage: `!config [action] [name] [channel] [value]`"}},
+		{message: "!config set prefix ??", expected: []string{"Prefix is changed to `??`"}},
+		{message: "!config", expected: nil},
+		{message: "??config clear haiku", expected: []string{"Settings of Haiku are updated"}},
+		{message: "??config", expected: []string{"Prefix: `??`\nhaiku: disabled"}},
+	}
+
+	for _, v := range testCases {
+		s := discordtest.NewSession()
+		rt.HandleMessage(s, discordtest.Message("channel", "10", v.message))
+
+		if actual := s.Contents(); !reflect.DeepEqual(actual, v.expected) {
+			t.Errorf("message %q: expected %q, actual %q", v.message, v.expected, actual)
+		}
+	}
+}
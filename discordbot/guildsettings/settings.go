@@ -0,0 +1,243 @@
+// Package guildsettings provides per guild settings: command prefix, enabled
+// plugins and channels where plugins are allowed to work
+package guildsettings
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	cacheExpiration = 30 * time.Minute
+	// errorExpiration is time settings are cached after failed load, so store isn't queried on every message
+	errorExpiration = time.Minute
+)
+
+// Settings represents settings of single guild
+type Settings struct {
+	GuildID  string                      `json:"-"`
+	Prefix   string                      `json:"prefix,omitempty"`
+	Plugins  map[string]*PluginSettings  `json:"plugins,omitempty"`
+	Commands map[string]*CommandSettings `json:"commands,omitempty"`
+}
+
+// PluginSettings represents settings of plugin in guild
+type PluginSettings struct {
+	Disabled bool `json:"disabled,omitempty"`
+	// AllowedChannels limits plugin to listed channels if not empty
+	AllowedChannels []string `json:"allowedChannels,omitempty"`
+	DeniedChannels  []string `json:"deniedChannels,omitempty"`
+}
+
+// CommandSettings represents access rules of command in guild
+type CommandSettings struct {
+	// Permission overrides discord permission required by command, zero means no permission required
+	Permission *int64 `json:"permission,omitempty"`
+	// Roles which are allowed to call command
+	Roles []string `json:"roles,omitempty"`
+}
+
+// Command returns access rules of command, command names are case insensitive
+func (s *Settings) Command(name string) *CommandSettings {
+	if c, ok := s.Commands[strings.ToLower(name)]; ok {
+		return c
+	}
+	return &CommandSettings{}
+}
+
+// Plugin returns settings of plugin, plugin names are case insensitive
+func (s *Settings) Plugin(name string) *PluginSettings {
+	if p, ok := s.Plugins[strings.ToLower(name)]; ok {
+		return p
+	}
+	return &PluginSettings{}
+}
+
+// Allowed checks whether plugin is enabled and allowed to work in channel
+func (p *PluginSettings) Allowed(channelID string) bool {
+	if p.Disabled || contains(p.DeniedChannels, channelID) {
+		return false
+	}
+
+	return len(p.AllowedChannels) == 0 || contains(p.AllowedChannels, channelID)
+}
+
+func (s *Settings) clone() *Settings {
+	c := &Settings{
+		GuildID:  s.GuildID,
+		Prefix:   s.Prefix,
+		Plugins:  map[string]*PluginSettings{},
+		Commands: map[string]*CommandSettings{},
+	}
+	for name, p := range s.Plugins {
+		c.Plugins[name] = &PluginSettings{
+			Disabled:        p.Disabled,
+			AllowedChannels: append([]string(nil), p.AllowedChannels...),
+			DeniedChannels:  append([]string(nil), p.DeniedChannels...),
+		}
+	}
+	for name, cmd := range s.Commands {
+		cc := &CommandSettings{Roles: append([]string(nil), cmd.Roles...)}
+		if cmd.Permission != nil {
+			permission := *cmd.Permission
+			cc.Permission = &permission
+		}
+		c.Commands[name] = cc
+	}
+	return c
+}
+
+// Store persists guild settings
+type Store interface {
+	// Load returns settings of guild or nil if guild has no settings saved
+	Load(guildID string) (*Settings, error)
+	Save(s *Settings) error
+}
+
+// Manager provides cached access to guild settings
+type Manager struct {
+	store         Store
+	defaultPrefix string
+	cache         *cache.Cache
+	// known are last loaded settings of guilds, they are used while store is unavailable
+	known *cache.Cache
+
+	mu sync.Mutex
+}
+
+// NewManager returns settings manager, defaultPrefix is used for guilds without own prefix
+func NewManager(store Store, defaultPrefix string) *Manager {
+	return &Manager{
+		store:         store,
+		defaultPrefix: defaultPrefix,
+		cache:         cache.New(cacheExpiration, 2*cacheExpiration),
+		known:         cache.New(cache.NoExpiration, 0),
+	}
+}
+
+// Get returns settings of guild. Returned settings must not be modified, use Update instead
+func (m *Manager) Get(guildID string) *Settings {
+	if guildID == "" {
+		return &Settings{}
+	}
+
+	if s, ok := m.cache.Get(guildID); ok {
+		return s.(*Settings)
+	}
+
+	s, err := m.load(guildID)
+	if err != nil {
+		log.Printf("failed to load settings of guild %s: %v", guildID, err)
+
+		// Last known settings keep restrictions of guild, defaults are used only if guild wasn't loaded yet
+		fallback := &Settings{GuildID: guildID}
+		if known, ok := m.known.Get(guildID); ok {
+			fallback = known.(*Settings)
+		}
+		m.cache.Set(guildID, fallback, errorExpiration)
+
+		return fallback
+	}
+
+	m.cache.SetDefault(guildID, s)
+	m.known.SetDefault(guildID, s)
+
+	return s
+}
+
+func (m *Manager) load(guildID string) (*Settings, error) {
+	s, err := m.store.Load(guildID)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		s = &Settings{}
+	}
+	s.GuildID = guildID
+
+	return s, nil
+}
+
+// Update modifies settings of guild with fn and saves them
+func (m *Manager) Update(guildID string, fn func(s *Settings) error) error {
+	if guildID == "" {
+		return errors.New("settings are available only in guilds")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, err := m.load(guildID)
+	if err != nil {
+		return errors.Wrap(err, "failed to load settings")
+	}
+
+	s := current.clone()
+	if err := fn(s); err != nil {
+		return err
+	}
+
+	if err := m.store.Save(s); err != nil {
+		return errors.Wrap(err, "failed to save settings")
+	}
+
+	m.cache.SetDefault(guildID, s)
+	m.known.SetDefault(guildID, s)
+
+	return nil
+}
+
+// SetEnabled turns plugin on or off in guild
+func (m *Manager) SetEnabled(guildID, plugin string, enabled bool) error {
+	action := "disable"
+	if enabled {
+		action = "enable"
+	}
+
+	return m.Update(guildID, func(s *Settings) error {
+		updatePlugin(s, plugin, action, "")
+		return nil
+	})
+}
+
+// Prefix returns command prefix of guild
+func (m *Manager) Prefix(guildID string) string {
+	if prefix := m.Get(guildID).Prefix; prefix != "" {
+		return prefix
+	}
+	return m.defaultPrefix
+}
+
+// Allowed checks whether plugin is allowed to work in channel of guild.
+// Everything is allowed in direct messages
+func (m *Manager) Allowed(guildID, channelID, plugin string) bool {
+	if guildID == "" {
+		return true
+	}
+	return m.Get(guildID).Plugin(plugin).Allowed(channelID)
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware makes router skip commands of plugins which are not allowed in channel
+func (m *Manager) Middleware(next router.HandlerFunc) router.HandlerFunc {
+	return func(ctx *router.Context) error {
+		if !m.Allowed(ctx.Message.GuildID, ctx.Message.ChannelID, ctx.Command.Plugin) {
+			return router.ErrNotAllowed
+		}
+		return next(ctx)
+	}
+}
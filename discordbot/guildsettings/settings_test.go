@@ -0,0 +1,124 @@
+package guildsettings
+
+import (
+	"errors"
+	"testing"
+)
+
+type memoryStore map[string]*Settings
+
+func (st memoryStore) Load(guildID string) (*Settings, error) {
+	return st[guildID], nil
+}
+
+func (st memoryStore) Save(s *Settings) error {
+	st[s.GuildID] = s
+	return nil
+}
+
+// failingStore counts loads and fails them when err is set
+type failingStore struct {
+	memoryStore
+	err   error
+	loads int
+}
+
+func (st *failingStore) Load(guildID string) (*Settings, error) {
+	st.loads++
+	if st.err != nil {
+		return nil, st.err
+	}
+	return st.memoryStore.Load(guildID)
+}
+
+func Test_Get(t *testing.T) {
+	st := &failingStore{memoryStore: memoryStore{"guild": {Plugins: map[string]*PluginSettings{"haiku": {Disabled: true}}}}}
+	m := NewManager(st, "!")
+
+	if m.Allowed("guild", "1", "haiku") {
+		t.Error("expected haiku to be disabled")
+	}
+
+	// Settings expire while store is down
+	st.err = errors.New("db is down")
+	m.cache.Delete("guild")
+
+	testCases := []struct {
+		guildID string
+		allowed bool
+		loads   int
+	}{
+		// Last known settings are kept
+		{guildID: "guild", allowed: false, loads: 2},
+		// Failed load is cached, store isn't queried again
+		{guildID: "guild", allowed: false, loads: 2},
+		// Guild without known settings gets defaults
+		{guildID: "other", allowed: true, loads: 3},
+		{guildID: "other", allowed: true, loads: 3},
+	}
+
+	for i, tc := range testCases {
+		if allowed := m.Allowed(tc.guildID, "1", "haiku"); allowed != tc.allowed {
+			t.Errorf("case %d: expected allowed: %v actual: %v", i, tc.allowed, allowed)
+		}
+		if st.loads != tc.loads {
+			t.Errorf("case %d: expected %d loads, actual %d", i, tc.loads, st.loads)
+		}
+	}
+}
+
+func Test_Allowed(t *testing.T) {
+	m := NewManager(memoryStore{}, "!")
+
+	type test struct {
+		action    string
+		plugin    string
+		channelID string
+		expected  map[string]bool
+	}
+
+	testCases := []test{
+		{expected: map[string]bool{"1": true, "2": true}},
+		{action: "allow", plugin: "Haiku", channelID: "1", expected: map[string]bool{"1": true, "2": false}},
+		{action: "deny", plugin: "haiku", channelID: "1", expected: map[string]bool{"1": false, "2": true}},
+		{action: "disable", plugin: "haiku", expected: map[string]bool{"1": false, "2": false}},
+		{action: "enable", plugin: "haiku", expected: map[string]bool{"1": false, "2": true}},
+		{action: "clear", plugin: "haiku", expected: map[string]bool{"1": true, "2": true}},
+	}
+
+	for _, v := range testCases {
+		if v.action != "" {
+			err := m.Update("guild", func(s *Settings) error {
+				updatePlugin(s, v.plugin, v.action, v.channelID)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for channelID, expected := range v.expected {
+			if actual := m.Allowed("guild", channelID, "HAIKU"); actual != expected {
+				t.Errorf("after %s of channel %s: expected: %v actual: %v", v.action, channelID, expected, actual)
+			}
+		}
+
+		if !m.Allowed("", "1", "haiku") {
+			t.Error("plugins must be allowed in direct messages")
+		}
+		if !m.Allowed("guild", "1", "racing") {
+			t.Error("other plugins must not be affected")
+		}
+	}
+}
+
+func Test_Prefix(t *testing.T) {
+	m := NewManager(memoryStore{"guild": {Prefix: "?"}}, "!")
+
+	if prefix := m.Prefix("guild"); prefix != "?" {
+		t.Error("expected: ? actual:", prefix)
+	}
+	if prefix := m.Prefix("other"); prefix != "!" {
+		t.Error("expected: ! actual:", prefix)
+	}
+}
@@ -0,0 +1,50 @@
+package guildsettings
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/paulvasilenko/discordbot/discordbot/metrics"
+	"github.com/paulvasilenko/discordbot/discordbot/storage"
+	"github.com/pkg/errors"
+)
+
+// SQLStore stores settings in guildSettings table as json,
+// its queries are the same for MySQL and SQLite
+type SQLStore struct {
+	db *sql.DB
+}
+
+// NewSQLStore returns store working with given connection
+func NewSQLStore(db *storage.DB) *SQLStore {
+	return &SQLStore{db: db.DB}
+}
+
+func (st *SQLStore) Load(guildID string) (*Settings, error) {
+	var raw string
+	err := st.db.QueryRow(`SELECT settings FROM guildSettings WHERE guildId = ?`, guildID).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, metrics.DBError("guildsettings", "load", err)
+	}
+
+	s := &Settings{}
+	if err := json.Unmarshal([]byte(raw), s); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode settings of guild %s", guildID)
+	}
+
+	return s, nil
+}
+
+func (st *SQLStore) Save(s *Settings) error {
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode settings")
+	}
+
+	_, err = st.db.Exec(`REPLACE INTO guildSettings (guildId, settings) VALUES (?, ?)`, s.GuildID, string(raw))
+
+	return metrics.DBError("guildsettings", "save", err)
+}
@@ -5,6 +5,9 @@ import (
 	"strings"
 
 	"github.com/bwmarrin/discordgo"
+	"github.com/paulvasilenko/discordbot/discordbot/discord"
+	"github.com/paulvasilenko/discordbot/discordbot/response"
+	log "github.com/sirupsen/logrus"
 )
 
 const (
@@ -30,8 +33,24 @@ func (c *Haiku) GetInfo() map[string]string {
 	}
 }
 
+func (c *Haiku) Name() string {
+	return "Haiku"
+}
+
+func (c *Haiku) Handlers() []interface{} {
+	return []interface{}{c.MessageCreate}
+}
+
+func (c *Haiku) Init(s *discordgo.Session) error {
+	return nil
+}
+
+func (c *Haiku) Close() error {
+	return nil
+}
+
 // MessageCreate reacts for created messages and processes logic
-func (c *Haiku) MessageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
+func (c *Haiku) MessageCreate(s discord.Session, m *discordgo.MessageCreate) {
 	if m.Author.Bot {
 		return
 	}
@@ -51,8 +70,9 @@ func (c *Haiku) MessageCreate(s *discordgo.Session, m *discordgo.MessageCreate)
 	}
 	messageContent += "🇯🇵🇯🇵🇯🇵🇯🇵🇯🇵🇯🇵"
 
-	s.ChannelMessageSend(m.ChannelID, messageContent)
-	return
+	if err := response.Send(s, m.ChannelID, response.Text(messageContent)); err != nil {
+		log.Println("Error sending haiku: ", err)
+	}
 }
 
 func makeHaiku(words []string) [][]string {
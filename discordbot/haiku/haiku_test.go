@@ -2,8 +2,12 @@ package haiku
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/paulvasilenko/discordbot/discordbot/discord/discordtest"
 )
 
 func Test_countSyllablesEng(t *testing.T) {
@@ -97,3 +101,25 @@ Splash! Silence again.`, func(r rune) bool { return (r == ' ') || (r == '\n') })
 		}
 	}
 }
+
+func Test_MessageCreate(t *testing.T) {
+	testCases := []struct {
+		content  string
+		expected []string
+	}{
+		{
+			content:  "test test test test test test test test test test test test test test test test test",
+			expected: []string{"🇯🇵🇯🇵🇯🇵🇯🇵🇯🇵🇯🇵\ntest test test test test\ntest test test test test test test\ntest test test test test\n🇯🇵🇯🇵🇯🇵🇯🇵🇯🇵🇯🇵"},
+		},
+		{content: "test test"},
+	}
+
+	for _, v := range testCases {
+		s := discordtest.NewSession()
+		NewHaiku().MessageCreate(s, &discordgo.MessageCreate{Message: discordtest.Message("channel", "10", v.content)})
+
+		if actual := s.Contents(); !reflect.DeepEqual(actual, v.expected) {
+			t.Errorf("message %q: expected %q, actual %q", v.content, v.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// Package help provides !help command built from metadata of registered plugins
+package help
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/paulvasilenko/discordbot/discordbot/plugin"
+	"github.com/paulvasilenko/discordbot/discordbot/response"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+)
+
+const (
+	CommandHelp = "help"
+
+	// FieldsPerPage is a number of embed fields shown on one help page
+	FieldsPerPage = 10
+)
+
+// Help is a plugin which prints information about registered plugins
+type Help struct {
+	registry *plugin.Registry
+}
+
+// NewHelp is a constructor function for help plugin
+func NewHelp(registry *plugin.Registry) *Help {
+	return &Help{registry: registry}
+}
+
+func (h *Help) Name() string {
+	return "Help"
+}
+
+// GetInfo returns map of info message
+func (h *Help) GetInfo() map[string]string {
+	return map[string]string{
+		"!" + CommandHelp: "Prints list of plugins. Pass plugin name to see its commands or page number to switch page",
+	}
+}
+
+func (h *Help) Handlers() []interface{} {
+	return nil
+}
+
+func (h *Help) Init(s *discordgo.Session) error {
+	return nil
+}
+
+func (h *Help) Close() error {
+	return nil
+}
+
+// Commands returns commands of plugin handled by router
+func (h *Help) Commands() []*router.Command {
+	return []*router.Command{
+		{
+			Name:        CommandHelp,
+			Description: h.GetInfo()["!"+CommandHelp],
+			Args: []router.Arg{
+				{Name: "plugin", Type: router.ArgString, Optional: true},
+				{Name: "page", Type: router.ArgNumber, Optional: true},
+			},
+			Handler: h.help,
+		},
+	}
+}
+
+func (h *Help) help(ctx *router.Context) error {
+	name := ctx.Args.String("plugin")
+	page := int(ctx.Args.Number("page"))
+
+	if n, err := strconv.Atoi(name); err == nil {
+		name, page = "", n
+	}
+
+	var r *response.Response
+	if name == "" {
+		r = h.pluginsPage(ctx.Prefix)
+	} else {
+		p, ok := h.registry.Get(name)
+		if !ok {
+			return ctx.UsageError(fmt.Sprintf("Unknown plugin %s", name))
+		}
+		r = pluginPage(ctx.Prefix, p)
+	}
+	r.PageSize = FieldsPerPage
+	r.Page = page
+	if r.Page < 1 {
+		r.Page = 1
+	}
+
+	return response.Reply(ctx, r)
+}
+
+func (h *Help) pluginsPage(prefix string) *response.Response {
+	plugins := h.registry.Plugins()
+	fields := make([]*discordgo.MessageEmbedField, 0, len(plugins))
+	for _, p := range plugins {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:  p.Name(),
+			Value: valueOrDash(strings.Join(withPrefix(prefix, plugin.Commands(p)), ", ")),
+		})
+	}
+
+	return &response.Response{
+		Title:     "Plugins",
+		Text:      fmt.Sprintf("Use `%s%s <plugin>` to see plugin commands", prefix, CommandHelp),
+		Fields:    fields,
+		PageUsage: prefix + CommandHelp + " <page>",
+	}
+}
+
+func pluginPage(prefix string, p plugin.Plugin) *response.Response {
+	info := p.GetInfo()
+	commands := plugin.Commands(p)
+	fields := make([]*discordgo.MessageEmbedField, 0, len(commands))
+	for _, command := range commands {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:  withPrefix(prefix, []string{command})[0],
+			Value: valueOrDash(info[command]),
+		})
+	}
+
+	return &response.Response{
+		Title:     p.Name(),
+		Fields:    fields,
+		PageUsage: fmt.Sprintf("%s%s %s <page>", prefix, CommandHelp, p.Name()),
+	}
+}
+
+// withPrefix replaces default ! prefix of commands from plugin info with configured one
+func withPrefix(prefix string, commands []string) []string {
+	result := make([]string, len(commands))
+	for i, command := range commands {
+		if strings.HasPrefix(command, router.DefaultPrefix) {
+			command = prefix + strings.TrimPrefix(command, router.DefaultPrefix)
+		}
+		result[i] = command
+	}
+	return result
+}
+
+// valueOrDash replaces empty field value which is rejected by discord
+func valueOrDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+
+	return value
+}
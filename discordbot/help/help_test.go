@@ -0,0 +1,81 @@
+package help
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/paulvasilenko/discordbot/discordbot/discord/discordtest"
+	"github.com/paulvasilenko/discordbot/discordbot/plugin"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+)
+
+type testPlugin struct{}
+
+func (testPlugin) Name() string { return "Racing" }
+func (testPlugin) GetInfo() map[string]string {
+	return map[string]string{"!rjoin": "Joins to race", "!rleave": "Leaves race"}
+}
+func (testPlugin) Handlers() []interface{}         { return nil }
+func (testPlugin) Init(s *discordgo.Session) error { return nil }
+func (testPlugin) Close() error                    { return nil }
+
+func Test_Commands(t *testing.T) {
+	rt := router.New("!")
+	registry := plugin.NewRegistry(rt)
+	h := NewHelp(registry)
+	for _, p := range []plugin.Plugin{testPlugin{}, h} {
+		if err := registry.Register(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rt.Add(h.Name(), h.Commands()...); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		message  string
+		title    string
+		fields   []string
+		expected []string
+	}{
+		{
+			message:  "!help",
+			title:    "Plugins",
+			fields:   []string{"Racing: !rjoin, !rleave", "Help: !help"},
+			expected: []string{""},
+		},
+		{
+			message:  "!help racing",
+			title:    "Racing",
+			fields:   []string{"!rjoin: Joins to race", "!rleave: Leaves race"},
+			expected: []string{""},
+		},
+		{
+			message:  "!help fishing",
+			expected: []string{"Unknown plugin fishing\nUsage: `!help [plugin] [page]`"},
+		},
+	}
+
+	for _, v := range testCases {
+		s := discordtest.NewSession()
+		rt.HandleMessage(s, discordtest.Message("channel", "10", v.message))
+
+		if actual := s.Contents(); !reflect.DeepEqual(actual, v.expected) {
+			t.Errorf("message %q: expected %q, actual %q", v.message, v.expected, actual)
+			continue
+		}
+		if v.title == "" {
+			continue
+		}
+
+		embed := s.Sent[0].Embeds[0]
+		var fields []string
+		for _, f := range embed.Fields {
+			fields = append(fields, f.Name+": "+f.Value)
+		}
+		if embed.Title != v.title || !reflect.DeepEqual(fields, v.fields) {
+			t.Errorf("message %q: expected %s %q, actual %s %q", v.message, v.title, v.fields, embed.Title, fields)
+		}
+	}
+}
@@ -3,8 +3,15 @@ package homog
 
 import (
 	"fmt"
-	"github.com/bwmarrin/discordgo"
 	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+)
+
+const (
+	pluralPattern   = "%s? Нет, спасибо, мне нравятся %s"
+	singularPattern = "%s? Нет, спасибо, мне нравится %s"
 )
 
 // Homog is a struct representing plugin with it's main configurations
@@ -22,36 +29,56 @@ func (h *Homog) GetInfo() map[string]string {
 	}
 }
 
-// MessageCreate is a event method for message sent to discord
-func (h *Homog) MessageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
-	if m.Author.Bot {
-		return
-	}
+func (h *Homog) Name() string {
+	return "Homog"
+}
 
-	data := [2]string{"Гомогенезация", "женщины"}
+func (h *Homog) Handlers() []interface{} {
+	return nil
+}
 
-	pattern := "%s? Нет, спасибо, мне нравятся %s"
+func (h *Homog) Init(s *discordgo.Session) error {
+	return nil
+}
 
-	var message string
+func (h *Homog) Close() error {
+	return nil
+}
 
-	switch {
-	case strings.HasPrefix(m.Content, "!homog2"):
-		pattern = "%s? Нет, спасибо, мне нравится %s"
-		message = strings.Replace(m.Content, "!homog2", "", 1)
-	case strings.HasPrefix(m.Content, "!homog"):
-		message = strings.Replace(m.Content, "!homog", "", 1)
-	default:
-		return
+// Commands returns commands of plugin handled by router
+func (h *Homog) Commands() []*router.Command {
+	return []*router.Command{
+		{
+			Name:        "homog",
+			Description: h.GetInfo()["!homog"],
+			Args:        []router.Arg{{Name: "message", Type: router.ArgText, Optional: true, Description: "two parts of message split by %"}},
+			Handler:     h.homog(pluralPattern),
+			Slash:       true,
+		},
+		{
+			Name:        "homog2",
+			Description: h.GetInfo()["!homog2"],
+			Args:        []router.Arg{{Name: "message", Type: router.ArgText, Optional: true, Description: "two parts of message split by %"}},
+			Handler:     h.homog(singularPattern),
+			Slash:       true,
+		},
 	}
+}
 
-	args := strings.Split(message, "%")
+func (h *Homog) homog(pattern string) router.HandlerFunc {
+	return func(ctx *router.Context) error {
+		data := [2]string{"Гомогенезация", "женщины"}
 
-	for index, value := range args {
-		if value == "" {
-			continue
+		args := strings.Split(ctx.Args.String("message"), "%")
+
+		for index, value := range args {
+			if value == "" || index >= len(data) {
+				continue
+			}
+			data[index] = value
 		}
-		data[index] = value
-	}
 
-	_, _ = s.ChannelMessageSend(m.ChannelID, fmt.Sprintf(pattern, data[0], data[1]))
+		_, err := ctx.Reply(fmt.Sprintf(pattern, data[0], data[1]))
+		return err
+	}
 }
@@ -0,0 +1,37 @@
+package homog
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/paulvasilenko/discordbot/discordbot/discord/discordtest"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+)
+
+func Test_Commands(t *testing.T) {
+	h := NewHomog()
+	rt := router.New("!")
+	if err := rt.Add(h.Name(), h.Commands()...); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		message  string
+		expected []string
+	}{
+		{message: "!homog", expected: []string{"Гомогенезация? Нет, спасибо, мне нравятся женщины"}},
+		{message: "!homog Яблоки%груши", expected: []string{"Яблоки? Нет, спасибо, мне нравятся груши"}},
+		{message: "!homog2 Чай", expected: []string{"Чай? Нет, спасибо, мне нравится женщины"}},
+		{message: "!homog2 %кофе", expected: []string{"Гомогенезация? Нет, спасибо, мне нравится кофе"}},
+		{message: "homog Чай", expected: nil},
+	}
+
+	for _, v := range testCases {
+		s := discordtest.NewSession()
+		rt.HandleMessage(s, discordtest.Message("channel", "user", v.message))
+
+		if actual := s.Contents(); !reflect.DeepEqual(actual, v.expected) {
+			t.Errorf("message %q: expected %q, actual %q", v.message, v.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// Package lifecycle tracks work running in the bot, so it can be finished before shutdown
+package lifecycle
+
+import (
+	"context"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Hook is a cleanup function called on shutdown, it should return when context is done
+type Hook func(ctx context.Context) error
+
+type hook struct {
+	name string
+	fn   Hook
+}
+
+// Manager keeps count of running tasks and cleanup hooks.
+// After shutdown is started new tasks are rejected
+type Manager struct {
+	mu       sync.Mutex
+	tasks    sync.WaitGroup
+	running  int
+	stopping bool
+	hooks    []hook
+	// onPanic handles panics recovered in tasks run by Go
+	onPanic func(p interface{}, stack []byte)
+}
+
+// NewManager is a constructor function for lifecycle manager
+func NewManager() *Manager {
+	return &Manager{}
+}
+
+// SetPanicHandler sets handler of panics recovered in tasks run by Go, by default they are logged
+func (m *Manager) SetPanicHandler(fn func(p interface{}, stack []byte)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.onPanic = fn
+}
+
+// Start registers new running task, it returns false when bot is shutting down.
+// Every successful Start must be followed by Done
+func (m *Manager) Start() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.stopping {
+		return false
+	}
+
+	m.running++
+	m.tasks.Add(1)
+
+	return true
+}
+
+// Done marks task registered by Start as finished
+func (m *Manager) Done() {
+	m.mu.Lock()
+	m.running--
+	m.mu.Unlock()
+
+	m.tasks.Done()
+}
+
+// Go runs fn in goroutine tracked as running task, it returns false and doesn't run fn
+// when bot is shutting down. Panic in fn is recovered and passed to panic handler
+func (m *Manager) Go(fn func()) bool {
+	if !m.Start() {
+		return false
+	}
+
+	go func() {
+		defer m.Done()
+		defer m.recoverPanic()
+		fn()
+	}()
+
+	return true
+}
+
+func (m *Manager) recoverPanic() {
+	p := recover()
+	if p == nil {
+		return
+	}
+
+	m.mu.Lock()
+	onPanic := m.onPanic
+	m.mu.Unlock()
+
+	if onPanic == nil {
+		log.Errorf("panic in background task: %v\n%s", p, debug.Stack())
+		return
+	}
+	onPanic(p, debug.Stack())
+}
+
+// Stopping returns true after shutdown is started
+func (m *Manager) Stopping() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.stopping
+}
+
+// OnShutdown registers cleanup hook. Hooks are called in reverse order of registration
+// after running tasks are finished
+func (m *Manager) OnShutdown(name string, fn Hook) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.hooks = append(m.hooks, hook{name: name, fn: fn})
+}
+
+// Middleware is router middleware which rejects commands during shutdown and
+// tracks running ones
+func (m *Manager) Middleware(next router.HandlerFunc) router.HandlerFunc {
+	return func(ctx *router.Context) error {
+		if !m.Start() {
+			return &router.DeniedError{Reason: "Bot is restarting, please, try again in a minute"}
+		}
+		defer m.Done()
+
+		return next(ctx)
+	}
+}
+
+// Shutdown stops accepting new tasks, waits until running ones are finished but not longer
+// than timeout and calls cleanup hooks. Hooks get their own timeout, so they can clean up
+// even when running tasks took the whole wait. It returns first error returned by hooks
+func (m *Manager) Shutdown(timeout time.Duration) error {
+	m.mu.Lock()
+	m.stopping = true
+	hooks := append([]hook(nil), m.hooks...)
+	m.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		m.tasks.Wait()
+		close(finished)
+	}()
+
+	wait := time.NewTimer(timeout)
+	defer wait.Stop()
+
+	select {
+	case <-finished:
+	case <-wait.C:
+		m.mu.Lock()
+		log.Printf("Shutdown timeout exceeded, %d tasks are still running", m.running)
+		m.mu.Unlock()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var firstErr error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i].fn(ctx); err != nil && firstErr == nil {
+			firstErr = errors.Wrapf(err, "failed to shutdown %s", hooks[i].name)
+		}
+	}
+
+	return firstErr
+}
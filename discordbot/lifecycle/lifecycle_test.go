@@ -0,0 +1,97 @@
+package lifecycle
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func Test_Shutdown(t *testing.T) {
+	testCases := []struct {
+		name     string
+		task     time.Duration
+		timeout  time.Duration
+		finished bool
+	}{
+		{name: "waits for task", task: 20 * time.Millisecond, timeout: time.Second, finished: true},
+		{name: "timeout exceeded", task: time.Second, timeout: 20 * time.Millisecond},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := NewManager()
+
+			finished := make(chan struct{})
+			m.Go(func() {
+				time.Sleep(tc.task)
+				close(finished)
+			})
+
+			// Hooks get time to clean up even when tasks exceeded timeout
+			var calls []string
+			m.OnShutdown("first", func(ctx context.Context) error {
+				calls = append(calls, "first")
+				if ctx.Err() != nil {
+					t.Error("expected context of first hook not to be done")
+				}
+				return errors.New("first failed")
+			})
+			m.OnShutdown("second", func(ctx context.Context) error {
+				calls = append(calls, "second")
+				if ctx.Err() != nil {
+					t.Error("expected context of second hook not to be done")
+				}
+				return errors.New("second failed")
+			})
+
+			err := m.Shutdown(tc.timeout)
+			if err == nil || err.Error() != "failed to shutdown second: second failed" {
+				t.Errorf("expected error of second hook, got %v", err)
+			}
+			if len(calls) != 2 || calls[0] != "second" || calls[1] != "first" {
+				t.Errorf("expected hooks in reverse order, got %v", calls)
+			}
+
+			select {
+			case <-finished:
+				if !tc.finished {
+					t.Error("expected shutdown not to wait for task")
+				}
+			default:
+				if tc.finished {
+					t.Error("expected task to be finished")
+				}
+			}
+
+			if m.Start() || m.Go(func() {}) {
+				t.Error("expected new tasks to be rejected")
+			}
+		})
+	}
+}
+
+func Test_GoPanic(t *testing.T) {
+	m := NewManager()
+
+	recovered := make(chan interface{}, 1)
+	m.SetPanicHandler(func(p interface{}, stack []byte) {
+		recovered <- p
+	})
+	m.Go(func() {
+		panic("boom")
+	})
+
+	if err := m.Shutdown(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case p := <-recovered:
+		if p != "boom" {
+			t.Errorf("expected boom, got %v", p)
+		}
+	default:
+		t.Error("expected panic to be passed to handler")
+	}
+}
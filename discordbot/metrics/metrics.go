@@ -0,0 +1,129 @@
+// Package metrics contains prometheus metrics of the bot, they are served by file server on /metrics
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "discordbot"
+
+var (
+	GatewayEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "gateway_events_total",
+		Help:      "Number of events received from discord gateway by type.",
+	}, []string{"type"})
+
+	Commands = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "commands_total",
+		Help:      "Number of called commands by plugin and result: ok, error, denied, usage or not_allowed.",
+	}, []string{"plugin", "command", "status"})
+
+	HandlerDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "handler_duration_seconds",
+		Help:      "Duration of plugin handlers, handler is command name or event type.",
+		Buckets:   []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"plugin", "handler"})
+
+	ConfifyQueue = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "confify_queue_depth",
+		Help:      "Number of images waiting for or being processed by confify.",
+	})
+
+	ConfifyStorage = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "confify_storage_bytes",
+		Help:      "Total size of images kept by confify.",
+	})
+
+	ChrisifyDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "confify_chrisify_duration_seconds",
+		Help:      "Duration of face swapping by chrisify or other backend.",
+		Buckets:   []float64{.5, 1, 2.5, 5, 10, 20, 30, 60},
+	})
+
+	TTSDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "tts_provider_duration_seconds",
+		Help:      "Duration of requests to text to speech provider.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	TTSErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tts_provider_errors_total",
+		Help:      "Number of failed requests to text to speech provider.",
+	})
+
+	DBErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_query_errors_total",
+		Help:      "Number of failed database queries by store and operation.",
+	}, []string{"store", "operation"})
+)
+
+// Event counts gateway events, it is added to session as handler of all events
+func Event(s *discordgo.Session, e *discordgo.Event) {
+	GatewayEvents.WithLabelValues(e.Type).Inc()
+}
+
+// Middleware is router middleware which counts commands and measures their duration
+func Middleware(next router.HandlerFunc) router.HandlerFunc {
+	return func(ctx *router.Context) error {
+		start := time.Now()
+		err := next(ctx)
+
+		command := strings.ToLower(ctx.Command.Name)
+		HandlerDuration.WithLabelValues(ctx.Command.Plugin, command).Observe(time.Since(start).Seconds())
+		Commands.WithLabelValues(ctx.Command.Plugin, command, Status(err)).Inc()
+
+		return err
+	}
+}
+
+// Since observes duration of plugin handler started at start
+func Since(plugin, handler string, start time.Time) {
+	HandlerDuration.WithLabelValues(plugin, handler).Observe(time.Since(start).Seconds())
+}
+
+// DBError counts err of store operation unless it is nil or sql.ErrNoRows, err is returned as is
+func DBError(store, operation string, err error) error {
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		DBErrors.WithLabelValues(store, operation).Inc()
+	}
+
+	return err
+}
+
+// Status returns outcome of command by its error: ok, error, denied, usage or not_allowed
+func Status(err error) string {
+	var (
+		denied *router.DeniedError
+		usage  *router.UsageError
+	)
+
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, router.ErrNotAllowed):
+		return "not_allowed"
+	case errors.As(err, &denied):
+		return "denied"
+	case errors.As(err, &usage):
+		return "usage"
+	default:
+		return "error"
+	}
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func Test_Middleware(t *testing.T) {
+	testCases := []struct {
+		name   string
+		err    error
+		status string
+	}{
+		{name: "ok", status: "ok"},
+		{name: "denied", err: &router.DeniedError{Reason: "slow down"}, status: "denied"},
+		{name: "usage", err: &router.UsageError{Reason: "user is required"}, status: "usage"},
+		{name: "not allowed", err: router.ErrNotAllowed, status: "not_allowed"},
+		{name: "error", err: errors.New("failed"), status: "error"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := &router.Context{Command: &router.Command{Name: "PTS", Plugin: "SmileyStats"}}
+			counter := Commands.WithLabelValues("SmileyStats", "pts", tc.status)
+			before := testutil.ToFloat64(counter)
+
+			err := Middleware(func(*router.Context) error { return tc.err })(ctx)
+			if err != tc.err {
+				t.Errorf("expected error %v, got %v", tc.err, err)
+			}
+			if got := testutil.ToFloat64(counter) - before; got != 1 {
+				t.Errorf("expected %s counter to increase by 1, got %v", tc.status, got)
+			}
+		})
+	}
+}
+
+func Test_DBError(t *testing.T) {
+	counter := DBErrors.WithLabelValues("racing", "join")
+
+	DBError("racing", "join", nil)
+	DBError("racing", "join", sql.ErrNoRows)
+	if got := testutil.ToFloat64(counter); got != 0 {
+		t.Errorf("expected no errors to be counted, got %v", got)
+	}
+
+	err := errors.New("connection refused")
+	if DBError("racing", "join", err) != err {
+		t.Error("expected error to be returned as is")
+	}
+	if got := testutil.ToFloat64(counter); got != 1 {
+		t.Errorf("expected error to be counted, got %v", got)
+	}
+}
@@ -0,0 +1,180 @@
+// Package permissions provides access control for router commands based on
+// discord permissions and roles configured per guild
+package permissions
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/paulvasilenko/discordbot/discordbot/discord"
+	"github.com/paulvasilenko/discordbot/discordbot/guildsettings"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+	"github.com/pkg/errors"
+)
+
+// Names maps names used in admin commands to discord permissions
+var Names = map[string]int64{
+	"administrator":    discordgo.PermissionAdministrator,
+	"manage_server":    discordgo.PermissionManageServer,
+	"manage_channels":  discordgo.PermissionManageChannels,
+	"manage_roles":     discordgo.PermissionManageRoles,
+	"manage_messages":  discordgo.PermissionManageMessages,
+	"manage_emojis":    discordgo.PermissionManageEmojis,
+	"kick_members":     discordgo.PermissionKickMembers,
+	"ban_members":      discordgo.PermissionBanMembers,
+	"moderate_members": discordgo.PermissionModerateMembers,
+	"mention_everyone": discordgo.PermissionMentionEveryone,
+	"attach_files":     discordgo.PermissionAttachFiles,
+	"embed_links":      discordgo.PermissionEmbedLinks,
+	"send_messages":    discordgo.PermissionSendMessages,
+}
+
+// Name returns human readable name of permissions, e.g. manage_messages
+func Name(permission int64) string {
+	var names []string
+	for name, p := range Names {
+		if permission&p == p {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Sprintf("%#x", permission)
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
+
+// Checker checks whether user is allowed to call command
+type Checker struct {
+	settings *guildsettings.Manager
+}
+
+// NewChecker returns checker which takes role rules from guild settings
+func NewChecker(settings *guildsettings.Manager) *Checker {
+	return &Checker{settings: settings}
+}
+
+// Middleware makes router deny commands to users without required permission or role
+func (c *Checker) Middleware(next router.HandlerFunc) router.HandlerFunc {
+	return func(ctx *router.Context) error {
+		if err := c.Check(ctx); err != nil {
+			return err
+		}
+		return next(ctx)
+	}
+}
+
+// Required returns permission and roles required to call command in guild
+func (c *Checker) Required(guildID string, command *router.Command) (int64, []string) {
+	rule := c.settings.Get(guildID).Command(command.Name)
+
+	permission := command.Permission
+	if rule.Permission != nil {
+		permission = *rule.Permission
+	}
+
+	return permission, rule.Roles
+}
+
+// Check returns router.DeniedError if user is not allowed to call command.
+// Administrators and guild owner are allowed to call any command
+func (c *Checker) Check(ctx *router.Context) error {
+	m := ctx.Message
+
+	if m.GuildID == "" {
+		if ctx.Command.Permission == 0 {
+			return nil
+		}
+		return &router.DeniedError{Reason: "This command is available only on servers"}
+	}
+
+	permission, roles := c.Required(m.GuildID, ctx.Command)
+	if permission == 0 && len(roles) == 0 {
+		return nil
+	}
+
+	perms, err := memberPermissions(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get member permissions")
+	}
+	if perms&discordgo.PermissionAdministrator != 0 {
+		return nil
+	}
+	if permission != 0 && perms&permission == permission {
+		return nil
+	}
+
+	if len(roles) > 0 {
+		memberRoles, err := memberRoles(ctx)
+		if err != nil {
+			return errors.Wrap(err, "failed to get member roles")
+		}
+		for _, role := range roles {
+			if contains(memberRoles, role) {
+				return nil
+			}
+		}
+	}
+
+	return &router.DeniedError{Reason: denyReason(ctx, permission, roles)}
+}
+
+func denyReason(ctx *router.Context, permission int64, roles []string) string {
+	var requirements []string
+	if permission != 0 {
+		requirements = append(requirements, fmt.Sprintf("**%s** permission", Name(permission)))
+	}
+	if len(roles) > 0 {
+		requirements = append(requirements, "one of roles: "+RoleNames(ctx.Session, ctx.Message.GuildID, roles))
+	}
+
+	return fmt.Sprintf(
+		"You need %s to use `%s%s`",
+		strings.Join(requirements, " or "),
+		ctx.Prefix,
+		ctx.Command.Name,
+	)
+}
+
+// RoleNames returns names of roles from session state, role ids are used for unknown roles
+func RoleNames(s discord.Session, guildID string, roles []string) string {
+	names := make([]string, len(roles))
+	for i, id := range roles {
+		names[i] = id
+		if role, err := s.Role(guildID, id); err == nil {
+			names[i] = "**" + role.Name + "**"
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+func memberPermissions(ctx *router.Context) (int64, error) {
+	if ctx.Interaction != nil && ctx.Interaction.Member != nil {
+		return ctx.Interaction.Member.Permissions, nil
+	}
+	return ctx.Session.UserChannelPermissions(ctx.Message.Author.ID, ctx.Message.ChannelID)
+}
+
+func memberRoles(ctx *router.Context) ([]string, error) {
+	if ctx.Message.Member != nil {
+		return ctx.Message.Member.Roles, nil
+	}
+
+	member, err := ctx.Session.GuildMember(ctx.Message.GuildID, ctx.Message.Author.ID)
+	if err != nil {
+		return nil, err
+	}
+	return member.Roles, nil
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
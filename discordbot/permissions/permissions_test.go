@@ -0,0 +1,87 @@
+package permissions
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/paulvasilenko/discordbot/discordbot/discord"
+	"github.com/paulvasilenko/discordbot/discordbot/guildsettings"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+)
+
+type memoryStore map[string]*guildsettings.Settings
+
+func (st memoryStore) Load(guildID string) (*guildsettings.Settings, error) {
+	return st[guildID], nil
+}
+
+func (st memoryStore) Save(s *guildsettings.Settings) error {
+	st[s.GuildID] = s
+	return nil
+}
+
+func Test_Check(t *testing.T) {
+	none := int64(0)
+	store := memoryStore{
+		"guild": {Commands: map[string]*guildsettings.CommandSettings{
+			"rreset":  {Roles: []string{"racers"}},
+			"confify": {Roles: []string{"artists"}},
+			"homog":   {Permission: &none},
+		}},
+	}
+	checker := NewChecker(guildsettings.NewManager(store, "!"))
+
+	commands := map[string]*router.Command{
+		"rreset":  {Name: "rreset", Permission: discordgo.PermissionManageMessages},
+		"confify": {Name: "confify"},
+		"homog":   {Name: "homog", Permission: discordgo.PermissionManageMessages},
+		"rjoin":   {Name: "rjoin"},
+	}
+
+	type test struct {
+		command     string
+		guildID     string
+		permissions int64
+		roles       []string
+		allowed     bool
+	}
+
+	testCases := []test{
+		{command: "rjoin", guildID: "guild", allowed: true},
+		{command: "rjoin", allowed: true},
+		{command: "rreset", allowed: false},
+		{command: "rreset", guildID: "guild", allowed: false},
+		{command: "rreset", guildID: "guild", permissions: discordgo.PermissionManageMessages, allowed: true},
+		{command: "rreset", guildID: "guild", roles: []string{"racers"}, allowed: true},
+		{command: "rreset", guildID: "guild", permissions: discordgo.PermissionAdministrator, allowed: true},
+		{command: "confify", guildID: "guild", allowed: false},
+		{command: "confify", guildID: "guild", roles: []string{"racers", "artists"}, allowed: true},
+		{command: "confify", guildID: "other", allowed: true},
+		{command: "homog", guildID: "guild", allowed: true},
+		{command: "homog", guildID: "other", allowed: false},
+	}
+
+	for _, v := range testCases {
+		member := &discordgo.Member{Permissions: v.permissions, Roles: v.roles}
+		ctx := &router.Context{
+			Session: discord.Wrap(&discordgo.Session{State: discordgo.NewState()}),
+			Message: &discordgo.Message{
+				GuildID: v.guildID,
+				Author:  &discordgo.User{ID: "user"},
+				Member:  member,
+			},
+			Interaction: &discordgo.Interaction{Member: member},
+			Command:     commands[v.command],
+			Prefix:      "!",
+		}
+
+		err := checker.Check(ctx)
+		if _, denied := err.(*router.DeniedError); err != nil && !denied {
+			t.Fatal(err)
+		}
+		if allowed := err == nil; allowed != v.allowed {
+			t.Errorf("command %s in guild %q with permissions %#x and roles %v: expected allowed: %v, error: %v",
+				v.command, v.guildID, v.permissions, v.roles, v.allowed, err)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package permissions
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/paulvasilenko/discordbot/discordbot/guildsettings"
+	"github.com/paulvasilenko/discordbot/discordbot/response"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+)
+
+const CommandPerm = "perm"
+
+// Permissions is a plugin which provides admin commands to change access rules of commands
+type Permissions struct {
+	checker  *Checker
+	settings *guildsettings.Manager
+	router   *router.Router
+}
+
+// NewPermissions is a constructor function for permissions plugin
+func NewPermissions(checker *Checker, settings *guildsettings.Manager, rt *router.Router) *Permissions {
+	return &Permissions{checker: checker, settings: settings, router: rt}
+}
+
+func (p *Permissions) Name() string {
+	return "Permissions"
+}
+
+// GetInfo returns map of info message
+func (p *Permissions) GetInfo() map[string]string {
+	return map[string]string{
+		"!perm":                                "Prints access rules of commands",
+		"!perm allow|revoke [command] [@role]": "Allows role to call command or revokes it",
+		"!perm require [command] [permission]": "Changes discord permission required by command, pass none to drop it. Known permissions: " + knownPermissions(),
+		"!perm reset [command]":                "Restores default access rules of command",
+	}
+}
+
+func (p *Permissions) Handlers() []interface{} {
+	return nil
+}
+
+func (p *Permissions) Init(s *discordgo.Session) error {
+	return nil
+}
+
+func (p *Permissions) Close() error {
+	return nil
+}
+
+// Commands returns commands of plugin handled by router
+func (p *Permissions) Commands() []*router.Command {
+	return []*router.Command{
+		{
+			Name:        CommandPerm,
+			Description: "Changes access rules of commands",
+			Args: []router.Arg{
+				{Name: "action", Type: router.ArgString, Optional: true},
+				{Name: "command", Type: router.ArgString, Optional: true},
+				{Name: "role", Type: router.ArgRole, Optional: true},
+				{Name: "permission", Type: router.ArgString, Optional: true},
+			},
+			Handler:    p.perm,
+			Permission: discordgo.PermissionAdministrator,
+		},
+	}
+}
+
+func (p *Permissions) perm(ctx *router.Context) error {
+	guildID := ctx.Message.GuildID
+	action := strings.ToLower(ctx.Args.String("action"))
+
+	if action == "" || action == "show" {
+		return response.Reply(ctx, response.Text(p.show(ctx)))
+	}
+
+	name := strings.TrimPrefix(ctx.Args.String("command"), ctx.Prefix)
+	command, ok := p.router.Command(name)
+	if !ok {
+		return ctx.UsageError(fmt.Sprintf("Unknown command %s", name))
+	}
+	if command.Name == CommandPerm {
+		return ctx.UsageError("Access rules of this command cannot be changed")
+	}
+
+	var update func(c *guildsettings.CommandSettings) error
+	switch action {
+	case "allow", "revoke":
+		role := ctx.Args.Role("role")
+		if role == "" {
+			return ctx.UsageError("Mention role to " + action)
+		}
+		update = func(c *guildsettings.CommandSettings) error {
+			c.Roles = remove(c.Roles, role)
+			if action == "allow" {
+				c.Roles = append(c.Roles, role)
+			}
+			return nil
+		}
+	case "require":
+		permissionName := strings.ToLower(ctx.Args.String("permission"))
+		permission, ok := Names[permissionName]
+		if permissionName == "none" {
+			permission, ok = 0, true
+		}
+		if !ok {
+			return ctx.UsageError(fmt.Sprintf("Unknown permission %s, known permissions: %s", permissionName, knownPermissions()))
+		}
+		update = func(c *guildsettings.CommandSettings) error {
+			c.Permission = &permission
+			return nil
+		}
+	case "reset":
+		update = func(c *guildsettings.CommandSettings) error {
+			c.Permission = nil
+			c.Roles = nil
+			return nil
+		}
+	default:
+		return ctx.UsageError(fmt.Sprintf("Unknown action %s", action))
+	}
+
+	err := p.settings.Update(guildID, func(s *guildsettings.Settings) error {
+		key := strings.ToLower(command.Name)
+		c := s.Command(key)
+		if err := update(c); err != nil {
+			return err
+		}
+
+		if s.Commands == nil {
+			s.Commands = map[string]*guildsettings.CommandSettings{}
+		}
+		s.Commands[key] = c
+		if c.Permission == nil && len(c.Roles) == 0 {
+			delete(s.Commands, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = ctx.Reply(fmt.Sprintf("`%s%s`: %s", ctx.Prefix, command.Name, p.rule(ctx, command)))
+	return err
+}
+
+func (p *Permissions) show(ctx *router.Context) string {
+	lines := []string{"Access rules:"}
+	for _, command := range p.router.Commands() {
+		if command.MessageCommand {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("`%s%s`: %s", ctx.Prefix, command.Name, p.rule(ctx, command)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (p *Permissions) rule(ctx *router.Context, command *router.Command) string {
+	permission, roles := p.checker.Required(ctx.Message.GuildID, command)
+
+	var parts []string
+	if permission != 0 {
+		parts = append(parts, Name(permission)+" permission")
+	}
+	if len(roles) > 0 {
+		parts = append(parts, "roles "+RoleNames(ctx.Session, ctx.Message.GuildID, roles))
+	}
+	if len(parts) == 0 {
+		return "everyone"
+	}
+	return strings.Join(parts, " or ")
+}
+
+func knownPermissions() string {
+	names := make([]string, 0, len(Names))
+	for name := range Names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func remove(list []string, value string) []string {
+	result := make([]string, 0, len(list))
+	for _, v := range list {
+		if v != value {
+			result = append(result, v)
+		}
+	}
+	return result
+}
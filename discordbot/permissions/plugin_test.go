@@ -0,0 +1,64 @@
+package permissions
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/paulvasilenko/discordbot/discordbot/discord/discordtest"
+	"github.com/paulvasilenko/discordbot/discordbot/guildsettings"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+)
+
+func Test_Perm(t *testing.T) {
+	settings := guildsettings.NewManager(memoryStore{}, "!")
+	checker := NewChecker(settings)
+	rt := router.New("!")
+	rt.Use(checker.Middleware)
+
+	p := NewPermissions(checker, settings, rt)
+	if err := rt.Add(p.Name(), p.Commands()...); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.Add("Racing", &router.Command{
+		Name:       "rreset",
+		Permission: discordgo.PermissionManageMessages,
+		Handler: func(ctx *router.Context) error {
+			_, err := ctx.Reply("Resetted racing")
+			return err
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := discordtest.NewSession()
+	s.Permissions["1"] = discordgo.PermissionAdministrator
+	s.Members["2"] = &discordgo.Member{Roles: []string{"5"}}
+	s.Members["3"] = &discordgo.Member{}
+	s.Roles["5"] = &discordgo.Role{ID: "5", Name: "racers"}
+
+	// Test cases share settings, so they depend on previous ones
+	testCases := []struct {
+		authorID string
+		message  string
+		expected []string
+	}{
+		{authorID: "3", message: "!perm", expected: []string{"You need **administrator** permission to use `!perm`"}},
+		{authorID: "1", message: "!perm allow rreset <@&5>", expected: []string{"`!rreset`: manage_messages permission or roles **racers**"}},
+		{authorID: "2", message: "!rreset", expected: []string{"Resetted racing"}},
+		{authorID: "3", message: "!rreset", expected: []string{"You need **manage_messages** permission or one of roles: **racers** to use `!rreset`"}},
+		{authorID: "1", message: "!perm require rreset none", expected: []string{"`!rreset`: roles **racers**"}},
+		{authorID: "1", message: "!perm require rreset fly", expected: []string{"Unknown permission fly, known permissions: " + knownPermissions() + "\nUsage: `!perm [action] [command] [role] [permission]`"}},
+		{authorID: "1", message: "!perm reset rreset", expected: []string{"`!rreset`: manage_messages permission"}},
+		{authorID: "1", message: "!perm", expected: []string{"Access rules:\n`!perm`: administrator permission\n`!rreset`: manage_messages permission"}},
+	}
+
+	for _, v := range testCases {
+		s.Reset()
+		rt.HandleMessage(s, discordtest.Message("channel", v.authorID, v.message))
+
+		if actual := s.Contents(); !reflect.DeepEqual(actual, v.expected) {
+			t.Errorf("%s sent %q: expected %q, actual %q", v.authorID, v.message, v.expected, actual)
+		}
+	}
+}
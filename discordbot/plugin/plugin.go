@@ -0,0 +1,278 @@
+// Package plugin describes the contract shared by all bot plugins and provides
+// registry which wires them into discord session
+package plugin
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/paulvasilenko/discordbot/discordbot/discord"
+	"github.com/paulvasilenko/discordbot/discordbot/lifecycle"
+	"github.com/paulvasilenko/discordbot/discordbot/metrics"
+	"github.com/paulvasilenko/discordbot/discordbot/report"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+	"github.com/paulvasilenko/discordbot/discordbot/scheduler"
+	"github.com/pkg/errors"
+)
+
+// Plugin is an interface every bot plugin implements
+type Plugin interface {
+	// Name returns unique name of plugin, used by !help and configuration
+	Name() string
+	// GetInfo returns map of commands supported by plugin and their descriptions
+	GetInfo() map[string]string
+	// Handlers returns list of discordgo event handlers of plugin
+	Handlers() []interface{}
+	// Init is called once before handlers are added to session
+	Init(s *discordgo.Session) error
+	// Close releases resources held by plugin
+	Close() error
+}
+
+// Commander is implemented by plugins which provide commands handled by router
+type Commander interface {
+	Commands() []*router.Command
+}
+
+// Tracked is implemented by plugins which run background work or need cleanup hooks,
+// lifecycle is set before Init
+type Tracked interface {
+	SetLifecycle(l *lifecycle.Manager)
+}
+
+// Scheduled is implemented by plugins which run jobs on timer, they register job handlers
+// in Init, scheduler is set before Init
+type Scheduled interface {
+	SetScheduler(s *scheduler.Scheduler)
+}
+
+// Guard decides whether plugin is allowed to handle events from channel of guild
+type Guard interface {
+	Allowed(guildID, channelID, plugin string) bool
+}
+
+// Registry keeps registered plugins in order of registration
+type Registry struct {
+	router    *router.Router
+	guard     Guard
+	lifecycle *lifecycle.Manager
+	reporter  *report.Reporter
+	scheduler *scheduler.Scheduler
+	plugins   []Plugin
+	byName    map[string]Plugin
+
+	// Handlers taking discord.Session, used by Handle
+	messageHandlers  []func(discord.Session, *discordgo.MessageCreate)
+	reactionHandlers []func(discord.Session, *discordgo.MessageReactionAdd)
+}
+
+// NewRegistry returns empty plugin registry which adds plugin commands to router
+func NewRegistry(rt *router.Router) *Registry {
+	return &Registry{
+		router:   rt,
+		reporter: report.NewReporter("", 0, 0),
+		byName:   map[string]Plugin{},
+	}
+}
+
+// SetGuard sets guard which is checked before plugin handlers are called
+func (r *Registry) SetGuard(g Guard) {
+	r.guard = g
+}
+
+// SetLifecycle sets manager which tracks plugin handlers, so they are finished before shutdown
+func (r *Registry) SetLifecycle(l *lifecycle.Manager) {
+	r.lifecycle = l
+}
+
+// SetReporter sets reporter of panics in plugin handlers, by default they are only logged
+func (r *Registry) SetReporter(rep *report.Reporter) {
+	r.reporter = rep
+}
+
+// SetScheduler sets scheduler passed to plugins implementing Scheduled
+func (r *Registry) SetScheduler(s *scheduler.Scheduler) {
+	r.scheduler = s
+}
+
+// Router returns command router of registry
+func (r *Registry) Router() *router.Router {
+	return r.router
+}
+
+// Register adds plugin to registry. Plugin names are case insensitive and must be unique
+func (r *Registry) Register(p Plugin) error {
+	name := strings.ToLower(p.Name())
+	if _, ok := r.byName[name]; ok {
+		return errors.Errorf("plugin %s is already registered", p.Name())
+	}
+
+	r.plugins = append(r.plugins, p)
+	r.byName[name] = p
+
+	return nil
+}
+
+// Plugins returns registered plugins in order of registration
+func (r *Registry) Plugins() []Plugin {
+	return append([]Plugin(nil), r.plugins...)
+}
+
+// Get returns plugin by its name
+func (r *Registry) Get(name string) (Plugin, bool) {
+	p, ok := r.byName[strings.ToLower(name)]
+	return p, ok
+}
+
+// Init initializes all registered plugins, adds their commands to router and
+// their handlers to session. Router handlers are added to session as well
+func (r *Registry) Init(s *discordgo.Session) error {
+	for _, p := range r.plugins {
+		if t, ok := p.(Tracked); ok && r.lifecycle != nil {
+			t.SetLifecycle(r.lifecycle)
+		}
+		if sc, ok := p.(Scheduled); ok && r.scheduler != nil {
+			sc.SetScheduler(r.scheduler)
+		}
+
+		if err := p.Init(s); err != nil {
+			return errors.Wrapf(err, "failed to init plugin %s", p.Name())
+		}
+
+		if c, ok := p.(Commander); ok {
+			if err := r.router.Add(p.Name(), c.Commands()...); err != nil {
+				return errors.Wrapf(err, "failed to add commands of plugin %s", p.Name())
+			}
+		}
+
+		for _, h := range p.Handlers() {
+			s.AddHandler(r.wrap(p.Name(), h))
+		}
+	}
+
+	s.AddHandler(r.router.MessageCreate)
+	s.AddHandler(r.router.InteractionCreate)
+
+	return nil
+}
+
+// wrap makes message handlers skip messages which call router commands,
+// makes handlers skip events from channels where plugin is not allowed
+// and events received during shutdown, panics of handlers are recovered and reported. Handlers taking discord.Session
+// are adapted to discordgo handler signatures
+func (r *Registry) wrap(plugin string, handler interface{}) interface{} {
+	switch h := handler.(type) {
+	case func(*discordgo.Session, *discordgo.MessageCreate):
+		return func(s *discordgo.Session, m *discordgo.MessageCreate) {
+			r.MessageCreate(plugin, func(discord.Session, *discordgo.MessageCreate) { h(s, m) })(discord.Wrap(s), m)
+		}
+	case func(*discordgo.Session, *discordgo.MessageReactionAdd):
+		return func(s *discordgo.Session, mr *discordgo.MessageReactionAdd) {
+			r.MessageReactionAdd(plugin, func(discord.Session, *discordgo.MessageReactionAdd) { h(s, mr) })(discord.Wrap(s), mr)
+		}
+	case func(discord.Session, *discordgo.MessageCreate):
+		wrapped := r.MessageCreate(plugin, h)
+		r.messageHandlers = append(r.messageHandlers, wrapped)
+		return func(s *discordgo.Session, m *discordgo.MessageCreate) {
+			wrapped(discord.Wrap(s), m)
+		}
+	case func(discord.Session, *discordgo.MessageReactionAdd):
+		wrapped := r.MessageReactionAdd(plugin, h)
+		r.reactionHandlers = append(r.reactionHandlers, wrapped)
+		return func(s *discordgo.Session, mr *discordgo.MessageReactionAdd) {
+			wrapped(discord.Wrap(s), mr)
+		}
+	}
+
+	return handler
+}
+
+// MessageCreate returns message handler of plugin with checks done for handlers added to session
+func (r *Registry) MessageCreate(plugin string, h func(discord.Session, *discordgo.MessageCreate)) func(discord.Session, *discordgo.MessageCreate) {
+	return func(s discord.Session, m *discordgo.MessageCreate) {
+		if r.router.IsCommand(m.GuildID, m.Content) || !r.allowed(m.GuildID, m.ChannelID, plugin) {
+			return
+		}
+		if !r.start() {
+			return
+		}
+		defer r.done()
+		defer metrics.Since(plugin, "message_create", time.Now())
+		defer r.reporter.Recover(plugin+" message handler", report.MessageFields(m.Message))
+		h(s, m)
+	}
+}
+
+// MessageReactionAdd returns reaction handler of plugin with checks done for handlers added to session
+func (r *Registry) MessageReactionAdd(plugin string, h func(discord.Session, *discordgo.MessageReactionAdd)) func(discord.Session, *discordgo.MessageReactionAdd) {
+	return func(s discord.Session, mr *discordgo.MessageReactionAdd) {
+		if !r.allowed(mr.GuildID, mr.ChannelID, plugin) {
+			return
+		}
+		if !r.start() {
+			return
+		}
+		defer r.done()
+		defer metrics.Since(plugin, "message_reaction_add", time.Now())
+		defer r.reporter.Recover(plugin+" reaction handler", report.ReactionFields(mr.MessageReaction))
+		h(s, mr)
+	}
+}
+
+// Handle passes event to plugin handlers and router like session does after Init,
+// so recorded events can be replayed with fake session. Handlers taking *discordgo.Session are skipped
+func (r *Registry) Handle(s discord.Session, event interface{}) {
+	switch e := event.(type) {
+	case *discordgo.MessageCreate:
+		for _, h := range r.messageHandlers {
+			h(s, e)
+		}
+		r.router.HandleMessage(s, e.Message)
+	case *discordgo.MessageReactionAdd:
+		for _, h := range r.reactionHandlers {
+			h(s, e)
+		}
+	case *discordgo.InteractionCreate:
+		r.router.HandleInteraction(s, e.Interaction)
+	}
+}
+
+func (r *Registry) start() bool {
+	return r.lifecycle == nil || r.lifecycle.Start()
+}
+
+func (r *Registry) done() {
+	if r.lifecycle != nil {
+		r.lifecycle.Done()
+	}
+}
+
+func (r *Registry) allowed(guildID, channelID, plugin string) bool {
+	return r.guard == nil || r.guard.Allowed(guildID, channelID, plugin)
+}
+
+// Close closes all registered plugins in reverse order and returns first occurred error
+func (r *Registry) Close() error {
+	var firstErr error
+	for i := len(r.plugins) - 1; i >= 0; i-- {
+		if err := r.plugins[i].Close(); err != nil && firstErr == nil {
+			firstErr = errors.Wrapf(err, "failed to close plugin %s", r.plugins[i].Name())
+		}
+	}
+
+	return firstErr
+}
+
+// Commands returns sorted list of commands from plugin info
+func Commands(p Plugin) []string {
+	info := p.GetInfo()
+	commands := make([]string, 0, len(info))
+	for command := range info {
+		commands = append(commands, command)
+	}
+	sort.Strings(commands)
+
+	return commands
+}
@@ -0,0 +1,131 @@
+package plugin
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/paulvasilenko/discordbot/discordbot/discord"
+	"github.com/paulvasilenko/discordbot/discordbot/discord/discordtest"
+	"github.com/paulvasilenko/discordbot/discordbot/lifecycle"
+	"github.com/paulvasilenko/discordbot/discordbot/report"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+)
+
+// channelGuard denies plugins in blocked channel
+type channelGuard struct{}
+
+func (channelGuard) Allowed(guildID, channelID, plugin string) bool {
+	return channelID != "blocked"
+}
+
+func Test_wrap(t *testing.T) {
+	testCases := []struct {
+		name     string
+		reaction bool
+		session  bool
+		content  string
+		channel  string
+		stopped  bool
+		panics   bool
+		called   bool
+		reported bool
+	}{
+		{name: "message", content: "hello", channel: "channel", called: true},
+		{name: "message with discordgo session", session: true, content: "hello", channel: "channel", called: true},
+		{name: "router command", content: "!ping", channel: "channel"},
+		{name: "router command with discordgo session", session: true, content: "!ping", channel: "channel"},
+		{name: "disallowed channel", content: "hello", channel: "blocked"},
+		{name: "shutdown", content: "hello", channel: "channel", stopped: true},
+		{name: "panic", content: "hello", channel: "channel", panics: true, called: true, reported: true},
+		{name: "reaction", reaction: true, channel: "channel", called: true},
+		{name: "reaction with discordgo session", reaction: true, session: true, channel: "channel", called: true},
+		{name: "reaction in disallowed channel", reaction: true, channel: "blocked"},
+		{name: "reaction on shutdown", reaction: true, channel: "channel", stopped: true},
+		{name: "reaction panic", reaction: true, channel: "channel", panics: true, called: true, reported: true},
+	}
+
+	for _, tc := range testCases {
+		rt := router.New("!")
+		if err := rt.Add("Ping", &router.Command{Name: "ping", Handler: func(ctx *router.Context) error { return nil }}); err != nil {
+			t.Fatal(err)
+		}
+		lc := lifecycle.NewManager()
+		if tc.stopped {
+			if err := lc.Shutdown(0); err != nil {
+				t.Fatal(err)
+			}
+		}
+		s := discordtest.NewSession()
+		reporter := report.NewReporter("admin", 5, time.Minute)
+		reporter.SetSession(s)
+
+		r := NewRegistry(rt)
+		r.SetGuard(channelGuard{})
+		r.SetLifecycle(lc)
+		r.SetReporter(reporter)
+
+		var called bool
+		handle := func() {
+			called = true
+			if tc.panics {
+				panic("boom")
+			}
+		}
+
+		var handler interface{}
+		switch {
+		case tc.reaction && tc.session:
+			handler = func(*discordgo.Session, *discordgo.MessageReactionAdd) { handle() }
+		case tc.reaction:
+			handler = func(discord.Session, *discordgo.MessageReactionAdd) { handle() }
+		case tc.session:
+			handler = func(*discordgo.Session, *discordgo.MessageCreate) { handle() }
+		default:
+			handler = func(discord.Session, *discordgo.MessageCreate) { handle() }
+		}
+
+		wrapped := r.wrap("Test", handler)
+		if tc.reaction {
+			wrapped.(func(*discordgo.Session, *discordgo.MessageReactionAdd))(&discordgo.Session{}, &discordgo.MessageReactionAdd{
+				MessageReaction: &discordgo.MessageReaction{ChannelID: tc.channel, GuildID: discordtest.GuildID},
+			})
+		} else {
+			wrapped.(func(*discordgo.Session, *discordgo.MessageCreate))(&discordgo.Session{}, &discordgo.MessageCreate{
+				Message: discordtest.Message(tc.channel, "10", tc.content),
+			})
+		}
+
+		if called != tc.called {
+			t.Errorf("%s: expected handler to be called: %v, actual: %v", tc.name, tc.called, called)
+		}
+		if reported := len(s.Contents()) == 1 && strings.HasPrefix(s.Contents()[0], "**Panic** in Test"); reported != tc.reported {
+			t.Errorf("%s: expected panic to be reported: %v, actual: %q", tc.name, tc.reported, s.Contents())
+		}
+		// Handler is finished even when it panics, so shutdown doesn't wait for it
+		if err := lc.Shutdown(time.Second); err != nil {
+			t.Errorf("%s: expected no running handlers, actual %v", tc.name, err)
+		}
+	}
+}
+
+func Test_Handle(t *testing.T) {
+	r := NewRegistry(router.New("!"))
+	r.SetGuard(channelGuard{})
+
+	var messages, reactions int
+	r.wrap("Test", func(discord.Session, *discordgo.MessageCreate) { messages++ })
+	r.wrap("Test", func(discord.Session, *discordgo.MessageReactionAdd) { reactions++ })
+	// Handlers taking *discordgo.Session are not replayed
+	r.wrap("Test", func(*discordgo.Session, *discordgo.MessageCreate) { messages++ })
+
+	s := discordtest.NewSession()
+	r.Handle(s, &discordgo.MessageCreate{Message: discordtest.Message("channel", "10", "hello")})
+	r.Handle(s, &discordgo.MessageCreate{Message: discordtest.Message("blocked", "10", "hello")})
+	r.Handle(s, &discordgo.MessageReactionAdd{MessageReaction: &discordgo.MessageReaction{ChannelID: "channel"}})
+
+	if messages != 1 || reactions != 1 {
+		t.Errorf("expected 1 message and 1 reaction, actual %d and %d", messages, reactions)
+	}
+}
@@ -1,8 +1,6 @@
 package racing
 
 import (
-	"context"
-	"database/sql"
 	"fmt"
 	"math/rand"
 	"strings"
@@ -10,20 +8,17 @@ import (
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/patrickmn/go-cache"
+	"github.com/paulvasilenko/discordbot/discordbot/response"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
 	log "github.com/sirupsen/logrus"
-	"gopkg.in/mgo.v2"
-	"gopkg.in/mgo.v2/bson"
 )
 
 const (
-	MongoCollectionRacing = "racing"
-	MongoDBRacing         = "racing"
-
-	CommandJoinRace   = "!rjoin"
-	CommandLeaveRace  = "!rleave"
-	CommandStartRace  = "!rstart"
-	CommandResetRace  = "!rreset"
-	CommandJoinedRace = "!rjoined"
+	CommandJoinRace   = "rjoin"
+	CommandLeaveRace  = "rleave"
+	CommandStartRace  = "rstart"
+	CommandResetRace  = "rreset"
+	CommandJoinedRace = "rjoined"
 
 	RacerEmoji = ":wheelchair:"
 
@@ -32,16 +27,14 @@ const (
 	RaceDelimiter   = "   "
 	RaceTrackLength = 25
 
-	MaximumDiscordMessageLength = 2000
-
 	SpeedCoefficient       = 70
 	BeingSlowedCoefficient = 10
 )
 
 type Racer struct {
-	ID       string `bson:"id"`
-	Username string `bson:"username"`
-	Emoticon string `bson:"emoticon"`
+	ID       string
+	Username string
+	Emoticon string
 }
 
 type RacerStats struct {
@@ -50,87 +43,102 @@ type RacerStats struct {
 }
 
 type Racing struct {
-	mysqlDbConn *sql.DB
-	mongoDbConn *mgo.Session
-	cache       *cache.Cache
+	store Store
+	cache *cache.Cache
 }
 
-// DEPRECATED: currently bot don't support Mongo, so this package doesn't work as well
-func NewRacing(mgoConn *mgo.Session, mysqlConn *sql.DB) (*Racing, error) {
-	if err := mgoConn.DB(MongoDBRacing).C(MongoCollectionRacing).EnsureIndex(mgo.Index{
-		Key:      []string{"id"},
-		Unique:   true,
-		DropDups: true,
-	}); err != nil {
-		return nil, err
-	}
-
+// NewRacing is a constructor function for racing plugin
+func NewRacing(store Store) *Racing {
 	return &Racing{
-		mongoDbConn: mgoConn,
-		cache:       cache.New(cache.NoExpiration, cache.NoExpiration),
-		mysqlDbConn: mysqlConn}, nil
+		store: store,
+		cache: cache.New(cache.NoExpiration, cache.NoExpiration),
+	}
 }
 
 func (q *Racing) GetInfo() map[string]string {
 	return map[string]string{
-		CommandResetRace:  "Removes all joined players to race",
-		CommandStartRace:  "Starts race with all joined players",
-		CommandJoinRace:   "Joins to race",
-		CommandLeaveRace:  "Leaves race",
-		CommandJoinedRace: "Prints list of joined racers",
+		"!" + CommandResetRace:  "Removes all joined players to race",
+		"!" + CommandStartRace:  "Starts race with all joined players",
+		"!" + CommandJoinRace:   "Joins to race",
+		"!" + CommandLeaveRace:  "Leaves race",
+		"!" + CommandJoinedRace: "Prints list of joined racers",
 	}
 }
 
-func (r *Racing) MessageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
-	switch {
-	case m.Content == CommandResetRace:
-		r.reset(s, m)
-	case m.Content == CommandStartRace:
-		r.start(s, m)
-	case strings.HasPrefix(m.Content, CommandJoinRace):
-		r.join(s, m)
-	case m.Content == CommandLeaveRace:
-		r.leave(s, m)
-	case m.Content == CommandJoinedRace:
-		r.joined(s, m)
+func (r *Racing) Name() string {
+	return "Racing"
+}
+
+func (r *Racing) Handlers() []interface{} {
+	return nil
+}
+
+func (r *Racing) Init(s *discordgo.Session) error {
+	return nil
+}
+
+func (r *Racing) Close() error {
+	return nil
+}
+
+// Commands returns commands of plugin handled by router
+func (r *Racing) Commands() []*router.Command {
+	info := r.GetInfo()
+	command := func(name string, handler router.HandlerFunc) *router.Command {
+		return &router.Command{Name: name, Description: info["!"+name], Handler: handler, Slash: true}
+	}
+
+	reset := command(CommandResetRace, r.reset)
+	reset.Permission = discordgo.PermissionManageMessages
+
+	return []*router.Command{
+		command(CommandJoinRace, r.join),
+		command(CommandLeaveRace, r.leave),
+		command(CommandStartRace, r.start),
+		reset,
+		command(CommandJoinedRace, r.joined),
 	}
 }
 
 // TODO: Refactor
-func (r *Racing) start(s *discordgo.Session, m *discordgo.MessageCreate) {
+func (r *Racing) start(ctx *router.Context) error {
 	if _, ok := r.cache.Get("Racing"); ok {
-		s.ChannelMessageSend(m.ChannelID, "Race is already started somewhere. Please, wait until it ends")
-		return
+		_, err := ctx.Reply("Race is already started somewhere. Please, wait until it ends")
+		return err
 	}
 
 	r.cache.Set("Racing", true, cache.NoExpiration)
 	defer r.cache.Delete("Racing")
 
-	var racers []*Racer
-	r.mongoDbConn.DB(MongoDBRacing).C(MongoCollectionRacing).Find(bson.M{}).All(&racers)
+	racers, err := r.store.Racers()
+	if err != nil {
+		return err
+	}
 
 	var winners []*RacerStats
 	ra := rand.New(rand.NewSource(time.Now().UnixNano()))
 	coef := make([]int, len(racers))
 
-	raceMessage, err := s.ChannelMessageSend(m.ChannelID, "Race is loading...")
+	raceMessage, err := ctx.Reply("Race is loading...")
 
 	if err != nil {
-		log.Println(err)
-		return
+		return err
 	}
 
 	racersMessage := racerPlaces(racers, coef)
 
-	s.ChannelMessageEdit(m.ChannelID, raceMessage.ID, "Ready!\n"+racersMessage)
+	if _, err := ctx.Edit(raceMessage, "Ready!\n"+racersMessage); err != nil {
+		log.Println("Race error: ", err)
+	}
 
 	for i := 0; i < 2; i++ {
 		time.Sleep(1 * time.Second)
-		switch i {
-		case 0:
-			s.ChannelMessageEdit(m.ChannelID, raceMessage.ID, "Steady!\n"+racersMessage)
-		case 1:
-			s.ChannelMessageEdit(m.ChannelID, raceMessage.ID, "GO!\n"+racersMessage)
+		status := "Steady!\n"
+		if i == 1 {
+			status = "GO!\n"
+		}
+		if _, err := ctx.Edit(raceMessage, status+racersMessage); err != nil {
+			log.Println("Race error: ", err)
 		}
 	}
 
@@ -174,19 +182,7 @@ func (r *Racing) start(s *discordgo.Session, m *discordgo.MessageCreate) {
 			}
 		}
 
-		message := racerPlaces(racers, coef)
-		_, err := s.ChannelMessageEdit(
-			m.ChannelID,
-			raceMessage.ID,
-			fmt.Sprintf(
-				"%s %s |Finish; Time: %02d:%02d\n%s",
-				"GO!",
-				strings.Repeat(RaceDelimiter, RaceTrackLength-1),
-				timer/60,
-				timer%60,
-				message,
-			),
-		)
+		_, err := ctx.Edit(raceMessage, raceStatus(racers, coef, timer))
 
 		if err != nil {
 			log.Println("Race error: ", err)
@@ -210,126 +206,97 @@ func (r *Racing) start(s *discordgo.Session, m *discordgo.MessageCreate) {
 		message += fmt.Sprintf("**#%d - %s;** Finish Time: %d s; Speed: %f m/s;\n", place, w.Racer.Username, w.FinishTime, float64(RaceTrackLength)/float64(w.FinishTime))
 	}
 
-	go r.saveRacerStats(winners)
+	err = response.Reply(ctx, response.Text(message))
+
+	// Saved after reply, so results are not delayed, but still while command is running,
+	// so shutdown waits for it
+	if err := r.store.SaveRace(time.Now(), winners); err != nil {
+		log.Println("failed to save race: ", err)
+	}
 
-	s.ChannelMessageSend(m.ChannelID, message)
+	return err
 }
 
-func (r *Racing) join(s *discordgo.Session, m *discordgo.MessageCreate) {
-	count, err := r.mongoDbConn.DB(MongoDBRacing).C(MongoCollectionRacing).Find(bson.M{}).Count()
+func (r *Racing) join(ctx *router.Context) error {
+	m := ctx.Message
+
+	racers, err := r.store.Racers()
 
 	if err != nil {
-		log.Println(err)
-		return
+		return err
 	}
 
-	// Assuming we're saving this user
-	count += 1
-
-	// Calculate maximum number of symbols might be used to represent race
-	// 35 is maximum username length + several symbols
-	// 22 is number of not calculated symbols used
-	symbolsThreshold := count*(RaceTrackLength*len(RaceDelimiter)+35) + len(RaceDelimiter)*RaceTrackLength + 22
+	// Race is shown in single edited message, so it must fit even when every racer is at finish
+	racer := &Racer{ID: m.Author.ID, Username: m.Author.Username, Emoticon: RacerEmoji}
+	racers = append(racers, racer)
+	finished := make([]int, len(racers))
+	for i := range finished {
+		finished[i] = RaceTrackLength
+	}
 
-	if symbolsThreshold >= MaximumDiscordMessageLength {
-		s.ChannelMessageSend(m.ChannelID, "You cannot join race: maximum number of racers are already joined")
-		return
+	if !response.Fits(raceStatus(racers, finished, 0)) {
+		_, err := ctx.Reply("You cannot join race: maximum number of racers are already joined")
+		return err
 	}
 
-	err = r.mongoDbConn.DB(MongoDBRacing).C(MongoCollectionRacing).Insert(
-		&Racer{ID: m.Author.ID, Username: m.Author.Username, Emoticon: RacerEmoji})
+	err = r.store.Join(racer)
 
 	if err != nil {
-		if strings.Contains(err.Error(), "duplicate") {
-			s.ChannelMessageSend(m.ChannelID, "You have already joined to race")
-			return
+		if err == ErrAlreadyJoined {
+			_, err := ctx.Reply("You have already joined to race")
+			return err
 		}
 
-		log.Println(err)
-		return
+		return err
 	}
 
-	s.ChannelMessageSend(m.ChannelID, m.Author.Username+" successfully joined to next race")
+	_, err = ctx.Reply(m.Author.Username + " successfully joined to next race")
+	return err
 }
 
-func (r *Racing) leave(s *discordgo.Session, m *discordgo.MessageCreate) {
-	r.mongoDbConn.DB(MongoDBRacing).C(MongoCollectionRacing).Remove(bson.M{"id": m.Author.ID})
-	s.ChannelMessageSend(m.ChannelID, m.Author.Username+" successfully left next race")
-}
+func (r *Racing) leave(ctx *router.Context) error {
+	m := ctx.Message
 
-func (r *Racing) reset(s *discordgo.Session, m *discordgo.MessageCreate) {
-	r.mongoDbConn.DB(MongoDBRacing).C(MongoCollectionRacing).RemoveAll(bson.M{})
-	s.ChannelMessageSend(m.ChannelID, "Resetted racing")
+	if err := r.store.Leave(m.Author.ID); err != nil {
+		return err
+	}
+	_, err := ctx.Reply(m.Author.Username + " successfully left next race")
+	return err
 }
 
-func (r *Racing) joined(s *discordgo.Session, m *discordgo.MessageCreate) {
-	var racers []*Racer
-	r.mongoDbConn.DB(MongoDBRacing).C(MongoCollectionRacing).Find(bson.M{}).All(&racers)
-
-	message := ""
-
-	for k, v := range racers {
-		message += fmt.Sprintf("#%d - %s \n", k+1, v.Username)
+func (r *Racing) reset(ctx *router.Context) error {
+	if err := r.store.Reset(); err != nil {
+		return err
 	}
-
-	s.ChannelMessageSend(m.ChannelID, "Racers:\n"+message)
+	_, err := ctx.Reply("Resetted racing")
+	return err
 }
 
-func (r *Racing) saveRacerStats(winners []*RacerStats) {
-	tx, err := r.mysqlDbConn.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelDefault, ReadOnly: false})
-
+func (r *Racing) joined(ctx *router.Context) error {
+	racers, err := r.store.Racers()
 	if err != nil {
-		log.Println(err)
-		return
-	}
-
-	if r, err := tx.Query(`INSERT INTO raceHistory (raceDatetime) VALUES (?);`, time.Now().Format(DatetimeLayout)); err != nil {
-		r.Close()
-		log.Println(err)
-		return
-	} else {
-		r.Close()
+		return err
 	}
 
-	var raceId int
-
-	if err := tx.QueryRow("SELECT LAST_INSERT_ID();").Scan(&raceId); err != nil {
-		log.Println(err)
-		tx.Rollback()
-		return
-	}
+	message := ""
 
-	query := `
-		INSERT INTO raceHistoryStats
-			(raceId, racerId, racerUsername, racerSpeed, racerTime, place)
-		VALUES`
-
-	values := make([]string, len(winners))
-
-	for i, racer := range winners {
-		values[i] = fmt.Sprintf(
-			"(%d, '%s', '%s', %f, %d, %d)",
-			raceId,
-			racer.Racer.ID,
-			racer.Racer.Username,
-			float64(RaceTrackLength)/float64(racer.FinishTime),
-			racer.FinishTime,
-			i,
-		)
+	for k, v := range racers {
+		message += fmt.Sprintf("#%d - %s \n", k+1, v.Username)
 	}
 
-	query += strings.Join(values, ",")
-
-	if r, err := tx.Query(query + ";"); err != nil {
-		r.Close()
-		log.Println(err)
-		tx.Rollback()
-		return
-	} else {
-		r.Close()
-	}
+	return response.Reply(ctx, response.Text("Racers:\n"+message))
+}
 
-	tx.Commit()
+// raceStatus renders race track with finish line and elapsed time
+func raceStatus(racers []*Racer, coef []int, timer int) string {
+	return fmt.Sprintf(
+		"%s %s |Finish; Time: %02d:%02d\n%s",
+		"GO!",
+		strings.Repeat(RaceDelimiter, RaceTrackLength-1),
+		timer/60,
+		timer%60,
+		racerPlaces(racers, coef),
+	)
 }
 
 func racerPlaces(racers []*Racer, coef []int) string {
@@ -0,0 +1,108 @@
+package racing
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/paulvasilenko/discordbot/discordbot/discord/discordtest"
+	"github.com/paulvasilenko/discordbot/discordbot/response"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+	"github.com/paulvasilenko/discordbot/discordbot/storage"
+	"github.com/paulvasilenko/discordbot/migrations"
+)
+
+func newSQLiteStore(t *testing.T) Store {
+	db, err := storage.Open(storage.SQLite, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	runner, err := migrations.NewRunner(db.DB, db.Driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runner.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	return NewStore(db)
+}
+
+func Test_Commands(t *testing.T) {
+	r := NewRacing(newSQLiteStore(t))
+	rt := router.New("!")
+	if err := rt.Add(r.Name(), r.Commands()...); err != nil {
+		t.Fatal(err)
+	}
+
+	// Test cases share lobby, so they depend on previous ones
+	testCases := []struct {
+		author   string
+		message  string
+		racing   bool
+		expected []string
+	}{
+		{author: "panda", message: "!rjoined", expected: []string{"Racers:\n"}},
+		{author: "panda", message: "!rjoin", expected: []string{"panda successfully joined to next race"}},
+		{author: "panda", message: "!rjoin", expected: []string{"You have already joined to race"}},
+		{author: "bear", message: "!rjoin", expected: []string{"bear successfully joined to next race"}},
+		{author: "bear", message: "!rjoined", expected: []string{"Racers:\n#1 - panda \n#2 - bear \n"}},
+		{author: "panda", message: "!rleave", expected: []string{"panda successfully left next race"}},
+		{author: "bear", message: "!rjoined", expected: []string{"Racers:\n#1 - bear \n"}},
+		{author: "bear", message: "!rstart", racing: true, expected: []string{"Race is already started somewhere. Please, wait until it ends"}},
+		{author: "bear", message: "!rreset", expected: []string{"Resetted racing"}},
+		{author: "bear", message: "!rjoined", expected: []string{"Racers:\n"}},
+	}
+
+	for _, v := range testCases {
+		if v.racing {
+			r.cache.Set("Racing", true, cache.NoExpiration)
+		}
+
+		s := discordtest.NewSession()
+		rt.HandleMessage(s, discordtest.Message("channel", v.author, v.message))
+		r.cache.Delete("Racing")
+
+		if actual := s.Contents(); !reflect.DeepEqual(actual, v.expected) {
+			t.Errorf("%s sent %q: expected %q, actual %q", v.author, v.message, v.expected, actual)
+		}
+	}
+}
+
+func Test_JoinLimit(t *testing.T) {
+	store := newSQLiteStore(t)
+	r := NewRacing(store)
+	rt := router.New("!")
+	if err := rt.Add(r.Name(), r.Commands()...); err != nil {
+		t.Fatal(err)
+	}
+
+	s := discordtest.NewSession()
+	for i := 0; i < 50; i++ {
+		rt.HandleMessage(s, discordtest.Message("channel", "racer"+strconv.Itoa(i), "!rjoin"))
+	}
+
+	racers, err := store.Racers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(racers) == 0 || len(racers) == 50 {
+		t.Fatalf("expected join to be limited, %d racers joined", len(racers))
+	}
+
+	finished := make([]int, len(racers))
+	for i := range finished {
+		finished[i] = RaceTrackLength
+	}
+	if status := raceStatus(racers, finished, 0); !response.Fits(status) {
+		t.Errorf("race of %d racers doesn't fit into message: %d", len(racers), len(status))
+	}
+
+	contents := s.Contents()
+	if expected := "You cannot join race: maximum number of racers are already joined"; contents[len(contents)-1] != expected {
+		t.Errorf("expected %q, actual %q", expected, contents[len(contents)-1])
+	}
+}
@@ -0,0 +1,147 @@
+package racing
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/paulvasilenko/discordbot/discordbot/metrics"
+	"github.com/paulvasilenko/discordbot/discordbot/storage"
+	"github.com/pkg/errors"
+)
+
+// ErrAlreadyJoined is returned when racer joins lobby twice
+var ErrAlreadyJoined = errors.New("racer has already joined")
+
+// Store keeps racers joined to next race and history of races
+type Store interface {
+	// Join adds racer to lobby of next race
+	Join(r *Racer) error
+	// Leave removes racer from lobby
+	Leave(racerID string) error
+	// Reset removes all racers from lobby
+	Reset() error
+	// Racers returns racers of lobby in order they joined
+	Racers() ([]*Racer, error)
+	// SaveRace saves results of finished race
+	SaveRace(at time.Time, winners []*RacerStats) error
+}
+
+// NewStore returns store implementation for driver of database
+func NewStore(db *storage.DB) Store {
+	if db.Driver == storage.SQLite {
+		return NewSQLiteStore(db.DB)
+	}
+
+	return NewMySQLStore(db.DB)
+}
+
+// sqlStore implements queries shared by MySQL and SQLite
+type sqlStore struct {
+	db *sql.DB
+
+	joinQuery string
+}
+
+// NewMySQLStore returns store working with raceLobby and raceHistory tables in MySQL
+func NewMySQLStore(db *sql.DB) Store {
+	return &sqlStore{
+		db:        db,
+		joinQuery: `INSERT IGNORE INTO raceLobby (racerId, racerUsername, emoticon) VALUES (?, ?, ?)`,
+	}
+}
+
+// NewSQLiteStore returns store working with raceLobby and raceHistory tables in SQLite
+func NewSQLiteStore(db *sql.DB) Store {
+	return &sqlStore{
+		db:        db,
+		joinQuery: `INSERT OR IGNORE INTO raceLobby (racerId, racerUsername, emoticon) VALUES (?, ?, ?)`,
+	}
+}
+
+func (st *sqlStore) Join(r *Racer) error {
+	res, err := st.db.Exec(st.joinQuery, r.ID, r.Username, r.Emoticon)
+	if err != nil {
+		return metrics.DBError("racing", "join", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrAlreadyJoined
+	}
+
+	return nil
+}
+
+func (st *sqlStore) Leave(racerID string) error {
+	_, err := st.db.Exec(`DELETE FROM raceLobby WHERE racerId = ?`, racerID)
+	return metrics.DBError("racing", "leave", err)
+}
+
+func (st *sqlStore) Reset() error {
+	_, err := st.db.Exec(`DELETE FROM raceLobby`)
+	return metrics.DBError("racing", "reset", err)
+}
+
+func (st *sqlStore) Racers() ([]*Racer, error) {
+	rows, err := st.db.Query(`SELECT racerId, racerUsername, emoticon FROM raceLobby ORDER BY id`)
+	if err != nil {
+		return nil, metrics.DBError("racing", "racers", err)
+	}
+	defer rows.Close()
+
+	var racers []*Racer
+	for rows.Next() {
+		r := &Racer{}
+		if err := rows.Scan(&r.ID, &r.Username, &r.Emoticon); err != nil {
+			return nil, err
+		}
+		racers = append(racers, r)
+	}
+
+	return racers, rows.Err()
+}
+
+func (st *sqlStore) SaveRace(at time.Time, winners []*RacerStats) error {
+	return metrics.DBError("racing", "save_race", st.saveRace(at, winners))
+}
+
+func (st *sqlStore) saveRace(at time.Time, winners []*RacerStats) error {
+	tx, err := st.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO raceHistory (raceDatetime) VALUES (?)`, at.Format(DatetimeLayout))
+	if err != nil {
+		return errors.Wrap(err, "failed to save race")
+	}
+
+	raceID, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	for i, racer := range winners {
+		if _, err := tx.Exec(
+			`INSERT INTO raceHistoryStats
+				(raceId, racerId, racerUsername, racerSpeed, racerTime, place)
+			VALUES
+				(?, ?, ?, ?, ?, ?)`,
+			raceID,
+			racer.Racer.ID,
+			racer.Racer.Username,
+			float64(RaceTrackLength)/float64(racer.FinishTime),
+			racer.FinishTime,
+			i,
+		); err != nil {
+			return errors.Wrapf(err, "failed to save stats of racer %s", racer.Racer.ID)
+		}
+	}
+
+	return tx.Commit()
+}
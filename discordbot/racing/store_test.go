@@ -0,0 +1,76 @@
+package racing
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/paulvasilenko/discordbot/discordbot/storage"
+	"github.com/paulvasilenko/discordbot/migrations"
+)
+
+func Test_sqlStore(t *testing.T) {
+	db, err := storage.Open(storage.SQLite, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	runner, err := migrations.NewRunner(db.DB, db.Driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runner.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	st := NewStore(db)
+	panda := &Racer{ID: "10", Username: "panda", Emoticon: RacerEmoji}
+	bear := &Racer{ID: "20", Username: "bear", Emoticon: RacerEmoji}
+
+	for _, r := range []*Racer{panda, bear} {
+		if err := st.Join(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := st.Join(panda); err != ErrAlreadyJoined {
+		t.Errorf("expected ErrAlreadyJoined, got %v", err)
+	}
+
+	racers, err := st.Racers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(racers, []*Racer{panda, bear}) {
+		t.Errorf("expected racers in join order, got %+v", racers)
+	}
+
+	if err := st.SaveRace(time.Now(), []*RacerStats{
+		{Racer: bear, FinishTime: 13},
+		{Racer: panda, FinishTime: 15},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var place int
+	if err := db.QueryRow(`SELECT place FROM raceHistoryStats WHERE racerId = ?`, panda.ID).Scan(&place); err != nil {
+		t.Fatal(err)
+	}
+	if place != 1 {
+		t.Errorf("expected place 1, got %d", place)
+	}
+
+	if err := st.Leave(panda.ID); err != nil {
+		t.Fatal(err)
+	}
+	if racers, _ := st.Racers(); len(racers) != 1 {
+		t.Errorf("expected one racer after leave, got %+v", racers)
+	}
+
+	if err := st.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if racers, _ := st.Racers(); len(racers) != 0 {
+		t.Errorf("expected empty lobby after reset, got %+v", racers)
+	}
+}
@@ -0,0 +1,200 @@
+// Package ratelimit provides token bucket rate limiting of commands per user,
+// channel, guild or globally
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+	"github.com/pkg/errors"
+)
+
+// Scope describes whose calls are counted together
+type Scope string
+
+const (
+	ScopeUser    Scope = "user"
+	ScopeChannel Scope = "channel"
+	ScopeGuild   Scope = "guild"
+	ScopeGlobal  Scope = "global"
+)
+
+// Rule allows Limit calls per Period in scope. Calls are restored evenly during period,
+// so rule with limit 1 works as a cooldown
+type Rule struct {
+	Scope  Scope         `yaml:"Scope"`
+	Limit  int           `yaml:"Limit"`
+	Period time.Duration `yaml:"Period"`
+}
+
+// Validate checks rule values
+func (r Rule) Validate() error {
+	switch r.Scope {
+	case ScopeUser, ScopeChannel, ScopeGuild, ScopeGlobal:
+	default:
+		return errors.Errorf("unknown scope %q", r.Scope)
+	}
+	if r.Limit < 1 {
+		return errors.New("limit must be positive")
+	}
+	if r.Period <= 0 {
+		return errors.New("period must be positive")
+	}
+	return nil
+}
+
+// Bucket is a bucket of calls with key, Limit calls are restored evenly during Period
+type Bucket struct {
+	Key    string
+	Limit  int
+	Period time.Duration
+}
+
+// Store keeps state of buckets. It's an interface to make possible sharing state between instances
+type Store interface {
+	// TakeAll takes one call from every bucket. If one of them is empty, nothing is
+	// taken and time until calls are available in all of them is returned
+	TakeAll(buckets []Bucket, now time.Time) (time.Duration, error)
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// MemoryStore keeps buckets in process memory
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets *cache.Cache
+}
+
+// NewMemoryStore returns empty memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: cache.New(time.Hour, 10*time.Minute)}
+}
+
+func (st *MemoryStore) TakeAll(buckets []Bucket, now time.Time) (time.Duration, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	// Every bucket is checked before calls are taken, so denied call doesn't spend calls of other buckets
+	states := make([]*bucket, len(buckets))
+	var wait time.Duration
+	for i, bk := range buckets {
+		b := &bucket{tokens: float64(bk.Limit), updated: now}
+		if v, ok := st.buckets.Get(bk.Key); ok {
+			b = v.(*bucket)
+		}
+
+		refillRate := float64(bk.Limit) / float64(bk.Period)
+		b.tokens = math.Min(float64(bk.Limit), b.tokens+float64(now.Sub(b.updated))*refillRate)
+		b.updated = now
+
+		if b.tokens < 1 {
+			if w := time.Duration(math.Ceil((1 - b.tokens) / refillRate)); w > wait {
+				wait = w
+			}
+		}
+		states[i] = b
+	}
+	if wait > 0 {
+		return wait, nil
+	}
+
+	for i, bk := range buckets {
+		states[i].tokens--
+		// bucket is full again after period, so there is no need to keep it longer
+		st.buckets.Set(bk.Key, states[i], bk.Period)
+	}
+
+	return 0, nil
+}
+
+// Limiter checks rules of commands
+type Limiter struct {
+	store Store
+	rules map[string][]Rule
+	now   func() time.Time
+}
+
+// NewLimiter returns limiter with rules by command names
+func NewLimiter(store Store, rules map[string][]Rule) (*Limiter, error) {
+	normalized := map[string][]Rule{}
+	for command, commandRules := range rules {
+		for _, rule := range commandRules {
+			if err := rule.Validate(); err != nil {
+				return nil, errors.Wrapf(err, "invalid rate limit of %s", command)
+			}
+		}
+		normalized[strings.ToLower(command)] = commandRules
+	}
+
+	return &Limiter{store: store, rules: normalized, now: time.Now}, nil
+}
+
+// Allow takes call of command from all buckets of command rules and returns
+// time to wait if one of them is exhausted, then nothing is taken
+func (l *Limiter) Allow(command, guildID, channelID, userID string) (time.Duration, error) {
+	command = strings.ToLower(command)
+
+	// Rules of the same scope are told apart by index, so every rule has its own bucket
+	var buckets []Bucket
+	for i, rule := range l.rules[command] {
+		id := ""
+		switch rule.Scope {
+		case ScopeUser:
+			id = userID
+		case ScopeChannel:
+			id = channelID
+		case ScopeGuild:
+			id = guildID
+		}
+
+		buckets = append(buckets, Bucket{Key: fmt.Sprintf("%s:%d:%s:%s", command, i, rule.Scope, id), Limit: rule.Limit, Period: rule.Period})
+	}
+	if len(buckets) == 0 {
+		return 0, nil
+	}
+
+	wait, err := l.store.TakeAll(buckets, l.now())
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to take call from buckets")
+	}
+
+	return wait, nil
+}
+
+// Middleware makes router deny commands which exceed rate limits
+func (l *Limiter) Middleware(next router.HandlerFunc) router.HandlerFunc {
+	return func(ctx *router.Context) error {
+		if ctx.Command.Unlimited != nil && ctx.Command.Unlimited(ctx) {
+			return next(ctx)
+		}
+
+		m := ctx.Message
+		wait, err := l.Allow(ctx.Command.Name, m.GuildID, m.ChannelID, m.Author.ID)
+		if err != nil {
+			return err
+		}
+		if wait > 0 {
+			return &router.DeniedError{Reason: fmt.Sprintf(
+				"Slow down, `%s%s` will be available in %s",
+				ctx.Prefix,
+				ctx.Command.Name,
+				FormatWait(wait),
+			)}
+		}
+
+		return next(ctx)
+	}
+}
+
+// FormatWait rounds wait time up to seconds
+func FormatWait(wait time.Duration) string {
+	return (wait + time.Second - 1).Truncate(time.Second).String()
+}
@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_Allow(t *testing.T) {
+	limiter, err := NewLimiter(NewMemoryStore(), map[string][]Rule{
+		"Confify": {{Scope: ScopeUser, Limit: 1, Period: 30 * time.Second}},
+		"rstart":  {{Scope: ScopeChannel, Limit: 2, Period: time.Minute}},
+		"rjoin": {
+			{Scope: ScopeUser, Limit: 2, Period: time.Minute},
+			{Scope: ScopeChannel, Limit: 1, Period: time.Minute},
+		},
+		"rreset": {
+			{Scope: ScopeUser, Limit: 1, Period: time.Minute},
+			{Scope: ScopeUser, Limit: 2, Period: time.Hour},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(0, 0)
+	limiter.now = func() time.Time { return now }
+
+	type test struct {
+		after   time.Duration
+		command string
+		channel string
+		user    string
+		wait    time.Duration
+	}
+
+	testCases := []test{
+		{command: "confify", user: "1"},
+		{command: "confify", user: "1", wait: 30 * time.Second},
+		{command: "confify", user: "2"},
+		{after: 10 * time.Second, command: "confify", user: "1", wait: 20 * time.Second},
+		{after: 20 * time.Second, command: "confify", user: "1"},
+		{command: "rstart", channel: "a", user: "1"},
+		{command: "rstart", channel: "a", user: "2"},
+		{command: "rstart", channel: "a", user: "3", wait: 30 * time.Second},
+		{command: "rstart", channel: "b", user: "3"},
+		{after: 30 * time.Second, command: "rstart", channel: "a", user: "3"},
+		{command: "homog", user: "1"},
+		{command: "homog", user: "1"},
+		// Call denied by channel rule doesn't spend call of user
+		{command: "rjoin", channel: "a", user: "1"},
+		{command: "rjoin", channel: "a", user: "1", wait: time.Minute},
+		{command: "rjoin", channel: "b", user: "1"},
+		{command: "rjoin", channel: "c", user: "1", wait: 30 * time.Second},
+		// Rules of the same scope have their own buckets
+		{command: "rreset", user: "1"},
+		{command: "rreset", user: "1", wait: time.Minute},
+		{after: time.Minute, command: "rreset", user: "1"},
+		{after: time.Minute, command: "rreset", user: "1", wait: 28 * time.Minute},
+	}
+
+	for i, v := range testCases {
+		now = now.Add(v.after)
+		wait, err := limiter.Allow(v.command, "guild", v.channel, v.user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wait != v.wait {
+			t.Errorf("case %d: expected wait: %v actual: %v", i, v.wait, wait)
+		}
+	}
+}
+
+func Test_NewLimiter(t *testing.T) {
+	invalid := []Rule{
+		{Scope: "planet", Limit: 1, Period: time.Second},
+		{Scope: ScopeUser, Limit: 0, Period: time.Second},
+		{Scope: ScopeUser, Limit: 1},
+	}
+
+	for _, rule := range invalid {
+		if _, err := NewLimiter(NewMemoryStore(), map[string][]Rule{"cmd": {rule}}); err == nil {
+			t.Errorf("expected error for rule %+v", rule)
+		}
+	}
+}
+
+func Test_FormatWait(t *testing.T) {
+	if actual := FormatWait(1500 * time.Millisecond); actual != "2s" {
+		t.Error("expected: 2s actual:", actual)
+	}
+}
@@ -0,0 +1,131 @@
+// Package recorder writes incoming gateway events to JSONL file and replays
+// recorded events through plugin handlers with fake discord session
+package recorder
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	MessageCreate      = "MESSAGE_CREATE"
+	MessageReactionAdd = "MESSAGE_REACTION_ADD"
+	InteractionCreate  = "INTERACTION_CREATE"
+)
+
+// DefaultTypes are types of events handled by plugins, they are recorded if no types are configured
+var DefaultTypes = []string{MessageCreate, MessageReactionAdd, InteractionCreate}
+
+// Event is a single recorded gateway event, data is a payload received from discord
+type Event struct {
+	Time time.Time       `json:"time"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Decode returns discordgo struct of event, nil is returned for types which cannot be replayed
+func (e *Event) Decode() (interface{}, error) {
+	var event interface{}
+	switch e.Type {
+	case MessageCreate:
+		event = &discordgo.MessageCreate{}
+	case MessageReactionAdd:
+		event = &discordgo.MessageReactionAdd{}
+	case InteractionCreate:
+		event = &discordgo.InteractionCreate{}
+	default:
+		return nil, nil
+	}
+
+	if err := json.Unmarshal(e.Data, event); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode %s event", e.Type)
+	}
+
+	return event, nil
+}
+
+// Recorder writes gateway events of configured types as JSON lines
+type Recorder struct {
+	mu     sync.Mutex
+	enc    *json.Encoder
+	closer io.Closer
+	types  map[string]bool
+}
+
+// New returns recorder writing events to w, DefaultTypes are recorded if types are empty
+func New(w io.Writer, types []string) *Recorder {
+	if len(types) == 0 {
+		types = DefaultTypes
+	}
+
+	r := &Recorder{enc: json.NewEncoder(w), types: map[string]bool{}}
+	for _, t := range types {
+		r.types[t] = true
+	}
+	if c, ok := w.(io.Closer); ok {
+		r.closer = c
+	}
+
+	return r
+}
+
+// Open returns recorder appending events to file, file is readable only by owner as it contains messages
+func Open(path string, types []string) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open recording")
+	}
+
+	return New(f, types), nil
+}
+
+// Event is a discordgo handler which records every received event
+func (r *Recorder) Event(s *discordgo.Session, e *discordgo.Event) {
+	if !r.types[e.Type] {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.enc.Encode(&Event{Time: time.Now().UTC(), Type: e.Type, Data: e.RawData}); err != nil {
+		log.Println("failed to record event: ", err)
+	}
+}
+
+// Close closes file of recording
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closer == nil {
+		return nil
+	}
+
+	return r.closer.Close()
+}
+
+// Read returns all events of recording
+func Read(r io.Reader) ([]*Event, error) {
+	var events []*Event
+
+	dec := json.NewDecoder(r)
+	for {
+		e := &Event{}
+		err := dec.Decode(e)
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read event #%d", len(events)+1)
+		}
+		events = append(events, e)
+	}
+}
@@ -0,0 +1,124 @@
+package recorder
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/paulvasilenko/discordbot/discordbot/discord"
+	"github.com/paulvasilenko/discordbot/discordbot/plugin"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+)
+
+// echo is a plugin which repeats messages and counts reactions
+type echo struct{}
+
+func (echo) Name() string                    { return "Echo" }
+func (echo) GetInfo() map[string]string      { return nil }
+func (echo) Init(s *discordgo.Session) error { return nil }
+func (echo) Close() error                    { return nil }
+func (echo) Handlers() []interface{} {
+	return []interface{}{
+		func(s discord.Session, m *discordgo.MessageCreate) {
+			s.ChannelMessageSend(m.ChannelID, m.Content)
+		},
+		func(s discord.Session, mr *discordgo.MessageReactionAdd) {
+			m, err := s.ChannelMessage(mr.ChannelID, mr.MessageID)
+			if err != nil {
+				s.ChannelMessageSend(mr.ChannelID, err.Error())
+				return
+			}
+			s.MessageReactionAdd(mr.ChannelID, m.ID, mr.Emoji.Name)
+		},
+	}
+}
+
+func Test_Replay(t *testing.T) {
+	rec := &bytes.Buffer{}
+	r := New(rec, nil)
+
+	events := []struct {
+		eventType string
+		data      interface{}
+	}{
+		{MessageCreate, &discordgo.Message{ID: "100", ChannelID: "1", Content: "hello", Author: &discordgo.User{ID: "10", Username: "panda"}}},
+		{"TYPING_START", &discordgo.TypingStart{ChannelID: "1", UserID: "10"}},
+		{MessageCreate, &discordgo.Message{ID: "101", ChannelID: "1", Content: "!ping", Author: &discordgo.User{ID: "20", Username: "bear"}}},
+		{MessageReactionAdd, &discordgo.MessageReactionAdd{
+			MessageReaction: &discordgo.MessageReaction{UserID: "20", MessageID: "100", ChannelID: "1", Emoji: discordgo.Emoji{Name: "🐼"}},
+			Member:          &discordgo.Member{User: &discordgo.User{ID: "20", Username: "bear"}},
+		}},
+	}
+	for _, e := range events {
+		data, err := json.Marshal(e.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Event(nil, &discordgo.Event{Type: e.eventType, RawData: data})
+	}
+
+	recorded, err := Read(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	for _, e := range recorded {
+		types = append(types, e.Type)
+	}
+	if expected := []string{MessageCreate, MessageCreate, MessageReactionAdd}; !reflect.DeepEqual(types, expected) {
+		t.Fatalf("expected recorded %v, actual %v", expected, types)
+	}
+
+	rt := router.New("!")
+	if err := rt.Add("Ping", &router.Command{Name: "ping", Handler: func(ctx *router.Context) error {
+		_, err := ctx.Reply("pong")
+		return err
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	registry := plugin.NewRegistry(rt)
+	if err := registry.Register(echo{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Init(&discordgo.Session{}); err != nil {
+		t.Fatal(err)
+	}
+
+	out := &bytes.Buffer{}
+	if err := Replay(recorded, registry, out); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `> MESSAGE_CREATE #1 @panda: "hello"
+< #1: "hello"
+> MESSAGE_CREATE #1 @bear: "!ping"
+< #1: "pong"
+> MESSAGE_REACTION_ADD #1 @bear: 🐼 on 100
+< #1: reaction 🐼 on 100
+`
+	if diff := Diff(expected, out.String()); len(diff) > 0 {
+		t.Errorf("unexpected replay output:\n%s", strings.Join(diff, "\n"))
+	}
+}
+
+func Test_Diff(t *testing.T) {
+	testCases := []struct {
+		expected string
+		actual   string
+		diff     []string
+	}{
+		{expected: "a\nb\n", actual: "a\nb", diff: nil},
+		{expected: "a\nb\nc", actual: "a\nc", diff: []string{"-b"}},
+		{expected: "a\nc", actual: "a\nb\nc", diff: []string{"+b"}},
+		{expected: "a\nb", actual: "a\nd", diff: []string{"-b", "+d"}},
+	}
+
+	for _, v := range testCases {
+		if diff := Diff(v.expected, v.actual); !reflect.DeepEqual(diff, v.diff) {
+			t.Errorf("%q vs %q: expected %q, actual %q", v.expected, v.actual, v.diff, diff)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package recorder
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/paulvasilenko/discordbot/discordbot/discord"
+	"github.com/paulvasilenko/discordbot/discordbot/discord/discordtest"
+)
+
+// Handler handles decoded gateway event, plugin.Registry is a handler of all plugins
+type Handler interface {
+	Handle(s discord.Session, event interface{})
+}
+
+// HandlerFunc is a function handling gateway event
+type HandlerFunc func(s discord.Session, event interface{})
+
+// Handle calls f(s, event)
+func (f HandlerFunc) Handle(s discord.Session, event interface{}) {
+	f(s, event)
+}
+
+// Replay passes recorded events to handler with fake session one by one.
+// Every event is written to w as line starting with >, followed by bot responses starting with <
+func Replay(events []*Event, h Handler, w io.Writer) error {
+	s := discordtest.NewSession()
+
+	for _, e := range events {
+		event, err := e.Decode()
+		if err != nil {
+			return err
+		}
+		if event == nil {
+			continue
+		}
+
+		if _, err := fmt.Fprintln(w, "> "+remember(s, event)); err != nil {
+			return err
+		}
+
+		h.Handle(s, event)
+
+		for _, line := range responses(s) {
+			if _, err := fmt.Fprintln(w, "< "+line); err != nil {
+				return err
+			}
+		}
+		s.Reset()
+	}
+
+	return nil
+}
+
+// remember adds messages and users of event to session, so handlers can fetch them, and describes event
+func remember(s *discordtest.Session, event interface{}) string {
+	switch e := event.(type) {
+	case *discordgo.MessageCreate:
+		s.AddMessage(e.Message)
+		if e.Author != nil {
+			s.Users[e.Author.ID] = e.Author
+		}
+		return fmt.Sprintf("%s #%s %s: %q", MessageCreate, e.ChannelID, username(e.Author), e.Content)
+	case *discordgo.MessageReactionAdd:
+		if e.Member != nil && e.Member.User != nil {
+			s.Users[e.UserID] = e.Member.User
+		}
+		return fmt.Sprintf("%s #%s %s: %s on %s", MessageReactionAdd, e.ChannelID, username(s.Users[e.UserID]), e.Emoji.Name, e.MessageID)
+	case *discordgo.InteractionCreate:
+		user := e.User
+		if e.Member != nil {
+			user = e.Member.User
+		}
+		name := ""
+		if e.Type == discordgo.InteractionApplicationCommand {
+			name = e.ApplicationCommandData().Name
+		}
+		return fmt.Sprintf("%s #%s %s: %s", InteractionCreate, e.ChannelID, username(user), name)
+	}
+
+	return fmt.Sprintf("%T", event)
+}
+
+// responses describes messages sent and reactions added by bot, final content of edited messages is used
+func responses(s *discordtest.Session) []string {
+	var lines []string
+	for _, m := range s.Sent {
+		line := fmt.Sprintf("#%s: %q", m.ChannelID, m.Content)
+		for _, e := range m.Embeds {
+			line += fmt.Sprintf(" [embed %q]", e.Title)
+		}
+		for _, a := range m.Attachments {
+			line += fmt.Sprintf(" [file %s]", a.Filename)
+		}
+		if m.Flags&discordgo.MessageFlagsEphemeral != 0 {
+			line += " [ephemeral]"
+		}
+		lines = append(lines, line)
+	}
+	for _, r := range s.Reactions {
+		lines = append(lines, fmt.Sprintf("#%s: reaction %s on %s", r.ChannelID, r.EmojiID, r.MessageID))
+	}
+
+	return lines
+}
+
+func username(u *discordgo.User) string {
+	if u == nil {
+		return "@unknown"
+	}
+	return "@" + u.Username
+}
+
+// Diff compares lines of expected and actual replay output, result has
+// removed lines starting with - and added ones starting with +, it's empty if outputs are equal
+func Diff(expected, actual string) []string {
+	a := strings.Split(strings.TrimRight(expected, "\n"), "\n")
+	b := strings.Split(strings.TrimRight(actual, "\n"), "\n")
+
+	// lcs[i][j] is length of longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var diff []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			diff = append(diff, "-"+a[i])
+			i++
+		default:
+			diff = append(diff, "+"+b[j])
+			j++
+		}
+	}
+
+	return diff
+}
@@ -0,0 +1,228 @@
+// Package report recovers panics of handlers and reports them with errors of commands
+// to log and admin channel
+package report
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/patrickmn/go-cache"
+	"github.com/paulvasilenko/discordbot/discordbot/discord"
+	"github.com/paulvasilenko/discordbot/discordbot/ratelimit"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const maxReportLength = 2000
+
+// Reporter logs panics and errors with context of event and posts them to admin channel.
+// Same reports are posted once per period, number of posted reports per period is limited
+type Reporter struct {
+	channelID string
+	limit     int
+	period    time.Duration
+
+	mu      sync.RWMutex
+	session discord.Session
+
+	limiter ratelimit.Store
+	// recent are numbers of suppressed repeats of posted reports by their keys
+	recent *cache.Cache
+}
+
+// NewReporter is a constructor function for reporter, reports are only logged if channel is empty
+func NewReporter(channelID string, limit int, period time.Duration) *Reporter {
+	r := &Reporter{
+		channelID: channelID,
+		limit:     limit,
+		period:    period,
+		limiter:   ratelimit.NewMemoryStore(),
+		recent:    cache.New(period, time.Minute),
+	}
+	r.recent.OnEvicted(r.repeated)
+
+	return r
+}
+
+// SetSession sets session used to post reports to admin channel
+func (r *Reporter) SetSession(s discord.Session) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.session = s
+}
+
+// Recover recovers panic and reports it, it must be called with defer
+func (r *Reporter) Recover(source string, fields log.Fields) {
+	if p := recover(); p != nil {
+		r.Panic(source, p, debug.Stack(), fields)
+	}
+}
+
+// Panic reports recovered panic value with stack
+func (r *Reporter) Panic(source string, p interface{}, stack []byte, fields log.Fields) {
+	log.WithFields(fields).WithField("source", source).Errorf("panic: %v\n%s", p, stack)
+	r.post("Panic", source, fmt.Sprint(p), fields)
+}
+
+// Error reports error
+func (r *Reporter) Error(source string, err error, fields log.Fields) {
+	log.WithFields(fields).WithField("source", source).Errorf("error: %v", err)
+	r.post("Error", source, err.Error(), fields)
+}
+
+// Middleware is router middleware which recovers panics of commands and reports them
+// with errors returned by commands. Panics are returned as errors
+func (r *Reporter) Middleware(next router.HandlerFunc) router.HandlerFunc {
+	return func(ctx *router.Context) (err error) {
+		source := "command " + ctx.Command.Name
+		defer func() {
+			if p := recover(); p != nil {
+				r.Panic(source, p, debug.Stack(), ContextFields(ctx))
+				err = errors.Errorf("panic: %v", p)
+			}
+		}()
+
+		err = next(ctx)
+		if Reportable(err) {
+			r.Error(source, err, ContextFields(ctx))
+		}
+
+		return err
+	}
+}
+
+// Reportable returns false for nil and errors caused by user, e.g. usage errors
+func Reportable(err error) bool {
+	var (
+		usageErr  *router.UsageError
+		deniedErr *router.DeniedError
+	)
+
+	return err != nil &&
+		!errors.As(err, &usageErr) &&
+		!errors.As(err, &deniedErr) &&
+		!errors.Is(err, router.ErrNotAllowed)
+}
+
+// ContextFields returns fields describing command call
+func ContextFields(ctx *router.Context) log.Fields {
+	fields := MessageFields(ctx.Message)
+	if ctx.Interaction != nil {
+		fields["interaction"] = ctx.Interaction.ID
+		delete(fields, "content")
+	}
+
+	return fields
+}
+
+// MessageFields returns fields describing message
+func MessageFields(m *discordgo.Message) log.Fields {
+	fields := log.Fields{
+		"guild":   m.GuildID,
+		"channel": m.ChannelID,
+		"message": m.ID,
+		"content": m.Content,
+	}
+	if m.Author != nil {
+		fields["user"] = m.Author.ID
+	}
+
+	return fields
+}
+
+// ReactionFields returns fields describing reaction
+func ReactionFields(mr *discordgo.MessageReaction) log.Fields {
+	return log.Fields{
+		"guild":   mr.GuildID,
+		"channel": mr.ChannelID,
+		"message": mr.MessageID,
+		"user":    mr.UserID,
+		"emoji":   mr.Emoji.APIName(),
+	}
+}
+
+func (r *Reporter) post(kind, source, reason string, fields log.Fields) {
+	r.mu.RLock()
+	s := r.session
+	r.mu.RUnlock()
+
+	if r.channelID == "" || s == nil {
+		return
+	}
+
+	// Add fails when the same report was posted recently, then its repeat is counted.
+	// Counter is shared by handlers running in parallel
+	key := source + ": " + reason
+	if err := r.recent.Add(key, new(int64), cache.DefaultExpiration); err != nil {
+		if v, ok := r.recent.Get(key); ok {
+			atomic.AddInt64(v.(*int64), 1)
+		}
+		return
+	}
+
+	bucket := ratelimit.Bucket{Key: r.channelID, Limit: r.limit, Period: r.period}
+	if wait, _ := r.limiter.TakeAll([]ratelimit.Bucket{bucket}, time.Now()); wait > 0 {
+		log.Println("report is not posted, limit of reports is exceeded")
+		return
+	}
+
+	message := fmt.Sprintf("**%s** in %s: %s", kind, source, reason)
+	if description := describe(fields); description != "" {
+		message += "\n" + description
+	}
+	r.send(s, message)
+}
+
+// repeated posts number of suppressed repeats of report when it's evicted from recent ones
+func (r *Reporter) repeated(key string, v interface{}) {
+	count := atomic.LoadInt64(v.(*int64))
+	if count == 0 {
+		return
+	}
+
+	r.mu.RLock()
+	s := r.session
+	r.mu.RUnlock()
+
+	r.send(s, fmt.Sprintf("%s was repeated %d more times in %s", key, count, r.period))
+}
+
+func (r *Reporter) send(s discord.Session, message string) {
+	if runes := []rune(message); len(runes) > maxReportLength {
+		message = string(runes[:maxReportLength-1]) + "…"
+	}
+
+	if _, err := s.ChannelMessageSend(r.channelID, message); err != nil {
+		log.Println("failed to post report: ", err)
+	}
+}
+
+func describe(fields log.Fields) string {
+	keys := make([]string, 0, len(fields))
+	for k, v := range fields {
+		if v != "" && k != "content" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s: %v", k, fields[k])
+	}
+
+	description := strings.Join(parts, ", ")
+	if content, ok := fields["content"].(string); ok && content != "" {
+		description += "\n> " + strings.ReplaceAll(content, "\n", "\n> ")
+	}
+
+	return description
+}
@@ -0,0 +1,121 @@
+package report
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/paulvasilenko/discordbot/discordbot/discord/discordtest"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+)
+
+func testContext() *router.Context {
+	return &router.Context{
+		Command: &router.Command{Name: "pts"},
+		Message: &discordgo.Message{
+			ID:        "1",
+			ChannelID: "general",
+			GuildID:   discordtest.GuildID,
+			Author:    &discordgo.User{ID: "10"},
+			Content:   "!pts",
+		},
+	}
+}
+
+func Test_Middleware(t *testing.T) {
+	s := discordtest.NewSession()
+	r := NewReporter("admin", 2, time.Hour)
+	r.SetSession(s)
+
+	// Test cases share reporter, so they depend on previous ones
+	testCases := []struct {
+		name     string
+		handler  router.HandlerFunc
+		err      string
+		expected []string
+	}{
+		{
+			name:     "panic",
+			handler:  func(*router.Context) error { panic("boom") },
+			err:      "panic: boom",
+			expected: []string{"**Panic** in command pts: boom\nchannel: general, guild: guild, message: 1, user: 10\n> !pts"},
+		},
+		{
+			name:    "same panic",
+			handler: func(*router.Context) error { panic("boom") },
+			err:     "panic: boom",
+		},
+		{
+			name:    "usage error",
+			handler: func(*router.Context) error { return &router.UsageError{Reason: "missing argument"} },
+			err:     "missing argument",
+		},
+		{
+			name:     "error",
+			handler:  func(*router.Context) error { return errors.New("db is down") },
+			err:      "db is down",
+			expected: []string{"**Error** in command pts: db is down\nchannel: general, guild: guild, message: 1, user: 10\n> !pts"},
+		},
+		{
+			name:    "limit exceeded",
+			handler: func(*router.Context) error { return errors.New("disk is full") },
+			err:     "disk is full",
+		},
+	}
+
+	for _, tc := range testCases {
+		s.Reset()
+		err := r.Middleware(tc.handler)(testContext())
+		if err == nil || err.Error() != tc.err {
+			t.Errorf("%s: expected error %q, got %v", tc.name, tc.err, err)
+		}
+		if actual := s.Contents(); !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("%s: expected %q, actual %q", tc.name, tc.expected, actual)
+		}
+	}
+}
+
+func Test_Repeated(t *testing.T) {
+	s := discordtest.NewSession()
+	r := NewReporter("admin", 5, 100*time.Millisecond)
+	r.SetSession(s)
+
+	// Handlers report errors in parallel
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.Error("command pts", errors.New("db is down"), nil)
+		}()
+	}
+	wg.Wait()
+	time.Sleep(150 * time.Millisecond)
+	r.recent.DeleteExpired()
+
+	expected := []string{
+		"**Error** in command pts: db is down",
+		"command pts: db is down was repeated 9 more times in 100ms",
+	}
+	if actual := s.Contents(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %q, actual %q", expected, actual)
+	}
+}
+
+func Test_NoChannel(t *testing.T) {
+	s := discordtest.NewSession()
+	r := NewReporter("", 5, time.Hour)
+	r.SetSession(s)
+
+	func() {
+		defer r.Recover("racing message handler", nil)
+		panic("boom")
+	}()
+
+	if actual := s.Contents(); actual != nil {
+		t.Errorf("expected no reports, actual %q", actual)
+	}
+}
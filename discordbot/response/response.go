@@ -0,0 +1,296 @@
+// Package response builds bot messages which fit discord limits: long text is split
+// into several messages at line and code block boundaries, fields are rendered as embeds with pages
+package response
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/paulvasilenko/discordbot/discordbot/discord"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+)
+
+// Limits of discord messages and embeds in characters
+const (
+	MaxMessageLength     = 2000
+	MaxTitleLength       = 256
+	MaxDescriptionLength = 4096
+	MaxFields            = 25
+	MaxFieldNameLength   = 256
+	MaxFieldValueLength  = 1024
+	MaxEmbedLength       = 6000
+
+	DefaultColor = 0x2ecc71
+
+	codeFence = "```"
+	// fenceReserve is room left in every part for closing and reopened code fence with language
+	fenceReserve = 16
+	// footerReserve is room left in embed for pagination footer
+	footerReserve = 128
+)
+
+// Response is a bot message. Response without title and fields is sent as text
+// split into several messages when it's too long, otherwise it's sent as embeds,
+// text is their description and fields are split into pages
+type Response struct {
+	Title string
+	Text  string
+	// Fields are rendered as embed fields, too long names and values are truncated
+	Fields []*discordgo.MessageEmbedField
+	// Embed renders text as embed even without title and fields
+	Embed bool
+	// Color of embeds, DefaultColor is used if it's not set
+	Color int
+	// AllowedMentions of messages, nil allows every mention
+	AllowedMentions *discordgo.MessageAllowedMentions
+
+	// PageSize is number of fields in one embed, MaxFields is used if it's not set
+	PageSize int
+	// Page is 1-based page of fields which is sent alone with pagination footer,
+	// all pages are sent if it's not set
+	Page int
+	// PageUsage is command switching pages shown in footer, e.g. !help <page>
+	PageUsage string
+}
+
+// Text returns response of plain text
+func Text(text string) *Response {
+	return &Response{Text: text}
+}
+
+// Reply sends response to channel where command was called,
+// for application commands first message is interaction response and next ones are followups
+func Reply(ctx *router.Context, r *Response) error {
+	for _, m := range r.Messages() {
+		if _, err := ctx.ReplyComplex(m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Send sends response to channel
+func Send(s discord.Session, channelID string, r *Response) error {
+	for _, m := range r.Messages() {
+		if _, err := s.ChannelMessageSendComplex(channelID, m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Messages renders response as messages fitting discord limits
+func (r *Response) Messages() []*discordgo.MessageSend {
+	var messages []*discordgo.MessageSend
+
+	if r.Title == "" && len(r.Fields) == 0 && !r.Embed {
+		for _, part := range Split(r.Text, MaxMessageLength) {
+			messages = append(messages, &discordgo.MessageSend{Content: part, AllowedMentions: r.AllowedMentions})
+		}
+		return messages
+	}
+
+	for _, embed := range r.Embeds() {
+		messages = append(messages, &discordgo.MessageSend{
+			Embeds:          []*discordgo.MessageEmbed{embed},
+			AllowedMentions: r.AllowedMentions,
+		})
+	}
+
+	return messages
+}
+
+// Embeds renders response as embeds. Text too long for description is continued in next embeds,
+// then fields follow split into pages. When Page is set, only that page is returned with footer
+func (r *Response) Embeds() []*discordgo.MessageEmbed {
+	color := r.Color
+	if color == 0 {
+		color = DefaultColor
+	}
+	title := Truncate(r.Title, MaxTitleLength)
+
+	descriptions := []string{""}
+	if r.Text != "" {
+		descriptions = Split(r.Text, MaxDescriptionLength)
+	}
+	pages := r.pages(title, descriptions)
+
+	if r.Page > 0 {
+		page := r.Page
+		if page > len(pages) {
+			page = len(pages)
+		}
+
+		footer := fmt.Sprintf("Page %d/%d", page, len(pages))
+		if len(pages) > 1 && r.PageUsage != "" {
+			footer += " • " + r.PageUsage
+		}
+
+		return []*discordgo.MessageEmbed{{
+			Title:       title,
+			Description: descriptions[0],
+			Color:       color,
+			Fields:      pages[page-1],
+			Footer:      &discordgo.MessageEmbedFooter{Text: footer},
+		}}
+	}
+
+	var embeds []*discordgo.MessageEmbed
+	for i, description := range descriptions {
+		embed := &discordgo.MessageEmbed{Description: description, Color: color}
+		if i == 0 {
+			embed.Title = title
+		}
+		embeds = append(embeds, embed)
+	}
+	// Fields of first page are put into last embed with description when they fit
+	last := embeds[len(embeds)-1]
+	for i, fields := range pages {
+		if i == 0 && embedLength(last)+fieldsLength(fields) <= MaxEmbedLength-footerReserve {
+			last.Fields = fields
+			continue
+		}
+		if len(fields) > 0 {
+			embeds = append(embeds, &discordgo.MessageEmbed{Color: color, Fields: fields})
+		}
+	}
+
+	return embeds
+}
+
+// pages splits fields into pages by PageSize and total length of embed
+func (r *Response) pages(title string, descriptions []string) [][]*discordgo.MessageEmbedField {
+	size := r.PageSize
+	if size <= 0 || size > MaxFields {
+		size = MaxFields
+	}
+
+	// Every page may be shown with title and first description
+	budget := MaxEmbedLength - footerReserve - utf8.RuneCountInString(title) - utf8.RuneCountInString(descriptions[0])
+
+	pages := [][]*discordgo.MessageEmbedField{nil}
+	length := 0
+	for _, f := range r.Fields {
+		field := &discordgo.MessageEmbedField{
+			Name:   Truncate(f.Name, MaxFieldNameLength),
+			Value:  Truncate(f.Value, MaxFieldValueLength),
+			Inline: f.Inline,
+		}
+		n := fieldsLength([]*discordgo.MessageEmbedField{field})
+
+		current := pages[len(pages)-1]
+		if len(current) > 0 && (len(current) >= size || length+n > budget) {
+			pages = append(pages, nil)
+			length = 0
+		}
+		pages[len(pages)-1] = append(pages[len(pages)-1], field)
+		length += n
+	}
+
+	return pages
+}
+
+// Split splits text into parts not longer than limit. Text is split at line breaks,
+// code block split between parts is closed and reopened with its language,
+// lines longer than limit are split as well. Text which fits is returned as is
+func Split(text string, limit int) []string {
+	if utf8.RuneCountInString(text) <= limit {
+		return []string{text}
+	}
+
+	var (
+		parts  []string
+		part   strings.Builder
+		length int
+		// fence is opening line of code block which is open at the end of part
+		fence string
+	)
+
+	flush := func() {
+		content := strings.TrimRight(part.String(), "\n")
+		if fence != "" {
+			content += "\n" + codeFence
+		}
+		if strings.TrimSpace(content) != "" && content != fence+"\n"+codeFence {
+			parts = append(parts, content)
+		}
+
+		part.Reset()
+		length = 0
+		if fence != "" {
+			part.WriteString(fence + "\n")
+			length = utf8.RuneCountInString(fence) + 1
+		}
+	}
+
+	for _, line := range splitLines(text, limit-fenceReserve) {
+		n := utf8.RuneCountInString(line)
+		if length+n > limit-fenceReserve {
+			flush()
+		}
+		part.WriteString(line)
+		length += n
+
+		if strings.Count(line, codeFence)%2 == 1 {
+			if fence == "" {
+				fence = codeFence
+				if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, codeFence) {
+					fence = trimmed
+				}
+			} else {
+				fence = ""
+			}
+		}
+	}
+	flush()
+
+	return parts
+}
+
+// splitLines splits text after line breaks, lines longer than limit are split into several
+func splitLines(text string, limit int) []string {
+	var lines []string
+	for _, line := range strings.SplitAfter(text, "\n") {
+		for utf8.RuneCountInString(line) > limit {
+			runes := []rune(line)
+			lines = append(lines, string(runes[:limit]))
+			line = string(runes[limit:])
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines
+}
+
+// Truncate cuts text to length runes with ellipsis
+func Truncate(text string, length int) string {
+	if runes := []rune(text); len(runes) > length {
+		return string(runes[:length-1]) + "…"
+	}
+
+	return text
+}
+
+// Fits returns true if text fits into single message
+func Fits(text string) bool {
+	return utf8.RuneCountInString(text) <= MaxMessageLength
+}
+
+func embedLength(e *discordgo.MessageEmbed) int {
+	return utf8.RuneCountInString(e.Title) + utf8.RuneCountInString(e.Description) + fieldsLength(e.Fields)
+}
+
+func fieldsLength(fields []*discordgo.MessageEmbedField) int {
+	n := 0
+	for _, f := range fields {
+		n += utf8.RuneCountInString(f.Name) + utf8.RuneCountInString(f.Value)
+	}
+
+	return n
+}
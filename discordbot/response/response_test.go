@@ -0,0 +1,110 @@
+package response
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func Test_Split(t *testing.T) {
+	testCases := []struct {
+		name     string
+		text     string
+		limit    int
+		expected []string
+	}{
+		{
+			name:     "fits",
+			text:     "Smileys top:\n#1 - kappa\n",
+			limit:    100,
+			expected: []string{"Smileys top:\n#1 - kappa\n"},
+		},
+		{
+			name:     "lines",
+			text:     strings.Repeat("0123456789\n", 5),
+			limit:    40,
+			expected: []string{"0123456789\n0123456789", "0123456789\n0123456789", "0123456789"},
+		},
+		{
+			name:     "code block",
+			text:     "Gift:\n```go\n" + strings.Repeat("0123456789\n", 3) + "```\nEnd",
+			limit:    50,
+			expected: []string{"Gift:\n```go\n0123456789\n0123456789\n```", "```go\n0123456789\n```\nEnd"},
+		},
+		{
+			name:     "long line",
+			text:     strings.Repeat("ы", 50),
+			limit:    36,
+			expected: []string{strings.Repeat("ы", 20), strings.Repeat("ы", 20), strings.Repeat("ы", 10)},
+		},
+	}
+
+	for _, tc := range testCases {
+		if actual := Split(tc.text, tc.limit); !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("%s: expected %q, actual %q", tc.name, tc.expected, actual)
+		}
+	}
+}
+
+func Test_Messages(t *testing.T) {
+	fields := make([]*discordgo.MessageEmbedField, 12)
+	for i := range fields {
+		fields[i] = &discordgo.MessageEmbedField{Name: "!cmd" + strconv.Itoa(i+1), Value: "description"}
+	}
+
+	testCases := []struct {
+		name     string
+		response *Response
+		// expected are contents of text messages or titles, descriptions, number of fields and footers of embeds
+		expected []string
+	}{
+		{
+			name:     "text",
+			response: Text(strings.Repeat("line\n", 500)),
+			expected: []string{strings.TrimSuffix(strings.Repeat("line\n", 396), "\n"), strings.TrimSuffix(strings.Repeat("line\n", 104), "\n")},
+		},
+		{
+			name:     "all pages",
+			response: &Response{Title: "Plugins", Text: "Use !help <plugin>", Fields: fields, PageSize: 5},
+			expected: []string{"Plugins|Use !help <plugin>|5|", "||5|", "||2|"},
+		},
+		{
+			name:     "page",
+			response: &Response{Title: "Plugins", Fields: fields, PageSize: 5, Page: 2, PageUsage: "!help <page>"},
+			expected: []string{"Plugins||5|Page 2/3 • !help <page>"},
+		},
+		{
+			name:     "page out of range",
+			response: &Response{Title: "Racing", Fields: fields[:2], PageSize: 5, Page: 3, PageUsage: "!help racing <page>"},
+			expected: []string{"Racing||2|Page 1/1"},
+		},
+		{
+			name:     "long description",
+			response: &Response{Embed: true, Text: strings.Repeat("line\n", 1000)},
+			expected: []string{"|" + strings.TrimSuffix(strings.Repeat("line\n", 816), "\n") + "|0|", "|" + strings.TrimSuffix(strings.Repeat("line\n", 184), "\n") + "|0|"},
+		},
+	}
+
+	for _, tc := range testCases {
+		var actual []string
+		for _, m := range tc.response.Messages() {
+			if len(m.Embeds) == 0 {
+				actual = append(actual, m.Content)
+				continue
+			}
+			e := m.Embeds[0]
+			footer := ""
+			if e.Footer != nil {
+				footer = e.Footer.Text
+			}
+			actual = append(actual, e.Title+"|"+e.Description+"|"+strconv.Itoa(len(e.Fields))+"|"+footer)
+		}
+
+		if !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("%s: expected %q, actual %q", tc.name, tc.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package router
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/pkg/errors"
+)
+
+// ArgType describes how argument token is parsed
+type ArgType int
+
+const (
+	// ArgString is a single word or quoted string
+	ArgString ArgType = iota
+	// ArgText consumes the rest of the message as is
+	ArgText
+	// ArgNumber is an integer number
+	ArgNumber
+	// ArgUser is a user mention
+	ArgUser
+	// ArgEmoji is a custom or unicode emoji
+	ArgEmoji
+	// ArgChannel is a channel mention
+	ArgChannel
+	// ArgAttachment is a file attached to message, it doesn't consume text
+	ArgAttachment
+	// ArgRole is a role mention
+	ArgRole
+)
+
+var (
+	userMentionRegex    = regexp.MustCompile(`^<@!?(\d+)>$`)
+	channelMentionRegex = regexp.MustCompile(`^<#(\d+)>$`)
+	roleMentionRegex    = regexp.MustCompile(`^<@&(\d+)>$`)
+	customEmojiRegex    = regexp.MustCompile(`^<(a?):([^:>]+):(\d+)>$`)
+)
+
+func (t ArgType) String() string {
+	switch t {
+	case ArgText:
+		return "text"
+	case ArgNumber:
+		return "number"
+	case ArgUser:
+		return "@user"
+	case ArgEmoji:
+		return "emoji"
+	case ArgChannel:
+		return "#channel"
+	case ArgAttachment:
+		return "attachment"
+	case ArgRole:
+		return "@role"
+	default:
+		return "string"
+	}
+}
+
+// Arg describes command argument
+type Arg struct {
+	Name        string
+	Description string
+	Type        ArgType
+	// Optional arguments are skipped when token does not match their type
+	Optional bool
+}
+
+// Emoji is a parsed emoji argument. ID is empty for unicode emojis
+type Emoji struct {
+	ID       string
+	Name     string
+	Animated bool
+}
+
+// String returns emoji in the form it's written in messages
+func (e Emoji) String() string {
+	if e.ID == "" {
+		return e.Name
+	}
+	if e.Animated {
+		return "<a:" + e.Name + ":" + e.ID + ">"
+	}
+	return "<:" + e.Name + ":" + e.ID + ">"
+}
+
+// Value is a parsed argument value
+type Value struct {
+	Raw        string
+	Number     int64
+	User       *discordgo.User
+	Emoji      Emoji
+	ChannelID  string
+	RoleID     string
+	Attachment *discordgo.MessageAttachment
+}
+
+// Args is a map of parsed arguments by their names
+type Args map[string]*Value
+
+// Has returns true if argument was passed
+func (a Args) Has(name string) bool {
+	_, ok := a[name]
+	return ok
+}
+
+// String returns raw value of argument or empty string
+func (a Args) String(name string) string {
+	if v, ok := a[name]; ok {
+		return v.Raw
+	}
+	return ""
+}
+
+// Number returns number argument or zero
+func (a Args) Number(name string) int64 {
+	if v, ok := a[name]; ok {
+		return v.Number
+	}
+	return 0
+}
+
+// User returns mentioned user or nil
+func (a Args) User(name string) *discordgo.User {
+	if v, ok := a[name]; ok {
+		return v.User
+	}
+	return nil
+}
+
+// Channel returns ID of mentioned channel or empty string
+func (a Args) Channel(name string) string {
+	if v, ok := a[name]; ok {
+		return v.ChannelID
+	}
+	return ""
+}
+
+// Role returns ID of mentioned role or empty string
+func (a Args) Role(name string) string {
+	if v, ok := a[name]; ok {
+		return v.RoleID
+	}
+	return ""
+}
+
+// Attachment returns attached file or nil
+func (a Args) Attachment(name string) *discordgo.MessageAttachment {
+	if v, ok := a[name]; ok {
+		return v.Attachment
+	}
+	return nil
+}
+
+// Emoji returns emoji argument and whether it was passed
+func (a Args) Emoji(name string) (Emoji, bool) {
+	if v, ok := a[name]; ok {
+		return v.Emoji, true
+	}
+	return Emoji{}, false
+}
+
+type token struct {
+	value string
+	start int
+}
+
+// tokenize splits text to words, keeping "quoted strings" as single token
+func tokenize(text string) ([]token, error) {
+	var (
+		tokens  []token
+		current strings.Builder
+		start   = -1
+		quoted  bool
+		escaped bool
+	)
+
+	flush := func() {
+		if start >= 0 {
+			tokens = append(tokens, token{value: current.String(), start: start})
+		}
+		current.Reset()
+		start = -1
+	}
+
+	for i, r := range text {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case quoted && r == '\\':
+			escaped = true
+		case r == '"':
+			if start < 0 {
+				start = i
+			}
+			quoted = !quoted
+		case !quoted && unicode.IsSpace(r):
+			flush()
+		default:
+			if start < 0 {
+				start = i
+			}
+			current.WriteRune(r)
+		}
+	}
+
+	if quoted {
+		return nil, errors.New("unterminated quoted string")
+	}
+	flush()
+
+	return tokens, nil
+}
+
+// parseArgs matches tokens of text against command arguments
+func parseArgs(args []Arg, text string, m *discordgo.Message) (Args, error) {
+	tokens, err := tokenize(text)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := Args{}
+	i := 0
+	for _, arg := range args {
+		if arg.Type == ArgAttachment {
+			if len(m.Attachments) > 0 {
+				parsed[arg.Name] = &Value{Raw: m.Attachments[0].URL, Attachment: m.Attachments[0]}
+			} else if !arg.Optional {
+				return nil, errors.Errorf("missing attachment %s", arg.Name)
+			}
+			continue
+		}
+
+		if i >= len(tokens) {
+			if !arg.Optional {
+				return nil, errors.Errorf("missing argument %s", arg.Name)
+			}
+			continue
+		}
+
+		if arg.Type == ArgText {
+			parsed[arg.Name] = &Value{Raw: strings.TrimSpace(text[tokens[i].start:])}
+			i = len(tokens)
+			continue
+		}
+
+		value, err := parseValue(arg.Type, tokens[i].value, m.Mentions)
+		if err != nil {
+			if arg.Optional {
+				continue
+			}
+			return nil, errors.Wrapf(err, "invalid argument %s", arg.Name)
+		}
+
+		parsed[arg.Name] = value
+		i++
+	}
+
+	return parsed, nil
+}
+
+func parseValue(t ArgType, raw string, mentions []*discordgo.User) (*Value, error) {
+	value := &Value{Raw: raw}
+
+	switch t {
+	case ArgNumber:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, errors.Errorf("%s is not a number", raw)
+		}
+		value.Number = n
+	case ArgUser:
+		match := userMentionRegex.FindStringSubmatch(raw)
+		if match == nil {
+			return nil, errors.Errorf("%s is not a user mention", raw)
+		}
+		value.User = &discordgo.User{ID: match[1]}
+		for _, u := range mentions {
+			if u.ID == match[1] {
+				value.User = u
+				break
+			}
+		}
+	case ArgChannel:
+		match := channelMentionRegex.FindStringSubmatch(raw)
+		if match == nil {
+			return nil, errors.Errorf("%s is not a channel mention", raw)
+		}
+		value.ChannelID = match[1]
+	case ArgRole:
+		match := roleMentionRegex.FindStringSubmatch(raw)
+		if match == nil {
+			return nil, errors.Errorf("%s is not a role mention", raw)
+		}
+		value.RoleID = match[1]
+	case ArgEmoji:
+		if match := customEmojiRegex.FindStringSubmatch(raw); match != nil {
+			value.Emoji = Emoji{Animated: match[1] == "a", Name: match[2], ID: match[3]}
+			break
+		}
+		if !isUnicodeEmoji(raw) {
+			return nil, errors.Errorf("%s is not an emoji", raw)
+		}
+		value.Emoji = Emoji{Name: raw}
+	}
+
+	return value, nil
+}
+
+func isUnicodeEmoji(s string) bool {
+	if s == "" {
+		return false
+	}
+	hasSymbol := false
+	for _, r := range s {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), unicode.IsSpace(r):
+			return false
+		case unicode.Is(unicode.So, r), unicode.Is(unicode.Sk, r):
+			hasSymbol = true
+		}
+	}
+	return hasSymbol
+}
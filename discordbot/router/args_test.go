@@ -0,0 +1,127 @@
+package router
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func Test_tokenize(t *testing.T) {
+	type test struct {
+		text   string
+		tokens []string
+		err    bool
+	}
+
+	testCases := []test{
+		{text: "", tokens: nil},
+		{text: "  one   two ", tokens: []string{"one", "two"}},
+		{text: `one "two three" four`, tokens: []string{"one", "two three", "four"}},
+		{text: `"escaped \"quote\""`, tokens: []string{`escaped "quote"`}},
+		{text: `""`, tokens: []string{""}},
+		{text: `"unterminated`, err: true},
+	}
+
+	for _, v := range testCases {
+		tokens, err := tokenize(v.text)
+		if (err != nil) != v.err {
+			t.Errorf("text %q: unexpected error: %v", v.text, err)
+			continue
+		}
+
+		var actual []string
+		for _, token := range tokens {
+			actual = append(actual, token.value)
+		}
+		if !reflect.DeepEqual(actual, v.tokens) {
+			t.Errorf("text %q: expected: %q actual: %q", v.text, v.tokens, actual)
+		}
+	}
+}
+
+func Test_parseArgs(t *testing.T) {
+	ptsArgs := []Arg{
+		{Name: "user", Type: ArgUser, Optional: true},
+		{Name: "emoji", Type: ArgEmoji, Optional: true},
+	}
+	mentions := []*discordgo.User{{ID: "42", Username: "panda"}}
+
+	type test struct {
+		args     []Arg
+		text     string
+		expected map[string]string
+		err      bool
+	}
+
+	testCases := []test{
+		{args: ptsArgs, text: "", expected: map[string]string{}},
+		{args: ptsArgs, text: " <@!42> ", expected: map[string]string{"user": "panda"}},
+		{args: ptsArgs, text: "<:kek:123>", expected: map[string]string{"emoji": "<:kek:123>"}},
+		{args: ptsArgs, text: "😂", expected: map[string]string{"emoji": "😂"}},
+		{args: ptsArgs, text: "<@42> <a:kek:123>", expected: map[string]string{"user": "panda", "emoji": "<a:kek:123>"}},
+		{args: []Arg{{Name: "user", Type: ArgUser}}, text: "nobody", err: true},
+		{args: []Arg{{Name: "user", Type: ArgUser}}, text: "", err: true},
+		{args: []Arg{{Name: "n", Type: ArgNumber}}, text: "12", expected: map[string]string{"n": "12"}},
+		{args: []Arg{{Name: "n", Type: ArgNumber}}, text: "twelve", err: true},
+		{args: []Arg{{Name: "message", Type: ArgText}}, text: ` foo  %"bar" `, expected: map[string]string{"message": `foo  %"bar"`}},
+		{args: []Arg{{Name: "name", Type: ArgString}, {Name: "rest", Type: ArgText, Optional: true}}, text: `"two words"`, expected: map[string]string{"name": "two words"}},
+	}
+
+	for _, v := range testCases {
+		parsed, err := parseArgs(v.args, v.text, &discordgo.Message{Mentions: mentions})
+		if (err != nil) != v.err {
+			t.Errorf("text %q: unexpected error: %v", v.text, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		actual := map[string]string{}
+		for name, value := range parsed {
+			switch {
+			case value.User != nil:
+				actual[name] = value.User.Username
+			case value.Emoji.Name != "":
+				actual[name] = value.Emoji.String()
+			default:
+				actual[name] = value.Raw
+			}
+		}
+		if !reflect.DeepEqual(actual, v.expected) {
+			t.Errorf("text %q: expected: %v actual: %v", v.text, v.expected, actual)
+		}
+	}
+}
+
+func Test_match(t *testing.T) {
+	r := New("?")
+	if err := r.Add("Test", &Command{Name: "pts", Aliases: []string{"printtopsmileys"}}, &Command{Name: "homog2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Add("Test", &Command{Name: "PTS"}); err == nil {
+		t.Error("expected error on duplicate command")
+	}
+
+	testCases := map[string]string{
+		"?pts":                  "pts",
+		"?pts  ":                "pts",
+		"?PrintTopSmileys <@1>": "pts",
+		"?homog2 a%b":           "homog2",
+		"?homog a%b":            "",
+		"!pts":                  "",
+		"pts":                   "",
+	}
+
+	for content, expected := range testCases {
+		c, _, ok := r.match(r.Prefix(""), content)
+		actual := ""
+		if ok {
+			actual = c.Name
+		}
+		if actual != expected {
+			t.Errorf("content %q: expected: %q actual: %q", content, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package router
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/paulvasilenko/discordbot/discordbot/discord"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	maxDescriptionLength = 100
+	maxNameLength        = 32
+)
+
+var slashNameRegex = regexp.MustCompile(`^[-_\p{Ll}\p{N}]{1,32}$`)
+
+// InteractionCreate is a event method for application commands called in discord
+func (r *Router) InteractionCreate(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	r.HandleInteraction(discord.Wrap(s), i.Interaction)
+}
+
+// HandleInteraction calls application command of interaction using given session
+func (r *Router) HandleInteraction(s discord.Session, i *discordgo.Interaction) {
+	if i.Type != discordgo.InteractionApplicationCommand {
+		return
+	}
+
+	data := i.ApplicationCommandData()
+	c, ok := r.applicationCommand(data)
+	if !ok {
+		return
+	}
+
+	user := i.User
+	if i.Member != nil {
+		user = i.Member.User
+	}
+	if user == nil || user.Bot {
+		return
+	}
+
+	message := &discordgo.Message{
+		ChannelID: i.ChannelID,
+		GuildID:   i.GuildID,
+		Author:    user,
+		Member:    i.Member,
+	}
+	if data.Resolved != nil {
+		for _, u := range data.Resolved.Users {
+			message.Mentions = append(message.Mentions, u)
+		}
+		for _, a := range data.Resolved.Attachments {
+			message.Attachments = append(message.Attachments, a)
+		}
+	}
+
+	ctx := &Context{
+		Session:     s,
+		Message:     message,
+		Interaction: i,
+		Command:     c,
+		Prefix:      "/",
+		RawArgs:     optionsString(data.Options),
+	}
+	ctx.parse = func() (Args, error) {
+		return parseOptions(c.Args, data)
+	}
+
+	if c.MessageCommand && data.Resolved != nil {
+		ctx.Target = data.Resolved.Messages[data.TargetID]
+	}
+
+	if c.Deferred {
+		err := s.InteractionRespond(i, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		})
+		if err != nil {
+			log.Println("failed to defer interaction response: ", err)
+			return
+		}
+		ctx.deferred = true
+	}
+
+	r.handleError(ctx, r.handler(c)(ctx))
+}
+
+func (r *Router) applicationCommand(data discordgo.ApplicationCommandInteractionData) (*Command, bool) {
+	c, ok := r.Command(data.Name)
+	if !ok {
+		return nil, false
+	}
+
+	if data.CommandType == discordgo.MessageApplicationCommand {
+		return c, c.MessageCommand
+	}
+	return c, c.Slash
+}
+
+// ApplicationCommands returns definitions of commands marked as slash or message commands
+func (r *Router) ApplicationCommands() []*discordgo.ApplicationCommand {
+	var commands []*discordgo.ApplicationCommand
+
+	for _, c := range r.Commands() {
+		switch {
+		case c.MessageCommand:
+			commands = append(commands, &discordgo.ApplicationCommand{
+				Type: discordgo.MessageApplicationCommand,
+				Name: c.Name,
+			})
+		case c.Slash:
+			description := c.Description
+			if description == "" {
+				description = c.Name
+			}
+			commands = append(commands, &discordgo.ApplicationCommand{
+				Type:        discordgo.ChatApplicationCommand,
+				Name:        strings.ToLower(c.Name),
+				Description: truncate(description, maxDescriptionLength),
+				Options:     commandOptions(c.Args),
+			})
+		}
+	}
+
+	return commands
+}
+
+// ValidateApplicationCommands checks names of slash commands against discord restrictions
+func (r *Router) ValidateApplicationCommands() error {
+	for _, c := range r.ApplicationCommands() {
+		if c.Type == discordgo.ChatApplicationCommand && !slashNameRegex.MatchString(c.Name) {
+			return errors.Errorf("invalid slash command name %s", c.Name)
+		}
+		if len([]rune(c.Name)) > maxNameLength {
+			return errors.Errorf("command name %s is too long", c.Name)
+		}
+	}
+	return nil
+}
+
+// SyncCommands registers application commands in discord. Commands are overwritten only
+// when registered ones differ, so it's safe to call on every start. Empty guildID
+// registers global commands, otherwise commands are registered only in guild which
+// makes them available immediately and is useful for development
+func (r *Router) SyncCommands(s *discordgo.Session, appID, guildID string) error {
+	if err := r.ValidateApplicationCommands(); err != nil {
+		return err
+	}
+
+	desired := r.ApplicationCommands()
+
+	registered, err := s.ApplicationCommands(appID, guildID)
+	if err != nil {
+		return errors.Wrap(err, "failed to fetch registered commands")
+	}
+
+	if sameCommands(desired, registered) {
+		log.Println("Application commands are up to date")
+		return nil
+	}
+
+	if desired == nil {
+		desired = []*discordgo.ApplicationCommand{}
+	}
+	if _, err := s.ApplicationCommandBulkOverwrite(appID, guildID, desired); err != nil {
+		return errors.Wrap(err, "failed to overwrite commands")
+	}
+
+	log.Printf("Registered %d application commands", len(desired))
+
+	return nil
+}
+
+func commandOptions(args []Arg) []*discordgo.ApplicationCommandOption {
+	var required, optional []*discordgo.ApplicationCommandOption
+
+	for _, arg := range args {
+		description := arg.Description
+		if description == "" {
+			description = arg.Name
+		}
+
+		option := &discordgo.ApplicationCommandOption{
+			Type:        optionType(arg.Type),
+			Name:        strings.ToLower(arg.Name),
+			Description: truncate(description, maxDescriptionLength),
+			Required:    !arg.Optional,
+		}
+		// discord requires required options to go first
+		if option.Required {
+			required = append(required, option)
+		} else {
+			optional = append(optional, option)
+		}
+	}
+
+	return append(required, optional...)
+}
+
+func optionType(t ArgType) discordgo.ApplicationCommandOptionType {
+	switch t {
+	case ArgNumber:
+		return discordgo.ApplicationCommandOptionInteger
+	case ArgUser:
+		return discordgo.ApplicationCommandOptionUser
+	case ArgChannel:
+		return discordgo.ApplicationCommandOptionChannel
+	case ArgRole:
+		return discordgo.ApplicationCommandOptionRole
+	case ArgAttachment:
+		return discordgo.ApplicationCommandOptionAttachment
+	default:
+		return discordgo.ApplicationCommandOptionString
+	}
+}
+
+// parseOptions converts interaction options to command arguments
+func parseOptions(args []Arg, data discordgo.ApplicationCommandInteractionData) (Args, error) {
+	options := map[string]*discordgo.ApplicationCommandInteractionDataOption{}
+	for _, o := range data.Options {
+		options[o.Name] = o
+	}
+
+	resolved := data.Resolved
+	if resolved == nil {
+		resolved = &discordgo.ApplicationCommandInteractionDataResolved{}
+	}
+
+	parsed := Args{}
+	for _, arg := range args {
+		o, ok := options[strings.ToLower(arg.Name)]
+		if !ok {
+			if !arg.Optional {
+				return nil, errors.Errorf("missing argument %s", arg.Name)
+			}
+			continue
+		}
+
+		// ids of users, channels and attachments come as strings
+		id := fmt.Sprint(o.Value)
+
+		value := &Value{}
+		switch arg.Type {
+		case ArgNumber:
+			value.Number = o.IntValue()
+			value.Raw = strconv.FormatInt(value.Number, 10)
+		case ArgUser:
+			value.Raw = id
+			value.User = resolved.Users[id]
+			if value.User == nil {
+				value.User = &discordgo.User{ID: value.Raw}
+			}
+		case ArgChannel:
+			value.Raw = id
+			value.ChannelID = id
+		case ArgRole:
+			value.Raw = id
+			value.RoleID = id
+		case ArgAttachment:
+			value.Attachment = resolved.Attachments[id]
+			if value.Attachment == nil {
+				return nil, errors.Errorf("attachment %s not found", arg.Name)
+			}
+			value.Raw = value.Attachment.URL
+		case ArgEmoji:
+			v, err := parseValue(ArgEmoji, strings.TrimSpace(o.StringValue()), nil)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid argument %s", arg.Name)
+			}
+			value = v
+		default:
+			value.Raw = o.StringValue()
+		}
+
+		parsed[arg.Name] = value
+	}
+
+	return parsed, nil
+}
+
+func optionsString(options []*discordgo.ApplicationCommandInteractionDataOption) string {
+	parts := make([]string, 0, len(options))
+	for _, o := range options {
+		parts = append(parts, fmt.Sprintf("%s:%v", o.Name, o.Value))
+	}
+	return strings.Join(parts, " ")
+}
+
+// commandDefinition is a part of application command compared during sync
+type commandDefinition struct {
+	Type        discordgo.ApplicationCommandType
+	Name        string
+	Description string
+	Options     []optionDefinition
+}
+
+type optionDefinition struct {
+	Type        discordgo.ApplicationCommandOptionType
+	Name        string
+	Description string
+	Required    bool
+}
+
+func sameCommands(a, b []*discordgo.ApplicationCommand) bool {
+	return reflect.DeepEqual(definitions(a), definitions(b))
+}
+
+func definitions(commands []*discordgo.ApplicationCommand) []commandDefinition {
+	defs := make([]commandDefinition, 0, len(commands))
+	for _, c := range commands {
+		t := c.Type
+		if t == 0 {
+			t = discordgo.ChatApplicationCommand
+		}
+
+		def := commandDefinition{Type: t, Name: c.Name, Description: c.Description}
+		for _, o := range c.Options {
+			def.Options = append(def.Options, optionDefinition{
+				Type:        o.Type,
+				Name:        o.Name,
+				Description: o.Description,
+				Required:    o.Required,
+			})
+		}
+		defs = append(defs, def)
+	}
+
+	sort.Slice(defs, func(i, j int) bool {
+		if defs[i].Type != defs[j].Type {
+			return defs[i].Type < defs[j].Type
+		}
+		return defs[i].Name < defs[j].Name
+	})
+
+	return defs
+}
+
+func truncate(s string, length int) string {
+	runes := []rune(s)
+	if len(runes) <= length {
+		return s
+	}
+	return string(runes[:length-1]) + "…"
+}
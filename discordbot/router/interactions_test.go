@@ -0,0 +1,102 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func Test_ApplicationCommands(t *testing.T) {
+	r := New("!")
+	err := r.Add("Test",
+		&Command{Name: "text"},
+		&Command{
+			Name:  "sdr",
+			Slash: true,
+			Args: []Arg{
+				{Name: "note", Type: ArgString, Optional: true},
+				{Name: "user", Type: ArgUser},
+			},
+		},
+		&Command{Name: "Speak", MessageCommand: true},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commands := r.ApplicationCommands()
+	if len(commands) != 2 {
+		t.Fatal("expected 2 commands, actual:", len(commands))
+	}
+
+	sdr := commands[0]
+	if sdr.Description != "sdr" {
+		t.Error("expected name as default description, actual:", sdr.Description)
+	}
+	if sdr.Options[0].Name != "user" || !sdr.Options[0].Required || sdr.Options[0].Type != discordgo.ApplicationCommandOptionUser {
+		t.Error("required user option must go first")
+	}
+	if commands[1].Type != discordgo.MessageApplicationCommand {
+		t.Error("expected message command")
+	}
+
+	if r.IsCommand("", "!Speak") {
+		t.Error("message commands must not be called with text")
+	}
+
+	registered := []*discordgo.ApplicationCommand{
+		{ID: "2", Type: discordgo.MessageApplicationCommand, Name: "Speak", Version: "1"},
+		{ID: "1", Name: "sdr", Description: "sdr", Options: sdr.Options},
+	}
+	if !sameCommands(commands, registered) {
+		t.Error("expected commands to be the same")
+	}
+
+	registered[1].Description = "changed"
+	if sameCommands(commands, registered) {
+		t.Error("expected commands to differ")
+	}
+}
+
+func Test_parseOptions(t *testing.T) {
+	args := []Arg{
+		{Name: "user", Type: ArgUser},
+		{Name: "emoji", Type: ArgEmoji, Optional: true},
+		{Name: "image", Type: ArgAttachment, Optional: true},
+	}
+
+	data := discordgo.ApplicationCommandInteractionData{
+		Options: []*discordgo.ApplicationCommandInteractionDataOption{
+			{Name: "user", Type: discordgo.ApplicationCommandOptionUser, Value: "42"},
+			{Name: "emoji", Type: discordgo.ApplicationCommandOptionString, Value: " <:kek:123> "},
+			{Name: "image", Type: discordgo.ApplicationCommandOptionAttachment, Value: "7"},
+		},
+		Resolved: &discordgo.ApplicationCommandInteractionDataResolved{
+			Users:       map[string]*discordgo.User{"42": {ID: "42", Username: "panda"}},
+			Attachments: map[string]*discordgo.MessageAttachment{"7": {ID: "7", URL: "https://cdn/a.png?ex=1"}},
+		},
+	}
+
+	parsed, err := parseOptions(args, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := parsed.User("user"); u == nil || u.Username != "panda" {
+		t.Error("expected resolved user, actual:", u)
+	}
+	if e, ok := parsed.Emoji("emoji"); !ok || e.ID != "123" {
+		t.Error("expected parsed emoji, actual:", e)
+	}
+	if a := parsed.Attachment("image"); a == nil || a.ID != "7" {
+		t.Error("expected resolved attachment, actual:", a)
+	}
+
+	data.Options[1].Value = "not an emoji"
+	if _, err := parseOptions(args, data); err == nil {
+		t.Error("expected error on invalid emoji")
+	}
+
+	if _, err := parseOptions(args, discordgo.ApplicationCommandInteractionData{}); err == nil {
+		t.Error("expected error on missing user")
+	}
+}
@@ -0,0 +1,414 @@
+// Package router provides command routing for text messages: prefix handling,
+// aliases and typed argument parsing
+package router
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/paulvasilenko/discordbot/discordbot/discord"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultPrefix is used when router is created with empty prefix
+const DefaultPrefix = "!"
+
+// HandlerFunc handles routed command
+type HandlerFunc func(ctx *Context) error
+
+// Middleware wraps command handler, e.g. to check whether command is allowed
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Command describes bot command
+type Command struct {
+	// Name of command without prefix
+	Name        string
+	Aliases     []string
+	Description string
+	Args        []Arg
+	Handler     HandlerFunc
+
+	// Permission is a discord permission required to call command by default
+	Permission int64
+	// Unlimited returns true for calls which are not counted by rate limits, e.g. cancellation of previous call
+	Unlimited func(ctx *Context) bool
+
+	// Slash makes command available as application (slash) command as well
+	Slash bool
+	// MessageCommand makes command available only from message context menu,
+	// message is passed to handler in Context.Target
+	MessageCommand bool
+	// Deferred commands acknowledge interaction before handler is called, used for slow work
+	Deferred bool
+
+	// Plugin is a name of plugin command belongs to, set by router
+	Plugin string
+}
+
+// Usage returns usage string of command, e.g. !pts [@user] [emoji]
+func (c *Command) Usage(prefix string) string {
+	parts := []string{prefix + c.Name}
+	for _, arg := range c.Args {
+		name := arg.Name
+		if arg.Type == ArgText {
+			name += "..."
+		}
+		if arg.Optional {
+			parts = append(parts, "["+name+"]")
+		} else {
+			parts = append(parts, "<"+name+">")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// ErrNotAllowed is returned by middlewares when command can't be called in this context
+var ErrNotAllowed = errors.New("command is not available here")
+
+// DeniedError is returned when user is not allowed to call command, reason is sent to user
+type DeniedError struct {
+	Reason string
+}
+
+func (e *DeniedError) Error() string {
+	return e.Reason
+}
+
+// UsageError is returned when command arguments are invalid
+type UsageError struct {
+	Command *Command
+	Reason  string
+}
+
+func (e *UsageError) Error() string {
+	return e.Reason
+}
+
+// Context is passed to command handler
+type Context struct {
+	Session discord.Session
+	// Message which called command. For application commands it's built from
+	// interaction and has only channel, guild, author, mentions and attachments
+	Message *discordgo.Message
+	// Interaction is set when command is called as application command
+	Interaction *discordgo.Interaction
+	// Target is a message on which message command is called
+	Target  *discordgo.Message
+	Command *Command
+	Prefix  string
+	// RawArgs is a part of message after command name
+	RawArgs string
+	// Args are parsed arguments, available after middlewares are passed
+	Args Args
+
+	parse      func() (Args, error)
+	deferred   bool
+	responded  bool
+	responseID string
+}
+
+// Reply sends message to channel where command was called
+func (c *Context) Reply(content string) (*discordgo.Message, error) {
+	return c.ReplyComplex(&discordgo.MessageSend{Content: content})
+}
+
+// ReplyComplex sends message with embeds and files to channel where command was called.
+// For application commands first reply is sent as interaction response, next ones as followups
+func (c *Context) ReplyComplex(data *discordgo.MessageSend) (*discordgo.Message, error) {
+	if c.Interaction == nil {
+		return c.Session.ChannelMessageSendComplex(c.Message.ChannelID, data)
+	}
+
+	if c.responded {
+		return c.Session.FollowupMessageCreate(c.Interaction, true, &discordgo.WebhookParams{
+			Content: data.Content,
+			Embeds:  data.Embeds,
+			Files:   data.Files,
+		})
+	}
+
+	var (
+		message *discordgo.Message
+		err     error
+	)
+	if c.deferred {
+		message, err = c.Session.InteractionResponseEdit(c.Interaction, &discordgo.WebhookEdit{
+			Content: &data.Content,
+			Embeds:  &data.Embeds,
+			Files:   data.Files,
+		})
+	} else {
+		err = c.Session.InteractionRespond(c.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: data.Content,
+				Embeds:  data.Embeds,
+				Files:   data.Files,
+			},
+		})
+		if err == nil {
+			message, err = c.Session.InteractionResponse(c.Interaction)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.responded = true
+	c.responseID = message.ID
+
+	return message, nil
+}
+
+// Edit changes content of message previously sent by Reply
+func (c *Context) Edit(message *discordgo.Message, content string) (*discordgo.Message, error) {
+	if c.Interaction == nil {
+		return c.Session.ChannelMessageEdit(message.ChannelID, message.ID, content)
+	}
+
+	edit := &discordgo.WebhookEdit{Content: &content}
+	if message.ID == c.responseID {
+		return c.Session.InteractionResponseEdit(c.Interaction, edit)
+	}
+	return c.Session.FollowupMessageEdit(c.Interaction, message.ID, edit)
+}
+
+// replyEphemeral answers interaction with message visible only to caller
+func (c *Context) replyEphemeral(content string) error {
+	if c.responded {
+		_, err := c.Session.FollowupMessageCreate(c.Interaction, true, &discordgo.WebhookParams{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		})
+		return err
+	}
+
+	if c.deferred {
+		_, err := c.Session.InteractionResponseEdit(c.Interaction, &discordgo.WebhookEdit{Content: &content})
+		return err
+	}
+
+	return c.Session.InteractionRespond(c.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+// UsageError returns error which makes router reply with command usage
+func (c *Context) UsageError(reason string) error {
+	return &UsageError{Command: c.Command, Reason: reason}
+}
+
+// Router dispatches messages to registered commands
+type Router struct {
+	prefix     string
+	prefixFunc func(guildID string) string
+
+	mu          sync.RWMutex
+	commands    []*Command
+	byName      map[string]*Command
+	middlewares []Middleware
+}
+
+// New returns router which uses given prefix for commands
+func New(prefix string) *Router {
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+
+	return &Router{
+		prefix: prefix,
+		byName: map[string]*Command{},
+	}
+}
+
+// SetPrefixFunc sets function which returns prefix of guild, e.g. from guild settings
+func (r *Router) SetPrefixFunc(fn func(guildID string) string) {
+	r.prefixFunc = fn
+}
+
+// Prefix returns command prefix of guild
+func (r *Router) Prefix(guildID string) string {
+	if r.prefixFunc != nil {
+		if prefix := r.prefixFunc(guildID); prefix != "" {
+			return prefix
+		}
+	}
+	return r.prefix
+}
+
+// Use adds middlewares which wrap every command handler. First added middleware is called first
+func (r *Router) Use(middlewares ...Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.middlewares = append(r.middlewares, middlewares...)
+}
+
+func (r *Router) handler(c *Command) HandlerFunc {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	h := func(ctx *Context) error {
+		args, err := ctx.parse()
+		if err != nil {
+			return ctx.UsageError(err.Error())
+		}
+
+		ctx.Args = args
+		return c.Handler(ctx)
+	}
+	for i := len(r.middlewares) - 1; i >= 0; i-- {
+		h = r.middlewares[i](h)
+	}
+	return h
+}
+
+// Add registers commands of plugin. Names and aliases are case insensitive and must be unique
+func (r *Router) Add(plugin string, commands ...*Command) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, c := range commands {
+		names := append([]string{c.Name}, c.Aliases...)
+		for _, name := range names {
+			if _, ok := r.byName[strings.ToLower(name)]; ok {
+				return errors.Errorf("command %s is already registered", name)
+			}
+		}
+
+		c.Plugin = plugin
+		r.commands = append(r.commands, c)
+		for _, name := range names {
+			r.byName[strings.ToLower(name)] = c
+		}
+	}
+
+	return nil
+}
+
+// Commands returns all registered commands
+func (r *Router) Commands() []*Command {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]*Command(nil), r.commands...)
+}
+
+// Command returns command by its name or alias
+func (r *Router) Command(name string) (*Command, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	c, ok := r.byName[strings.ToLower(name)]
+	return c, ok
+}
+
+// IsCommand checks whether message content calls one of registered commands in guild
+func (r *Router) IsCommand(guildID, content string) bool {
+	_, _, ok := r.match(r.Prefix(guildID), content)
+	return ok
+}
+
+// match finds command called by content and returns rest of content as arguments
+func (r *Router) match(prefix, content string) (*Command, string, bool) {
+	if !strings.HasPrefix(content, prefix) {
+		return nil, "", false
+	}
+
+	content = strings.TrimPrefix(content, prefix)
+	name := content
+	rest := ""
+	if i := strings.IndexFunc(content, isSpace); i >= 0 {
+		name, rest = content[:i], content[i:]
+	}
+
+	c, ok := r.Command(name)
+	if !ok || c.MessageCommand {
+		return nil, "", false
+	}
+	return c, rest, true
+}
+
+// MessageCreate is a event method for message sent to discord
+func (r *Router) MessageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
+	r.HandleMessage(discord.Wrap(s), m.Message)
+}
+
+// HandleMessage calls command of message using given session
+func (r *Router) HandleMessage(s discord.Session, m *discordgo.Message) {
+	if m.Author == nil || m.Author.Bot {
+		return
+	}
+
+	prefix := r.Prefix(m.GuildID)
+	c, rest, ok := r.match(prefix, m.Content)
+	if !ok {
+		return
+	}
+
+	ctx := &Context{
+		Session: s,
+		Message: m,
+		Command: c,
+		Prefix:  prefix,
+		RawArgs: strings.TrimSpace(rest),
+	}
+	ctx.parse = func() (Args, error) {
+		return parseArgs(c.Args, ctx.RawArgs, ctx.Message)
+	}
+
+	r.handleError(ctx, r.handler(c)(ctx))
+}
+
+func (r *Router) handleError(ctx *Context, err error) {
+	if err == nil {
+		if ctx.Interaction != nil && !ctx.responded {
+			err = ErrNotAllowed
+		} else {
+			return
+		}
+	}
+
+	var (
+		usageErr  *UsageError
+		deniedErr *DeniedError
+		reply     string
+	)
+	switch {
+	case errors.As(err, &deniedErr):
+		reply = deniedErr.Reason
+	case errors.As(err, &usageErr):
+		reply = fmt.Sprintf("%s\nUsage: `%s`", usageErr.Reason, usageErr.Command.Usage(ctx.Prefix))
+	case errors.Is(err, ErrNotAllowed):
+		if ctx.Interaction == nil {
+			return
+		}
+		reply = "This command is not available here"
+	default:
+		log.Printf("command %s%s failed: %v", ctx.Prefix, ctx.Command.Name, err)
+		if ctx.Interaction == nil {
+			return
+		}
+		reply = "Command failed, please, try again later"
+	}
+
+	if ctx.Interaction != nil {
+		err = ctx.replyEphemeral(reply)
+	} else {
+		_, err = ctx.Reply(reply)
+	}
+	if err != nil {
+		log.Println("failed to reply: ", err)
+	}
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\n' || r == '\t'
+}
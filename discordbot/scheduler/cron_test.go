@@ -0,0 +1,61 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_ParseSchedule(t *testing.T) {
+	testCases := []struct {
+		expr string
+		err  string
+	}{
+		{expr: "0 12 * * 1"},
+		{expr: "*/15 9-18 1,15 * 1-5"},
+		{expr: "@weekly"},
+		{expr: "0 12 * *", err: `expected 5 fields in schedule "0 12 * *", got 4`},
+		{expr: "60 * * * *", err: `minute "60" is out of range 0-59`},
+		{expr: "0 * * 13 *", err: `month "13" is out of range 1-12`},
+		{expr: "*/0 * * * *", err: `invalid step in minute "*/0"`},
+		{expr: "a * * * *", err: `invalid minute "a"`},
+	}
+
+	for _, tc := range testCases {
+		_, err := ParseSchedule(tc.expr)
+		if (err == nil && tc.err != "") || (err != nil && err.Error() != tc.err) {
+			t.Errorf("%s: expected error %q, got %v", tc.expr, tc.err, err)
+		}
+	}
+}
+
+func Test_Next(t *testing.T) {
+	// Sunday
+	now := time.Date(2026, 10, 18, 12, 30, 20, 0, time.UTC)
+
+	testCases := []struct {
+		expr     string
+		expected time.Time
+	}{
+		{expr: "* * * * *", expected: time.Date(2026, 10, 18, 12, 31, 0, 0, time.UTC)},
+		{expr: "0 12 * * 1", expected: time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)},
+		{expr: "45 12 * * *", expected: time.Date(2026, 10, 18, 12, 45, 0, 0, time.UTC)},
+		{expr: "*/20 * * * *", expected: time.Date(2026, 10, 18, 12, 40, 0, 0, time.UTC)},
+		{expr: "0 0 1 * *", expected: time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{expr: "0 9 * * 7", expected: time.Date(2026, 10, 25, 9, 0, 0, 0, time.UTC)},
+		// Day of month or day of week
+		{expr: "0 9 20 * 1", expected: time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)},
+		{expr: "@yearly", expected: time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{expr: "0 0 29 2 *", expected: time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{expr: "0 0 30 2 *"},
+	}
+
+	for _, tc := range testCases {
+		s, err := ParseSchedule(tc.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if next := s.Next(now); !next.Equal(tc.expected) {
+			t.Errorf("%s: expected %v, actual %v", tc.expr, tc.expected, next)
+		}
+	}
+}
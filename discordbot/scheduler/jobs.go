@@ -0,0 +1,131 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/paulvasilenko/discordbot/discordbot/response"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+	"github.com/pkg/errors"
+)
+
+const (
+	CommandJobs = "jobs"
+
+	nextRunLayout = "2006-01-02 15:04 MST"
+)
+
+// Jobs is a plugin which provides admin commands to list and cancel scheduled jobs of guild
+type Jobs struct {
+	scheduler *Scheduler
+}
+
+// NewJobs is a constructor function for jobs plugin
+func NewJobs(s *Scheduler) *Jobs {
+	return &Jobs{scheduler: s}
+}
+
+func (j *Jobs) Name() string {
+	return "Jobs"
+}
+
+// GetInfo returns map of info message
+func (j *Jobs) GetInfo() map[string]string {
+	return map[string]string{
+		"!jobs":             "Lists scheduled jobs of this server",
+		"!jobs cancel [id]": "Cancels scheduled job",
+	}
+}
+
+func (j *Jobs) Handlers() []interface{} {
+	return nil
+}
+
+func (j *Jobs) Init(s *discordgo.Session) error {
+	return nil
+}
+
+func (j *Jobs) Close() error {
+	return nil
+}
+
+// Commands returns commands of plugin handled by router
+func (j *Jobs) Commands() []*router.Command {
+	return []*router.Command{
+		{
+			Name:        CommandJobs,
+			Description: "Lists or cancels scheduled jobs of this server",
+			Args: []router.Arg{
+				{Name: "action", Type: router.ArgString, Optional: true},
+				{Name: "id", Type: router.ArgNumber, Optional: true},
+			},
+			Handler:    j.jobs,
+			Permission: discordgo.PermissionManageServer,
+		},
+	}
+}
+
+func (j *Jobs) jobs(ctx *router.Context) error {
+	m := ctx.Message
+	if m.GuildID == "" {
+		_, err := ctx.Reply("Jobs are available only on servers")
+		return err
+	}
+
+	switch action := strings.ToLower(ctx.Args.String("action")); action {
+	case "", "list":
+		jobs, err := j.scheduler.Jobs(m.GuildID)
+		if err != nil {
+			return err
+		}
+
+		return response.Reply(ctx, response.Text(list(jobs)))
+	case "cancel":
+		if !ctx.Args.Has("id") {
+			return ctx.UsageError("Job id is required")
+		}
+		id := ctx.Args.Number("id")
+
+		job, err := j.scheduler.Get(id)
+		if errors.Is(err, ErrNotFound) || (err == nil && job.GuildID != m.GuildID) {
+			return ctx.UsageError(fmt.Sprintf("Job #%d is not found", id))
+		}
+		if err != nil {
+			return err
+		}
+		if err := j.scheduler.Cancel(id); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+
+		_, err = ctx.Reply(fmt.Sprintf("Job #%d is cancelled", id))
+		return err
+	default:
+		return ctx.UsageError(fmt.Sprintf("Unknown action %s", action))
+	}
+}
+
+func list(jobs []*Job) string {
+	if len(jobs) == 0 {
+		return "There are no scheduled jobs"
+	}
+
+	lines := []string{"Scheduled jobs:"}
+	for _, job := range jobs {
+		description := job.Description
+		if description == "" {
+			description = job.Handler
+		}
+
+		line := fmt.Sprintf("#%d %s", job.ID, description)
+		if job.Recurring() {
+			line += fmt.Sprintf(", `%s`", job.Schedule)
+		}
+		line += ", next run " + job.NextRun.In(time.Local).Format(nextRunLayout)
+
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,252 @@
+// Package scheduler runs recurring and one-off jobs of plugins. Jobs are kept in database,
+// so they survive restarts, and runs missed while bot was stopped are made on start
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/paulvasilenko/discordbot/discordbot/discord"
+	"github.com/paulvasilenko/discordbot/discordbot/lifecycle"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultInterval is how often due jobs are checked, cron schedules have minute precision
+const DefaultInterval = 15 * time.Second
+
+// Job is a scheduled call of handler registered by plugin
+type Job struct {
+	ID int64
+	// Handler is name of handler registered with Scheduler.Handle, e.g. smileystats.weekly
+	Handler     string
+	Description string
+	GuildID     string
+	ChannelID   string
+	CreatedBy   string
+	// Schedule is cron expression of recurring job, one-off jobs have empty schedule and run once at NextRun
+	Schedule string
+	// Payload is arbitrary data of handler, e.g. json
+	Payload string
+	NextRun time.Time
+}
+
+// Recurring returns true if job runs by schedule
+func (j *Job) Recurring() bool {
+	return j.Schedule != ""
+}
+
+// HandlerFunc runs job, session is the one scheduler is started with
+type HandlerFunc func(s discord.Session, job *Job) error
+
+// Scheduler checks due jobs and runs their handlers as lifecycle tasks
+type Scheduler struct {
+	store     Store
+	lifecycle *lifecycle.Manager
+	interval  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	handlers map[string]HandlerFunc
+	running  map[int64]bool
+	session  discord.Session
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+// NewScheduler is a constructor function for scheduler
+func NewScheduler(store Store, lc *lifecycle.Manager) *Scheduler {
+	return &Scheduler{
+		store:     store,
+		lifecycle: lc,
+		interval:  DefaultInterval,
+		now:       time.Now,
+		handlers:  map[string]HandlerFunc{},
+		running:   map[int64]bool{},
+	}
+}
+
+// Handle registers handler of jobs, plugins register them in Init
+func (s *Scheduler) Handle(name string, fn HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.handlers[name] = fn
+}
+
+// Schedule validates and saves job. Next run of recurring job is calculated by its schedule,
+// one-off job must have NextRun set
+func (s *Scheduler) Schedule(job *Job) error {
+	s.mu.Lock()
+	_, ok := s.handlers[job.Handler]
+	s.mu.Unlock()
+	if !ok {
+		return errors.Errorf("unknown job handler %s", job.Handler)
+	}
+
+	if job.Recurring() {
+		next, err := nextRun(job.Schedule, s.now())
+		if err != nil {
+			return err
+		}
+		job.NextRun = next
+	} else if job.NextRun.IsZero() {
+		return errors.New("time of one-off job is required")
+	}
+
+	return s.store.Add(job)
+}
+
+// Cancel deletes job, ErrNotFound is returned if job doesn't exist
+func (s *Scheduler) Cancel(id int64) error {
+	return s.store.Delete(id)
+}
+
+// Get returns job by id
+func (s *Scheduler) Get(id int64) (*Job, error) {
+	return s.store.Get(id)
+}
+
+// Jobs returns jobs of guild ordered by next run, jobs of bot have empty guild
+func (s *Scheduler) Jobs(guildID string) ([]*Job, error) {
+	return s.store.List(guildID)
+}
+
+// Start runs jobs missed while bot was stopped and starts checking due jobs,
+// all handlers must be registered before it
+func (s *Scheduler) Start(session discord.Session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stop != nil {
+		return
+	}
+	s.session = session
+	s.stop = make(chan struct{})
+	s.stopped = make(chan struct{})
+
+	go s.loop(s.stop, s.stopped)
+}
+
+// Stop stops checking due jobs, running jobs are awaited by lifecycle
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	stop, stopped := s.stop, s.stopped
+	s.stop = nil
+	s.mu.Unlock()
+
+	if stop == nil {
+		return nil
+	}
+	close(stop)
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Scheduler) loop(stop, stopped chan struct{}) {
+	defer close(stopped)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.runDue()
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runDue starts handlers of due jobs. Next run is saved before job is started,
+// so job isn't repeated if bot stops while it's running
+func (s *Scheduler) runDue() {
+	now := s.now()
+	jobs, err := s.store.Due(now)
+	if err != nil {
+		log.Println("failed to get due jobs: ", err)
+		return
+	}
+
+	for _, job := range jobs {
+		s.mu.Lock()
+		h, ok := s.handlers[job.Handler]
+		running, session := s.running[job.ID], s.session
+		s.mu.Unlock()
+		if running {
+			continue
+		}
+
+		if now.Sub(job.NextRun) > time.Minute {
+			log.Printf("Job %d %s missed run at %s, running it now", job.ID, job.Handler, job.NextRun.Format(time.RFC3339))
+		}
+
+		if err := s.advance(job, now); err != nil {
+			log.Printf("failed to reschedule job %d: %v", job.ID, err)
+			continue
+		}
+		if !ok {
+			log.Printf("Job %d is skipped, handler %s is not registered", job.ID, job.Handler)
+			continue
+		}
+
+		s.setRunning(job.ID, true)
+		job := job
+		if !s.lifecycle.Go(func() {
+			defer s.setRunning(job.ID, false)
+			if err := h(session, job); err != nil {
+				log.Printf("job %d %s failed: %v", job.ID, job.Handler, err)
+			}
+		}) {
+			s.setRunning(job.ID, false)
+			return
+		}
+	}
+}
+
+// advance sets next run of recurring job and deletes one-off job
+func (s *Scheduler) advance(job *Job, now time.Time) error {
+	if !job.Recurring() {
+		return s.store.Delete(job.ID)
+	}
+
+	next, err := nextRun(job.Schedule, now)
+	if err != nil {
+		return err
+	}
+
+	return s.store.SetNextRun(job.ID, next)
+}
+
+func (s *Scheduler) setRunning(id int64, running bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if running {
+		s.running[id] = true
+	} else {
+		delete(s.running, id)
+	}
+}
+
+func nextRun(schedule string, now time.Time) (time.Time, error) {
+	sch, err := ParseSchedule(schedule)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	next := sch.Next(now)
+	if next.IsZero() {
+		return time.Time{}, errors.Errorf("schedule %q never runs", schedule)
+	}
+
+	return next, nil
+}
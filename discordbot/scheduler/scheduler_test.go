@@ -0,0 +1,150 @@
+package scheduler
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/paulvasilenko/discordbot/discordbot/discord"
+	"github.com/paulvasilenko/discordbot/discordbot/discord/discordtest"
+	"github.com/paulvasilenko/discordbot/discordbot/lifecycle"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+	"github.com/paulvasilenko/discordbot/discordbot/storage"
+	"github.com/paulvasilenko/discordbot/migrations"
+)
+
+func newSQLiteStore(t *testing.T) Store {
+	db, err := storage.Open(storage.SQLite, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	runner, err := migrations.NewRunner(db.DB, db.Driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runner.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	return NewSQLStore(db)
+}
+
+func Test_runDue(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 30, 0, 0, time.UTC)
+	lc := lifecycle.NewManager()
+	sch := NewScheduler(newSQLiteStore(t), lc)
+	sch.now = func() time.Time { return now }
+
+	s := discordtest.NewSession()
+	sch.session = s
+	sch.Handle("echo", func(s discord.Session, job *Job) error {
+		_, err := s.ChannelMessageSend(job.ChannelID, job.Payload)
+		return err
+	})
+
+	jobs := []*Job{
+		{Handler: "echo", GuildID: "guild", ChannelID: "general", Schedule: "0 12 * * 1", Payload: "weekly"},
+		{Handler: "echo", GuildID: "guild", ChannelID: "general", NextRun: now.Add(time.Hour), Payload: "later"},
+		{Handler: "echo", GuildID: "guild", ChannelID: "general", NextRun: now.Add(-time.Hour), Payload: "missed"},
+	}
+	for _, job := range jobs {
+		if err := sch.Schedule(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sch.Schedule(&Job{Handler: "unknown", Schedule: "@daily"}); err == nil || err.Error() != "unknown job handler unknown" {
+		t.Errorf("expected unknown handler error, got %v", err)
+	}
+	if err := sch.Schedule(&Job{Handler: "echo"}); err == nil {
+		t.Error("expected error of one-off job without time")
+	}
+
+	// Test cases share scheduler, so they depend on previous ones
+	testCases := []struct {
+		name     string
+		now      time.Time
+		expected []string
+		jobs     int
+	}{
+		{name: "missed run", now: now, expected: []string{"missed"}, jobs: 2},
+		{name: "nothing is due", now: now.Add(time.Minute), jobs: 2},
+		{name: "one-off", now: now.Add(2 * time.Hour), expected: []string{"later"}, jobs: 1},
+		{name: "recurring", now: time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC), expected: []string{"weekly"}, jobs: 1},
+		{name: "recurring is rescheduled", now: time.Date(2026, 10, 20, 12, 0, 0, 0, time.UTC), jobs: 1},
+	}
+
+	for _, tc := range testCases {
+		s.Reset()
+		now = tc.now
+		sch.runDue()
+		if err := lc.Shutdown(time.Second); err != nil {
+			t.Fatal(err)
+		}
+		lc = lifecycle.NewManager()
+		sch.lifecycle = lc
+
+		if actual := s.Contents(); !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("%s: expected %q, actual %q", tc.name, tc.expected, actual)
+		}
+		if left, err := sch.Jobs("guild"); err != nil || len(left) != tc.jobs {
+			t.Errorf("%s: expected %d jobs, got %d, %v", tc.name, tc.jobs, len(left), err)
+		}
+	}
+
+	left, err := sch.Jobs("guild")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := time.Date(2026, 10, 26, 12, 0, 0, 0, time.UTC); !left[0].NextRun.Equal(expected) {
+		t.Errorf("expected next run at %v, got %v", expected, left[0].NextRun)
+	}
+}
+
+func Test_Jobs(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 30, 0, 0, time.Local)
+	sch := NewScheduler(newSQLiteStore(t), lifecycle.NewManager())
+	sch.now = func() time.Time { return now }
+	sch.Handle("echo", func(discord.Session, *Job) error { return nil })
+
+	for _, job := range []*Job{
+		{Handler: "echo", Description: "Weekly top in <#general>", GuildID: discordtest.GuildID, Schedule: "0 12 * * 1"},
+		{Handler: "echo", GuildID: discordtest.GuildID, NextRun: now.Add(time.Hour)},
+		{Handler: "echo", GuildID: "other", NextRun: now.Add(time.Hour)},
+	} {
+		if err := sch.Schedule(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rt := router.New("!")
+	if err := rt.Add("Jobs", NewJobs(sch).Commands()...); err != nil {
+		t.Fatal(err)
+	}
+	s := discordtest.NewSession()
+
+	// Test cases share scheduler, so they depend on previous ones
+	testCases := []struct {
+		message  string
+		expected []string
+	}{
+		{message: "!jobs", expected: []string{"Scheduled jobs:\n" +
+			"#2 echo, next run " + now.Add(time.Hour).Format(nextRunLayout) + "\n" +
+			"#1 Weekly top in <#general>, `0 12 * * 1`, next run " + time.Date(2026, 10, 19, 12, 0, 0, 0, time.Local).Format(nextRunLayout)}},
+		{message: "!jobs cancel 3", expected: []string{"Job #3 is not found\nUsage: `!jobs [action] [id]`"}},
+		{message: "!jobs cancel", expected: []string{"Job id is required\nUsage: `!jobs [action] [id]`"}},
+		{message: "!jobs cancel 2", expected: []string{"Job #2 is cancelled"}},
+		{message: "!jobs cancel 2", expected: []string{"Job #2 is not found\nUsage: `!jobs [action] [id]`"}},
+		{message: "!jobs cancel 1", expected: []string{"Job #1 is cancelled"}},
+		{message: "!jobs", expected: []string{"There are no scheduled jobs"}},
+	}
+
+	for _, tc := range testCases {
+		s.Reset()
+		rt.HandleMessage(s, discordtest.Message("general", "1", tc.message))
+		if actual := s.Contents(); !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("%s: expected %q, actual %q", tc.message, tc.expected, actual)
+		}
+	}
+}
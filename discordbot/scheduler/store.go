@@ -0,0 +1,117 @@
+package scheduler
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/paulvasilenko/discordbot/discordbot/metrics"
+	"github.com/paulvasilenko/discordbot/discordbot/storage"
+	"github.com/pkg/errors"
+)
+
+// ErrNotFound is returned when job doesn't exist
+var ErrNotFound = errors.New("job not found")
+
+// Store keeps scheduled jobs, so they survive restarts
+type Store interface {
+	// Add saves new job and sets its ID
+	Add(job *Job) error
+	// SetNextRun changes time of next run of job
+	SetNextRun(id int64, next time.Time) error
+	Delete(id int64) error
+	Get(id int64) (*Job, error)
+	// Due returns jobs which should run at or before now
+	Due(now time.Time) ([]*Job, error)
+	// List returns jobs of guild ordered by next run
+	List(guildID string) ([]*Job, error)
+}
+
+// SQLStore stores jobs in scheduledJobs table, time of next run is unix time,
+// its queries are the same for MySQL and SQLite
+type SQLStore struct {
+	db *sql.DB
+}
+
+// NewSQLStore returns store working with given connection
+func NewSQLStore(db *storage.DB) *SQLStore {
+	return &SQLStore{db: db.DB}
+}
+
+const jobColumns = `id, handler, description, guildId, channelId, createdBy, schedule, payload, nextRun`
+
+func (st *SQLStore) Add(job *Job) error {
+	res, err := st.db.Exec(
+		`INSERT INTO scheduledJobs (handler, description, guildId, channelId, createdBy, schedule, payload, nextRun)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		job.Handler, job.Description, job.GuildID, job.ChannelID, job.CreatedBy, job.Schedule, job.Payload, job.NextRun.Unix(),
+	)
+	if err != nil {
+		return metrics.DBError("scheduler", "add", err)
+	}
+
+	job.ID, err = res.LastInsertId()
+
+	return metrics.DBError("scheduler", "add", err)
+}
+
+func (st *SQLStore) SetNextRun(id int64, next time.Time) error {
+	_, err := st.db.Exec(`UPDATE scheduledJobs SET nextRun = ? WHERE id = ?`, next.Unix(), id)
+
+	return metrics.DBError("scheduler", "set_next_run", err)
+}
+
+func (st *SQLStore) Delete(id int64) error {
+	res, err := st.db.Exec(`DELETE FROM scheduledJobs WHERE id = ?`, id)
+	if err != nil {
+		return metrics.DBError("scheduler", "delete", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (st *SQLStore) Get(id int64) (*Job, error) {
+	jobs, err := st.query("get", `SELECT `+jobColumns+` FROM scheduledJobs WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(jobs) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return jobs[0], nil
+}
+
+func (st *SQLStore) Due(now time.Time) ([]*Job, error) {
+	return st.query("due", `SELECT `+jobColumns+` FROM scheduledJobs WHERE nextRun <= ? ORDER BY nextRun, id`, now.Unix())
+}
+
+func (st *SQLStore) List(guildID string) ([]*Job, error) {
+	return st.query("list", `SELECT `+jobColumns+` FROM scheduledJobs WHERE guildId = ? ORDER BY nextRun, id`, guildID)
+}
+
+func (st *SQLStore) query(operation, query string, args ...interface{}) ([]*Job, error) {
+	rows, err := st.db.Query(query, args...)
+	if err != nil {
+		return nil, metrics.DBError("scheduler", operation, err)
+	}
+	defer rows.Close()
+
+	var jobs []*Job
+	for rows.Next() {
+		var (
+			job     Job
+			nextRun int64
+		)
+		if err := rows.Scan(&job.ID, &job.Handler, &job.Description, &job.GuildID, &job.ChannelID,
+			&job.CreatedBy, &job.Schedule, &job.Payload, &nextRun); err != nil {
+			return nil, metrics.DBError("scheduler", operation, err)
+		}
+		job.NextRun = time.Unix(nextRun, 0)
+		jobs = append(jobs, &job)
+	}
+
+	return jobs, metrics.DBError("scheduler", operation, rows.Err())
+}
@@ -3,14 +3,18 @@ package sdr
 import (
 	"fmt"
 	"math/rand"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
+	"github.com/paulvasilenko/discordbot/discordbot/response"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+	"github.com/pkg/errors"
 )
 
 // SDR represents SDR plugin
 type SDR struct {
+	mu    sync.RWMutex
 	texts map[int]string
 	gifts map[int]Gift
 }
@@ -31,35 +35,80 @@ type Gift struct {
 }
 
 func NewSDR(texts map[int]string, gifts map[int]Gift) (*SDR, error) {
-	return &SDR{
-		texts: texts,
-		gifts: gifts,
-	}, nil
+	r := &SDR{}
+	if err := r.SetData(texts, gifts); err != nil {
+		return nil, err
+	}
+
+	return r, nil
 }
 
-func init() {
-	rand.Seed(time.Now().Unix())
+// SetData replaces postcard texts and gifts, both are maps with keys from 0 to len-1
+func (r *SDR) SetData(texts map[int]string, gifts map[int]Gift) error {
+	if len(texts) == 0 || len(gifts) == 0 {
+		return errors.New("sdr texts and gifts must not be empty")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.texts = texts
+	r.gifts = gifts
+
+	return nil
 }
 
-func (r *SDR) MessageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
-	if !strings.HasPrefix(m.Message.Content, "!sdr") {
-		return
+func (r *SDR) Name() string {
+	return "SDR"
+}
+
+// GetInfo returns map of info message
+func (r *SDR) GetInfo() map[string]string {
+	return map[string]string{
+		"!sdr": "!sdr [@user] - Sends random gift with postcard to mentioned user",
 	}
+}
 
-	if len(m.Mentions) == 0 {
-		s.ChannelMessageSend(m.ChannelID, "mention user to give a gift")
-		return
+func (r *SDR) Handlers() []interface{} {
+	return nil
+}
+
+func (r *SDR) Init(s *discordgo.Session) error {
+	return nil
+}
+
+func (r *SDR) Close() error {
+	return nil
+}
+
+// Commands returns commands of plugin handled by router
+func (r *SDR) Commands() []*router.Command {
+	return []*router.Command{
+		{
+			Name:        "sdr",
+			Description: r.GetInfo()["!sdr"],
+			Args:        []router.Arg{{Name: "user", Type: router.ArgUser, Description: "user to give a gift"}},
+			Handler:     r.sdr,
+			Slash:       true,
+		},
 	}
+}
+
+func init() {
+	rand.Seed(time.Now().Unix())
+}
 
-	mentionedUser := m.Mentions[0]
+func (r *SDR) sdr(ctx *router.Context) error {
+	mentionedUser := ctx.Args.User("user")
 
+	r.mu.RLock()
 	text := r.texts[rand.Intn(len(r.texts))]
 	gift := r.gifts[rand.Intn(len(r.gifts))]
+	r.mu.RUnlock()
 
 	// TODO: mongodb
 
-	s.ChannelMessageSend(
-		m.ChannelID,
+	return response.Reply(ctx, response.Text(
 		fmt.Sprintf(
 			`%v присылает подарок для %v:
 `+"```"+`
@@ -69,7 +118,7 @@ func (r *SDR) MessageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 %v
 %v
 `,
-			m.Author.Mention(), mentionedUser.Mention(), text, gift.Image, gift.Description,
+			ctx.Message.Author.Mention(), mentionedUser.Mention(), text, gift.Image, gift.Description,
 		),
-	)
+	))
 }
@@ -0,0 +1,47 @@
+package sdr
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/paulvasilenko/discordbot/discordbot/discord/discordtest"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+)
+
+func Test_Commands(t *testing.T) {
+	r, err := NewSDR(
+		map[int]string{0: "С праздником!"},
+		map[int]Gift{0: {Image: "http://gift.png", Description: "Панда"}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rt := router.New("!")
+	if err := rt.Add(r.Name(), r.Commands()...); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		message  string
+		expected []string
+	}{
+		{
+			message:  "!sdr <@10>",
+			expected: []string{"<@user> присылает подарок для <@10>:\n```\nС праздником!\n```\nИ подарок:\nhttp://gift.png\nПанда\n"},
+		},
+		{
+			message:  "!sdr",
+			expected: []string{"missing argument user\nUsage: `!sdr <user>`"},
+		},
+	}
+
+	for _, v := range testCases {
+		s := discordtest.NewSession()
+		rt.HandleMessage(s, discordtest.Message("channel", "user", v.message))
+
+		if actual := s.Contents(); !reflect.DeepEqual(actual, v.expected) {
+			t.Errorf("message %q: expected %q, actual %q", v.message, v.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+// Package secret loads secrets from files mounted by docker or kubernetes and hides them in logs
+package secret
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Redacted replaces secrets in logs
+const Redacted = "[REDACTED]"
+
+// FromFile sets value to content of file named in <env>_FILE variable, e.g. DISCORDBOT_TOKEN_FILE.
+// Value is kept if variable is not set, trailing newline of file is trimmed
+func FromFile(env string, value *string) error {
+	path := os.Getenv(env + "_FILE")
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read %s_FILE", env)
+	}
+	*value = strings.TrimRight(string(data), "\r\n")
+
+	return nil
+}
+
+// Redactor is logrus formatter which replaces known secrets in formatted log lines
+type Redactor struct {
+	formatter log.Formatter
+
+	mu       sync.RWMutex
+	secrets  map[string]bool
+	replacer *strings.Replacer
+}
+
+// NewRedactor is a constructor function for redactor wrapping formatter
+func NewRedactor(formatter log.Formatter) *Redactor {
+	return &Redactor{
+		formatter: formatter,
+		secrets:   map[string]bool{},
+		replacer:  strings.NewReplacer(),
+	}
+}
+
+// Add adds secrets which are redacted in logs, empty ones are skipped
+func (r *Redactor) Add(secrets ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, s := range secrets {
+		if s != "" {
+			r.secrets[s] = true
+		}
+	}
+
+	pairs := make([]string, 0, len(r.secrets)*2)
+	for s := range r.secrets {
+		pairs = append(pairs, s, Redacted)
+	}
+	r.replacer = strings.NewReplacer(pairs...)
+}
+
+// Format formats entry with wrapped formatter and redacts secrets
+func (r *Redactor) Format(entry *log.Entry) ([]byte, error) {
+	line, err := r.formatter.Format(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	replacer := r.replacer
+	r.mu.RUnlock()
+
+	return []byte(replacer.Replace(string(line))), nil
+}
@@ -0,0 +1,62 @@
+package secret
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func Test_FromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("Bot secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name     string
+		file     string
+		expected string
+		err      bool
+	}{
+		{name: "not set", expected: "from config"},
+		{name: "file", file: path, expected: "Bot secret"},
+		{name: "missing file", file: path + "-missing", expected: "from config", err: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.file != "" {
+				t.Setenv("DISCORDBOT_TOKEN_FILE", tc.file)
+			}
+
+			value := "from config"
+			err := FromFile("DISCORDBOT_TOKEN", &value)
+			if (err != nil) != tc.err {
+				t.Errorf("expected error %v, got %v", tc.err, err)
+			}
+			if value != tc.expected {
+				t.Errorf("expected %q, actual %q", tc.expected, value)
+			}
+		})
+	}
+}
+
+func Test_Redactor(t *testing.T) {
+	r := NewRedactor(&log.TextFormatter{DisableTimestamp: true})
+	r.Add("Bot secret", "", "root:pass")
+
+	out := &bytes.Buffer{}
+	logger := log.New()
+	logger.SetOutput(out)
+	logger.SetFormatter(r)
+
+	logger.WithField("dsn", "root:pass@tcp(mysqldb:3306)/pandabot").Println("Opening connection with token: Bot secret")
+
+	expected := `level=info msg="Opening connection with token: [REDACTED]" dsn="[REDACTED]@tcp(mysqldb:3306)/pandabot"` + "\n"
+	if out.String() != expected {
+		t.Errorf("expected %q, actual %q", expected, out.String())
+	}
+}
@@ -1,7 +0,0 @@
-package smileystats
-
-import "database/sql"
-
-type DB interface {
-	Query(query string, args ...interface{}) (*sql.Rows, error)
-}
@@ -4,70 +4,173 @@ package smileystats
 import (
 	"fmt"
 	"regexp"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/patrickmn/go-cache"
+	"github.com/paulvasilenko/discordbot/discordbot/discord"
+	"github.com/paulvasilenko/discordbot/discordbot/response"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+	"github.com/paulvasilenko/discordbot/discordbot/scheduler"
 	log "github.com/sirupsen/logrus"
 )
 
+const (
+	// JobWeeklyTop is handler of scheduled job posting smileys top to channel
+	JobWeeklyTop = "smileystats.weekly_top"
+	// WeeklyTopSchedule is Monday noon in time zone of bot
+	WeeklyTopSchedule = "0 12 * * 1"
+)
+
 var (
 	smileyRegex = regexp.MustCompile(`(?i)<(:[^>]+:)(\d+)>`)
 )
 
 // SmileyStats is struct which represents plugin configuration
 type SmileyStats struct {
-	dbConn DB
-	cache  *cache.Cache
+	store     Store
+	cache     *cache.Cache
+	scheduler *scheduler.Scheduler
 
+	mu        sync.RWMutex
 	blacklist map[string]string
 }
 
 // NewSmileyStats returns set up instance of SmileyStats
-func NewSmileyStats(conn DB, blacklist map[string]string) *SmileyStats {
+func NewSmileyStats(store Store, blacklist map[string]string) *SmileyStats {
 	return &SmileyStats{
-		dbConn:    conn,
+		store:     store,
 		cache:     cache.New(cache.NoExpiration, cache.NoExpiration),
 		blacklist: blacklist,
 	}
 }
 
+// SetBlacklist replaces list of smileys which are not counted
+func (sm *SmileyStats) SetBlacklist(blacklist map[string]string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.blacklist = blacklist
+}
+
+// Blacklist returns copy of list of smileys which are not counted
+func (sm *SmileyStats) Blacklist() map[string]string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	blacklist := make(map[string]string, len(sm.blacklist))
+	for name, id := range sm.blacklist {
+		blacklist[name] = id
+	}
+
+	return blacklist
+}
+
+func (sm *SmileyStats) blacklisted(name string) bool {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	_, ok := sm.blacklist[name]
+	return ok
+}
+
 func (sm *SmileyStats) GetInfo() map[string]string {
 	return map[string]string{
-		"!pts": "Prints top 10 of emojis used. Pass emoji as an argument to see personal stat for this emoji",
+		"!pts":       "Prints top 10 of emojis used. Pass emoji as an argument to see personal stat for this emoji",
+		"!ptsweekly": "Posts top 10 of emojis to channel every Monday, current channel is used if not passed",
 	}
 }
 
-// MessageCreate is method which triggers when message sent to discord chat
-func (sm *SmileyStats) MessageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
-	if m.Author.Bot {
-		return
+func (sm *SmileyStats) Name() string {
+	return "SmileyStats"
+}
+
+func (sm *SmileyStats) Handlers() []interface{} {
+	return []interface{}{sm.MessageCreate, sm.MessageReactionAdd}
+}
+
+// SetScheduler sets scheduler of weekly top
+func (sm *SmileyStats) SetScheduler(s *scheduler.Scheduler) {
+	sm.scheduler = s
+}
+
+func (sm *SmileyStats) Init(s *discordgo.Session) error {
+	if sm.scheduler != nil {
+		sm.scheduler.Handle(JobWeeklyTop, sm.weeklyTop)
 	}
 
-	if m.Content == "!printtopsmileys" || m.Content == "!pts" {
-		if err := sm.printTopStats(s, m.ChannelID); err != nil {
-			log.Println("printTopStats error: ", err)
-		}
+	return nil
+}
 
-		return
+func (sm *SmileyStats) Close() error {
+	return nil
+}
+
+// Commands returns commands of plugin handled by router
+func (sm *SmileyStats) Commands() []*router.Command {
+	return []*router.Command{
+		{
+			Name:        "pts",
+			Aliases:     []string{"printtopsmileys"},
+			Description: sm.GetInfo()["!pts"],
+			Args: []router.Arg{
+				{Name: "user", Type: router.ArgUser, Optional: true, Description: "user to show stats of"},
+				{Name: "emoji", Type: router.ArgEmoji, Optional: true, Description: "emoji to show stats of"},
+			},
+			Handler: sm.pts,
+			Slash:   true,
+		},
+		{
+			Name:        "ptsweekly",
+			Description: sm.GetInfo()["!ptsweekly"],
+			Args: []router.Arg{
+				{Name: "channel", Type: router.ArgChannel, Optional: true, Description: "channel to post top to"},
+			},
+			Handler:    sm.ptsWeekly,
+			Permission: discordgo.PermissionManageServer,
+		},
 	}
+}
 
-	smileys := smileyRegex.FindAllStringSubmatch(m.Content, -1)
-	if strings.HasPrefix(m.Content, "!pts") {
-		if len(m.Mentions) > 0 {
-			sm.printUserStat(s, m.Mentions[0].ID, m.ChannelID)
-		} else if smileys != nil {
-			sm.printSmileyStat(s, smileys[0][1], m.ChannelID)
+func (sm *SmileyStats) pts(ctx *router.Context) error {
+	var stats string
+	var err error
+	if user := ctx.Args.User("user"); user != nil {
+		stats, err = sm.userStat(user.ID)
+	} else if emoji, ok := ctx.Args.Emoji("emoji"); ok {
+		name := emoji.Name
+		if emoji.ID != "" {
+			name = ":" + name + ":"
 		}
+		stats, err = sm.smileyStat(name)
+	} else {
+		stats, err = sm.topStats()
+	}
+	if err != nil {
+		return err
+	}
+
+	// Reply answers slash command, so it must not be empty
+	if stats == "" {
+		stats = "No smileys are counted yet"
+	}
+
+	return response.Reply(ctx, response.Text(stats))
+}
+
+// MessageCreate is method which triggers when message sent to discord chat
+func (sm *SmileyStats) MessageCreate(s discord.Session, m *discordgo.MessageCreate) {
+	if m.Author.Bot {
 		return
 	}
 
+	smileys := smileyRegex.FindAllStringSubmatch(m.Content, -1)
 	if smileys == nil {
 		return
 	}
 	for _, smiley := range smileys {
-		if _, ok := sm.blacklist[smiley[1]]; ok {
+		if sm.blacklisted(smiley[1]) {
 			continue
 		}
 		if err := sm.insertSmiley(smiley[2], smiley[1], m.Author.ID, m.Author.Username); err != nil {
@@ -77,7 +180,7 @@ func (sm *SmileyStats) MessageCreate(s *discordgo.Session, m *discordgo.MessageC
 	}
 }
 
-func (sm *SmileyStats) MessageReactionAdd(s *discordgo.Session, mr *discordgo.MessageReactionAdd) {
+func (sm *SmileyStats) MessageReactionAdd(s discord.Session, mr *discordgo.MessageReactionAdd) {
 	if mr.Emoji.ID == "" {
 		return
 	}
@@ -91,7 +194,7 @@ func (sm *SmileyStats) MessageReactionAdd(s *discordgo.Session, mr *discordgo.Me
 		return
 	}
 
-	if _, ok := sm.blacklist[mr.Emoji.Name]; ok {
+	if sm.blacklisted(mr.Emoji.Name) {
 		return
 	}
 
@@ -101,162 +204,139 @@ func (sm *SmileyStats) MessageReactionAdd(s *discordgo.Session, mr *discordgo.Me
 	}
 }
 
-func (sm *SmileyStats) insertSmiley(emojiID, emojiName, authorID, authorName string) error {
-	if _, ok := sm.cache.Get(emojiID + authorID); ok {
-		return nil
+func (sm *SmileyStats) ptsWeekly(ctx *router.Context) error {
+	m := ctx.Message
+	if m.GuildID == "" || sm.scheduler == nil {
+		_, err := ctx.Reply("Weekly top is available only on servers")
+		return err
 	}
 
-	sqlString := `
-		INSERT IGNORE INTO smileyHistory
-			(emojiId, emojiName, userId, userName, createDatetime)
-		VALUES
-			(?, ?, ?, ?, ?);`
-
-	r, err := sm.dbConn.Query(
-		sqlString,
-		emojiID,
-		emojiName,
-		authorID,
-		authorName,
-		time.Now().Format("2006-01-02 15:04:05"),
-	)
+	channelID := ctx.Args.Channel("channel")
+	if channelID == "" {
+		channelID = m.ChannelID
+	}
 
+	jobs, err := sm.scheduler.Jobs(m.GuildID)
 	if err != nil {
 		return err
 	}
+	for _, job := range jobs {
+		if job.Handler == JobWeeklyTop && job.ChannelID == channelID {
+			_, err := ctx.Reply(fmt.Sprintf("Weekly top is already posted to <#%s>, cancel it with `%sjobs cancel %d`", channelID, ctx.Prefix, job.ID))
+			return err
+		}
+	}
 
-	sm.cache.Set(emojiID+authorID, true, 1*time.Second)
-
-	defer r.Close()
+	job := &scheduler.Job{
+		Handler:     JobWeeklyTop,
+		Description: fmt.Sprintf("Weekly smileys top in <#%s>", channelID),
+		GuildID:     m.GuildID,
+		ChannelID:   channelID,
+		CreatedBy:   m.Author.ID,
+		Schedule:    WeeklyTopSchedule,
+	}
+	if err := sm.scheduler.Schedule(job); err != nil {
+		return err
+	}
 
-	return nil
+	_, err = ctx.Reply(fmt.Sprintf("Smileys top will be posted to <#%s> every Monday, job #%d", channelID, job.ID))
+	return err
 }
 
-func (sm *SmileyStats) printTopStats(s *discordgo.Session, channelID string) error {
-	sqlString := `
-	SELECT COUNT(emojiId) as usages, emojiName, emojiId
-	FROM smileyHistory
-	GROUP BY emojiName ORDER BY usages DESC LIMIT 10`
-
-	rows, err := sm.dbConn.Query(sqlString)
-
+func (sm *SmileyStats) weeklyTop(s discord.Session, job *scheduler.Job) error {
+	stats, err := sm.topStats()
 	if err != nil {
 		return err
 	}
 
-	defer rows.Close()
-
-	stats := "Smileys top:\n"
-
-	i := 0
-	for rows.Next() {
-		i += 1
-
-		var count, emoticonName, emoticonId string
-		rows.Scan(&count, &emoticonName, &emoticonId)
+	return response.Send(s, job.ChannelID, response.Text(stats))
+}
 
-		smileyString := ""
+func (sm *SmileyStats) insertSmiley(emojiID, emojiName, authorID, authorName string) error {
+	if _, ok := sm.cache.Get(emojiID + authorID); ok {
+		return nil
+	}
 
-		if emoticonId != "" {
-			smileyString = fmt.Sprintf("<%s%v>", emoticonName, emoticonId)
-		} else {
-			smileyString = emoticonName
-		}
+	err := sm.store.Insert(Usage{
+		EmojiID:   emojiID,
+		EmojiName: emojiName,
+		UserID:    authorID,
+		UserName:  authorName,
+		Time:      time.Now(),
+	})
 
-		stats += fmt.Sprintf("#%d - %s %s usages\n", i, smileyString, count)
+	if err != nil {
+		return err
 	}
 
-	s.ChannelMessageSend(channelID, stats)
+	sm.cache.Set(emojiID+authorID, true, 1*time.Second)
 
 	return nil
 }
 
-func (sm *SmileyStats) printSmileyStat(s *discordgo.Session, smiley, channelID string) error {
-	sqlString := `
-	SELECT COUNT(emojiId) as usages, emojiName, emojiId, userName
-	FROM smileyHistory
-	WHERE emojiName = ?
-	GROUP BY userId ORDER BY usages DESC LIMIT 10`
-
-	rows, err := sm.dbConn.Query(sqlString, smiley)
+// topStats returns text of most used smileys
+func (sm *SmileyStats) topStats() (string, error) {
+	top, err := sm.store.Top(10)
 
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	defer rows.Close()
+	stats := "Smileys top:\n"
 
-	stats := ""
+	for i, stat := range top {
+		stats += fmt.Sprintf("#%d - %s %d usages\n", i+1, smileyString(stat), stat.Usages)
+	}
 
-	i := 0
-	for rows.Next() {
-		i += 1
+	return stats, nil
+}
 
-		var count, emoticonName, emoticonId, userName string
-		rows.Scan(&count, &emoticonName, &emoticonId, &userName)
+// smileyStat returns text of users who use smiley most, it's empty if smiley is not used
+func (sm *SmileyStats) smileyStat(smiley string) (string, error) {
+	top, err := sm.store.SmileyTop(smiley, 10)
 
-		if i == 1 {
-			smileyString := ""
+	if err != nil {
+		return "", err
+	}
 
-			if emoticonId != "" {
-				smileyString = fmt.Sprintf("<%s%v>", emoticonName, emoticonId)
-			} else {
-				smileyString = emoticonName
-			}
+	stats := ""
 
-			stats += fmt.Sprintf("Smiley %s top:\n", smileyString)
+	for i, stat := range top {
+		if i == 0 {
+			stats += fmt.Sprintf("Smiley %s top:\n", smileyString(stat))
 		}
 
-		stats += fmt.Sprintf("#%d - %s %s usages\n", i, userName, count)
+		stats += fmt.Sprintf("#%d - %s %d usages\n", i+1, stat.UserName, stat.Usages)
 	}
 
-	s.ChannelMessageSend(channelID, stats)
-
-	return nil
+	return stats, nil
 }
 
-func (sm *SmileyStats) printUserStat(s *discordgo.Session, userID, channelID string) error {
-	sqlString := `
-	SELECT COUNT(emojiId) as usages, emojiName, emojiId, userName
-	FROM smileyHistory
-	WHERE userId = ?
-	GROUP BY emojiName
-	ORDER BY usages
-	DESC LIMIT 10;`
-
-	rows, err := sm.dbConn.Query(sqlString, userID)
+// userStat returns text of smileys most used by user, it's empty if user used none
+func (sm *SmileyStats) userStat(userID string) (string, error) {
+	top, err := sm.store.UserTop(userID, 10)
 
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	defer rows.Close()
-
 	stats := ""
 
-	i := 0
-	for rows.Next() {
-		i += 1
-
-		var count, emoticonName, emoticonId, userName string
-		rows.Scan(&count, &emoticonName, &emoticonId, &userName)
-
-		if i == 1 {
+	for i, stat := range top {
+		if i == 0 {
 			stats += fmt.Sprintf("User <@%s> top:\n", userID)
 		}
 
-		smileyString := ""
+		stats += fmt.Sprintf("#%d - %s %d usages\n", i+1, smileyString(stat), stat.Usages)
+	}
 
-		if emoticonId != "" {
-			smileyString = fmt.Sprintf("<%s%v>", emoticonName, emoticonId)
-		} else {
-			smileyString = emoticonName
-		}
+	return stats, nil
+}
 
-		stats += fmt.Sprintf("#%d - %s %s usages\n", i, smileyString, count)
+func smileyString(stat Stat) string {
+	if stat.EmojiID != "" {
+		return fmt.Sprintf("<%s%v>", stat.EmojiName, stat.EmojiID)
 	}
 
-	s.ChannelMessageSend(channelID, stats)
-
-	return nil
+	return stat.EmojiName
 }
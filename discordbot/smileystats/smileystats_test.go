@@ -0,0 +1,157 @@
+package smileystats
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/paulvasilenko/discordbot/discordbot/discord/discordtest"
+	"github.com/paulvasilenko/discordbot/discordbot/lifecycle"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+	"github.com/paulvasilenko/discordbot/discordbot/scheduler"
+)
+
+func Test_Commands(t *testing.T) {
+	sm := NewSmileyStats(newSQLiteStore(t), map[string]string{":lul:": "3"})
+	rt := router.New("!")
+	if err := rt.Add(sm.Name(), sm.Commands()...); err != nil {
+		t.Fatal(err)
+	}
+
+	s := discordtest.NewSession()
+	s.Users["30"] = &discordgo.User{ID: "30", Username: "fox"}
+	s.Users["40"] = &discordgo.User{ID: "40", Username: "robot", Bot: true}
+
+	messages := []struct {
+		authorID string
+		username string
+		content  string
+	}{
+		{authorID: "10", username: "panda", content: "<:kappa:1> hi <:Kappa:2>"},
+		{authorID: "20", username: "bear", content: "<:kappa:1> <:lul:3>"},
+	}
+	for _, v := range messages {
+		m := discordtest.Message("channel", v.authorID, v.content)
+		m.Author.Username = v.username
+		sm.MessageCreate(s, &discordgo.MessageCreate{Message: m})
+	}
+
+	reactions := []*discordgo.MessageReaction{
+		{UserID: "30", ChannelID: "channel", Emoji: discordgo.Emoji{ID: "1", Name: "kappa"}},
+		// Unicode emojis and reactions of bots are not counted
+		{UserID: "30", ChannelID: "channel", Emoji: discordgo.Emoji{Name: "🐼"}},
+		{UserID: "40", ChannelID: "channel", Emoji: discordgo.Emoji{ID: "2", Name: "Kappa"}},
+	}
+	for _, r := range reactions {
+		sm.MessageReactionAdd(s, &discordgo.MessageReactionAdd{MessageReaction: r})
+	}
+
+	testCases := []struct {
+		message  string
+		expected []string
+	}{
+		{
+			message:  "!pts",
+			expected: []string{"Smileys top:\n#1 - <:kappa:1> 3 usages\n#2 - <:Kappa:2> 1 usages\n"},
+		},
+		{
+			message:  "!printtopsmileys <:Kappa:2>",
+			expected: []string{"Smiley <:Kappa:2> top:\n#1 - panda 1 usages\n"},
+		},
+		{
+			message:  "!pts <@20>",
+			expected: []string{"User <@20> top:\n#1 - <:kappa:1> 1 usages\n"},
+		},
+	}
+
+	for _, v := range testCases {
+		s.Reset()
+		rt.HandleMessage(s, discordtest.Message("channel", "10", v.message))
+
+		if actual := s.Contents(); !reflect.DeepEqual(actual, v.expected) {
+			t.Errorf("message %q: expected %q, actual %q", v.message, v.expected, actual)
+		}
+	}
+}
+
+func Test_PtsWeekly(t *testing.T) {
+	db := newSQLiteDB(t)
+	sm := NewSmileyStats(NewStore(db), nil)
+	sm.SetScheduler(scheduler.NewScheduler(scheduler.NewSQLStore(db), lifecycle.NewManager()))
+	if err := sm.Init(nil); err != nil {
+		t.Fatal(err)
+	}
+	rt := router.New("!")
+	if err := rt.Add(sm.Name(), sm.Commands()...); err != nil {
+		t.Fatal(err)
+	}
+
+	s := discordtest.NewSession()
+
+	// Test cases share scheduler, so they depend on previous ones
+	testCases := []struct {
+		message  string
+		expected []string
+	}{
+		{message: "!ptsweekly", expected: []string{"Smileys top will be posted to <#channel> every Monday, job #1"}},
+		{message: "!ptsweekly", expected: []string{"Weekly top is already posted to <#channel>, cancel it with `!jobs cancel 1`"}},
+		{message: "!ptsweekly <#20>", expected: []string{"Smileys top will be posted to <#20> every Monday, job #2"}},
+	}
+
+	for _, v := range testCases {
+		s.Reset()
+		rt.HandleMessage(s, discordtest.Message("channel", "10", v.message))
+
+		if actual := s.Contents(); !reflect.DeepEqual(actual, v.expected) {
+			t.Errorf("message %q: expected %q, actual %q", v.message, v.expected, actual)
+		}
+	}
+}
+
+func Test_PtsSlash(t *testing.T) {
+	sm := NewSmileyStats(newSQLiteStore(t), nil)
+	rt := router.New("!")
+	if err := rt.Add(sm.Name(), sm.Commands()...); err != nil {
+		t.Fatal(err)
+	}
+
+	s := discordtest.NewSession()
+	m := discordtest.Message("channel", "10", "<:kappa:1>")
+	m.Author.Username = "panda"
+	sm.MessageCreate(s, &discordgo.MessageCreate{Message: m})
+
+	testCases := []struct {
+		name     string
+		options  []*discordgo.ApplicationCommandInteractionDataOption
+		expected []string
+	}{
+		{
+			name:     "top",
+			expected: []string{"Smileys top:\n#1 - <:kappa:1> 1 usages\n"},
+		},
+		{
+			name:     "user without smileys",
+			options:  []*discordgo.ApplicationCommandInteractionDataOption{{Name: "user", Type: discordgo.ApplicationCommandOptionUser, Value: "20"}},
+			expected: []string{"No smileys are counted yet"},
+		},
+	}
+
+	for _, tc := range testCases {
+		s.Reset()
+		rt.HandleInteraction(s, &discordgo.Interaction{
+			ID:        "interaction",
+			Type:      discordgo.InteractionApplicationCommand,
+			ChannelID: "channel",
+			GuildID:   discordtest.GuildID,
+			Member:    &discordgo.Member{User: &discordgo.User{ID: "10"}},
+			Data:      discordgo.ApplicationCommandInteractionData{Name: "pts", Options: tc.options},
+		})
+
+		if len(s.Responses) != 1 {
+			t.Errorf("%s: expected one interaction response, actual %d", tc.name, len(s.Responses))
+		}
+		if actual := s.Contents(); !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("%s: expected %q, actual %q", tc.name, tc.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package smileystats
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/paulvasilenko/discordbot/discordbot/metrics"
+	"github.com/paulvasilenko/discordbot/discordbot/storage"
+)
+
+const DatetimeLayout = "2006-01-02 15:04:05"
+
+// Usage is a single usage of smiley by user
+type Usage struct {
+	EmojiID   string
+	EmojiName string
+	UserID    string
+	UserName  string
+	Time      time.Time
+}
+
+// Stat is number of smiley usages, UserName is set for stats grouped by users
+type Stat struct {
+	EmojiID   string
+	EmojiName string
+	UserName  string
+	Usages    int
+}
+
+// Store keeps history of smiley usages
+type Store interface {
+	// Insert saves usage, repeated usage at the same second is ignored
+	Insert(u Usage) error
+	// Top returns most used smileys
+	Top(limit int) ([]Stat, error)
+	// SmileyTop returns users who used smiley the most
+	SmileyTop(emojiName string, limit int) ([]Stat, error)
+	// UserTop returns smileys used by user the most
+	UserTop(userID string, limit int) ([]Stat, error)
+}
+
+// NewStore returns store implementation for driver of database
+func NewStore(db *storage.DB) Store {
+	if db.Driver == storage.SQLite {
+		return NewSQLiteStore(db.DB)
+	}
+
+	return NewMySQLStore(db.DB)
+}
+
+// sqlStore implements queries shared by MySQL and SQLite
+type sqlStore struct {
+	db *sql.DB
+
+	insertQuery string
+}
+
+// NewMySQLStore returns store working with smileyHistory table in MySQL
+func NewMySQLStore(db *sql.DB) Store {
+	return &sqlStore{
+		db: db,
+		insertQuery: `
+		INSERT IGNORE INTO smileyHistory
+			(emojiId, emojiName, userId, userName, createDatetime)
+		VALUES
+			(?, ?, ?, ?, ?);`,
+	}
+}
+
+// NewSQLiteStore returns store working with smileyHistory table in SQLite
+func NewSQLiteStore(db *sql.DB) Store {
+	return &sqlStore{
+		db: db,
+		insertQuery: `
+		INSERT OR IGNORE INTO smileyHistory
+			(emojiId, emojiName, userId, userName, createDatetime)
+		VALUES
+			(?, ?, ?, ?, ?);`,
+	}
+}
+
+func (st *sqlStore) Insert(u Usage) error {
+	_, err := st.db.Exec(
+		st.insertQuery,
+		u.EmojiID,
+		u.EmojiName,
+		u.UserID,
+		u.UserName,
+		u.Time.Format(DatetimeLayout),
+	)
+
+	return metrics.DBError("smileystats", "insert", err)
+}
+
+func (st *sqlStore) Top(limit int) ([]Stat, error) {
+	sqlString := `
+	SELECT COUNT(emojiId) as usages, emojiName, emojiId, ''
+	FROM smileyHistory
+	GROUP BY emojiName ORDER BY usages DESC LIMIT ?`
+
+	return st.stats("top", sqlString, limit)
+}
+
+func (st *sqlStore) SmileyTop(emojiName string, limit int) ([]Stat, error) {
+	sqlString := `
+	SELECT COUNT(emojiId) as usages, emojiName, emojiId, userName
+	FROM smileyHistory
+	WHERE emojiName = ?
+	GROUP BY userId ORDER BY usages DESC LIMIT ?`
+
+	return st.stats("smiley_top", sqlString, emojiName, limit)
+}
+
+func (st *sqlStore) UserTop(userID string, limit int) ([]Stat, error) {
+	sqlString := `
+	SELECT COUNT(emojiId) as usages, emojiName, emojiId, userName
+	FROM smileyHistory
+	WHERE userId = ?
+	GROUP BY emojiName
+	ORDER BY usages
+	DESC LIMIT ?;`
+
+	return st.stats("user_top", sqlString, userID, limit)
+}
+
+func (st *sqlStore) stats(operation, query string, args ...interface{}) ([]Stat, error) {
+	rows, err := st.db.Query(query, args...)
+	if err != nil {
+		return nil, metrics.DBError("smileystats", operation, err)
+	}
+	defer rows.Close()
+
+	var stats []Stat
+	for rows.Next() {
+		var s Stat
+		if err := rows.Scan(&s.Usages, &s.EmojiName, &s.EmojiID, &s.UserName); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+
+	return stats, rows.Err()
+}
@@ -0,0 +1,94 @@
+package smileystats
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/paulvasilenko/discordbot/discordbot/storage"
+	"github.com/paulvasilenko/discordbot/migrations"
+)
+
+func newSQLiteStore(t *testing.T) Store {
+	return NewStore(newSQLiteDB(t))
+}
+
+func newSQLiteDB(t *testing.T) *storage.DB {
+	db, err := storage.Open(storage.SQLite, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	runner, err := migrations.NewRunner(db.DB, db.Driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runner.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func Test_sqlStore(t *testing.T) {
+	st := newSQLiteStore(t)
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	usages := []Usage{
+		{EmojiID: "1", EmojiName: ":kappa:", UserID: "10", UserName: "panda", Time: now},
+		// Same second is ignored
+		{EmojiID: "1", EmojiName: ":kappa:", UserID: "10", UserName: "panda", Time: now},
+		{EmojiID: "1", EmojiName: ":kappa:", UserID: "10", UserName: "panda", Time: now.Add(time.Second)},
+		{EmojiID: "1", EmojiName: ":kappa:", UserID: "20", UserName: "bear", Time: now},
+		{EmojiID: "2", EmojiName: ":Kappa:", UserID: "20", UserName: "bear", Time: now},
+		{EmojiID: "2", EmojiName: ":Kappa:", UserID: "20", UserName: "bear", Time: now.Add(time.Second)},
+	}
+	for _, u := range usages {
+		if err := st.Insert(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		stats    func() ([]Stat, error)
+		expected []Stat
+	}{
+		{
+			name:  "top",
+			stats: func() ([]Stat, error) { return st.Top(10) },
+			expected: []Stat{
+				{EmojiID: "1", EmojiName: ":kappa:", Usages: 3},
+				{EmojiID: "2", EmojiName: ":Kappa:", Usages: 2},
+			},
+		},
+		{
+			name:  "smiley top",
+			stats: func() ([]Stat, error) { return st.SmileyTop(":kappa:", 10) },
+			expected: []Stat{
+				{EmojiID: "1", EmojiName: ":kappa:", UserName: "panda", Usages: 2},
+				{EmojiID: "1", EmojiName: ":kappa:", UserName: "bear", Usages: 1},
+			},
+		},
+		{
+			name:  "user top with limit",
+			stats: func() ([]Stat, error) { return st.UserTop("20", 1) },
+			expected: []Stat{
+				{EmojiID: "2", EmojiName: ":Kappa:", UserName: "bear", Usages: 2},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stats, err := tc.stats()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(stats, tc.expected) {
+				t.Errorf("expected %+v, got %+v", tc.expected, stats)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+// Package storage opens database used by plugins, it is either MySQL server or embedded SQLite file
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/pkg/errors"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	MySQL  = "mysql"
+	SQLite = "sqlite"
+)
+
+// DB is database connection with name of its driver, plugins choose store implementation by driver
+type DB struct {
+	*sql.DB
+	Driver string
+}
+
+// Open opens database of given driver, dsn of sqlite is path to database file or :memory:
+func Open(driver, dsn string) (*DB, error) {
+	switch driver {
+	case MySQL:
+		db, err := sql.Open(MySQL, dsn)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to open mysql connection")
+		}
+
+		db.SetMaxIdleConns(1)
+		db.SetMaxOpenConns(50)
+		db.SetConnMaxLifetime(1 * time.Second)
+
+		return &DB{DB: db, Driver: driver}, nil
+	case SQLite:
+		db, err := sql.Open(SQLite, "file:"+dsn+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to open sqlite database")
+		}
+
+		// SQLite allows single writer, and every connection to :memory: is separate database
+		db.SetMaxOpenConns(1)
+
+		return &DB{DB: db, Driver: driver}, nil
+	default:
+		return nil, errors.Errorf("unknown storage driver %q, expected %s or %s", driver, MySQL, SQLite)
+	}
+}
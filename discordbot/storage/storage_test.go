@@ -0,0 +1,50 @@
+package storage
+
+import "testing"
+
+func Test_Open(t *testing.T) {
+	testCases := []struct {
+		name   string
+		driver string
+		dsn    string
+		err    bool
+	}{
+		{name: "sqlite", driver: SQLite, dsn: ":memory:"},
+		{name: "mysql", driver: MySQL, dsn: "root:root@tcp(localhost:3306)/pandabot"},
+		{name: "unknown", driver: "postgres", err: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, err := Open(tc.driver, tc.dsn)
+			if tc.err {
+				if err == nil {
+					t.Error("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			if db.Driver != tc.driver {
+				t.Errorf("expected driver %s, got %s", tc.driver, db.Driver)
+			}
+		})
+	}
+
+	db, err := Open(SQLite, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var foreignKeys int
+	if err := db.QueryRow("PRAGMA foreign_keys").Scan(&foreignKeys); err != nil {
+		t.Fatal(err)
+	}
+	if foreignKeys != 1 {
+		t.Error("expected foreign keys to be enabled")
+	}
+}
@@ -5,20 +5,36 @@ import (
 	"crypto/md5"
 	"fmt"
 	"io"
+	"time"
 	"unicode/utf8"
 
 	"github.com/bwmarrin/discordgo"
+	"github.com/paulvasilenko/discordbot/discordbot/discord"
+	"github.com/paulvasilenko/discordbot/discordbot/metrics"
+	"github.com/paulvasilenko/discordbot/discordbot/ratelimit"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/sync/errgroup"
 )
 
+// CommandSpeak is a name of message command which converts message to speech
+const CommandSpeak = "Speak"
+
 type TTSProvider interface {
 	Process(text string) (io.Reader, error)
 }
 
+// Limiter limits number of calls of command
+type Limiter interface {
+	Allow(command, guildID, channelID, userID string) (time.Duration, error)
+}
+
 // TextToSpeech is a plugin to support text to speech feature
 type TextToSpeech struct {
 	Provider TTSProvider
+	// Limiter is optional, it limits voice messages requested with reactions
+	Limiter Limiter
 }
 
 func NewTTS(p TTSProvider) *TextToSpeech {
@@ -27,19 +43,104 @@ func NewTTS(p TTSProvider) *TextToSpeech {
 	}
 }
 
+func (tts *TextToSpeech) Name() string {
+	return "TTS"
+}
+
+// GetInfo returns map of info message
+func (tts *TextToSpeech) GetInfo() map[string]string {
+	return map[string]string{
+		"🔈":          "React with 🔈 to message to get it as voice message",
+		CommandSpeak: "Choose Apps > Speak in message menu to get it as voice message",
+	}
+}
+
+func (tts *TextToSpeech) Handlers() []interface{} {
+	return []interface{}{tts.MessageReactionAdd}
+}
+
+func (tts *TextToSpeech) Init(s *discordgo.Session) error {
+	return nil
+}
+
+func (tts *TextToSpeech) Close() error {
+	return nil
+}
+
+// Commands returns commands of plugin handled by router
+func (tts *TextToSpeech) Commands() []*router.Command {
+	return []*router.Command{
+		{
+			Name:           CommandSpeak,
+			Description:    "Converts message to voice message",
+			Handler:        tts.speakCommand,
+			MessageCommand: true,
+			Deferred:       true,
+		},
+	}
+}
+
+func (tts *TextToSpeech) speakCommand(ctx *router.Context) error {
+	if ctx.Target == nil {
+		return errors.New("target message not found")
+	}
+
+	data, err := tts.speak(ctx.Target.Content)
+	if err != nil {
+		_, replyErr := ctx.Reply(err.Error())
+		if replyErr != nil {
+			log.Println("failed to reply: ", replyErr)
+		}
+		return errors.Wrap(err, "failed to convert message to speech")
+	}
+
+	_, err = ctx.ReplyComplex(data)
+	return err
+}
+
 // MessageReactionAdd
-func (tts *TextToSpeech) MessageReactionAdd(s *discordgo.Session, mr *discordgo.MessageReactionAdd) {
+func (tts *TextToSpeech) MessageReactionAdd(s discord.Session, mr *discordgo.MessageReactionAdd) {
 	if mr.Emoji.Name != "🔈" {
 		return
 	}
 
+	if tts.Limiter != nil {
+		wait, err := tts.Limiter.Allow(CommandSpeak, mr.GuildID, mr.ChannelID, mr.UserID)
+		if err != nil {
+			log.Println("Error checking rate limit: ", err)
+			return
+		}
+		if wait > 0 {
+			if _, err := s.ChannelMessageSend(mr.ChannelID, fmt.Sprintf("<@%s>, next voice message will be available in %s", mr.UserID, ratelimit.FormatWait(wait))); err != nil {
+				log.Println("Error sending message: ", err)
+			}
+			return
+		}
+	}
+
 	message, err := s.ChannelMessage(mr.ChannelID, mr.MessageID)
 	if err != nil {
 		log.Println("Error getting message: ", err)
 		return
 	}
 
-	parts := splitTextToParts(message.Content, 255)
+	data, err := tts.speak(message.Content)
+	if err != nil {
+		log.Println("Error converting message to speech: ", err)
+		if _, err := s.ChannelMessageSend(mr.ChannelID, err.Error()); err != nil {
+			log.Println("Error sending message: ", err)
+		}
+		return
+	}
+
+	if _, err := s.ChannelMessageSendComplex(mr.ChannelID, data); err != nil {
+		log.Println("Error sending voice message: ", err)
+	}
+}
+
+// speak converts text to message with ogg file
+func (tts *TextToSpeech) speak(text string) (*discordgo.MessageSend, error) {
+	parts := splitTextToParts(text, 255)
 	processedParts := make([]io.Reader, len(parts))
 
 	gr, _ := errgroup.WithContext(context.Background())
@@ -47,8 +148,11 @@ func (tts *TextToSpeech) MessageReactionAdd(s *discordgo.Session, mr *discordgo.
 		v := v
 		i := i
 		gr.Go(func() error {
+			start := time.Now()
 			content, err := tts.Provider.Process(v)
+			metrics.TTSDuration.Observe(time.Since(start).Seconds())
 			if err != nil {
+				metrics.TTSErrors.Inc()
 				return err
 			}
 			processedParts[i] = content
@@ -56,26 +160,23 @@ func (tts *TextToSpeech) MessageReactionAdd(s *discordgo.Session, mr *discordgo.
 		})
 	}
 	if err := gr.Wait(); err != nil {
-		s.ChannelMessageSend(mr.ChannelID, err.Error())
-		log.Println("Error converting message to speech: ", err)
-		return
+		return nil, err
 	}
 
 	mergedStream, err := MergeOggStreams(processedParts)
 	if err != nil {
-		log.Println("Error converting message to speech: ", err)
-		return
+		return nil, err
 	}
 
-	s.ChannelMessageSendComplex(mr.ChannelID, &discordgo.MessageSend{
+	return &discordgo.MessageSend{
 		Files: []*discordgo.File{
 			{
-				Name:        fmt.Sprintf("%x", md5.Sum([]byte(message.Content))) + ".ogg",
+				Name:        fmt.Sprintf("%x", md5.Sum([]byte(text))) + ".ogg",
 				ContentType: "audio/ogg",
 				Reader:      mergedStream,
 			},
 		},
-	})
+	}, nil
 }
 
 func splitTextToParts(text string, partLen int) []string {
@@ -2,10 +2,20 @@ package tts
 
 import (
 	"bytes"
+	"crypto/md5"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/paulvasilenko/discordbot/discordbot/discord/discordtest"
+	"github.com/paulvasilenko/discordbot/discordbot/router"
+	"github.com/pkg/errors"
 )
 
 func Test_splitTextToParts(t *testing.T) {
@@ -48,4 +58,94 @@ func Test_readFile(t *testing.T) {
 func Test_englishOrRussian(t *testing.T) {
 	text := `Your mom гей`
 	fmt.Println(lettersStats(text))
-}
\ No newline at end of file
+}
+
+type fakeProvider struct {
+	err error
+}
+
+func (p fakeProvider) Process(text string) (io.Reader, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	return strings.NewReader("OggS" + text), nil
+}
+
+// fakeLimiter denies every call for wait
+type fakeLimiter struct {
+	wait time.Duration
+}
+
+func (l fakeLimiter) Allow(command, guildID, channelID, userID string) (time.Duration, error) {
+	return l.wait, nil
+}
+
+func Test_Speak(t *testing.T) {
+	testCases := []struct {
+		name        string
+		emoji       string
+		interaction bool
+		wait        time.Duration
+		err         error
+		expected    []string
+		file        string
+	}{
+		{name: "reaction", emoji: "🔈", expected: []string{""}, file: "OggShello"},
+		{name: "other reaction", emoji: "👍"},
+		{name: "limited reaction", emoji: "🔈", wait: 1200 * time.Millisecond, expected: []string{"<@20>, next voice message will be available in 2s"}},
+		{name: "failed reaction", emoji: "🔈", err: errors.New("tts is down"), expected: []string{"tts is down"}},
+		{name: "message command", interaction: true, expected: []string{""}, file: "OggShello"},
+		{name: "failed message command", interaction: true, err: errors.New("tts is down"), expected: []string{"tts is down", "Command failed, please, try again later"}},
+	}
+
+	for _, v := range testCases {
+		tts := NewTTS(fakeProvider{err: v.err})
+		tts.Limiter = fakeLimiter{wait: v.wait}
+		rt := router.New("!")
+		if err := rt.Add(tts.Name(), tts.Commands()...); err != nil {
+			t.Fatal(err)
+		}
+
+		s := discordtest.NewSession()
+		m := s.AddMessage(discordtest.Message("channel", "10", "hello"))
+
+		if v.interaction {
+			rt.HandleInteraction(s, &discordgo.Interaction{
+				ID:        "interaction",
+				Type:      discordgo.InteractionApplicationCommand,
+				ChannelID: m.ChannelID,
+				GuildID:   m.GuildID,
+				Member:    &discordgo.Member{User: &discordgo.User{ID: "20"}},
+				Data: discordgo.ApplicationCommandInteractionData{
+					Name:        CommandSpeak,
+					CommandType: discordgo.MessageApplicationCommand,
+					TargetID:    m.ID,
+					Resolved: &discordgo.ApplicationCommandInteractionDataResolved{
+						Messages: map[string]*discordgo.Message{m.ID: m},
+					},
+				},
+			})
+		} else {
+			tts.MessageReactionAdd(s, &discordgo.MessageReactionAdd{MessageReaction: &discordgo.MessageReaction{
+				UserID:    "20",
+				MessageID: m.ID,
+				ChannelID: m.ChannelID,
+				GuildID:   m.GuildID,
+				Emoji:     discordgo.Emoji{Name: v.emoji},
+			}})
+		}
+
+		if actual := s.Contents(); !reflect.DeepEqual(actual, v.expected) {
+			t.Errorf("%s: expected %q, actual %q", v.name, v.expected, actual)
+			continue
+		}
+		if v.file == "" {
+			continue
+		}
+
+		name := fmt.Sprintf("%x", md5.Sum([]byte("hello"))) + ".ogg"
+		if data, _ := s.File(s.Sent[0].ID, name); string(data) != v.file {
+			t.Errorf("%s: expected file %s with %q, actual %q", v.name, name, v.file, data)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 
 	"github.com/pkg/errors"
 )
@@ -14,6 +15,16 @@ type TTSClient struct {
 	*http.Client
 
 	RequestURL string
+
+	mu sync.RWMutex
+}
+
+// SetRequestURL replaces url of speech service, safe to call while client is used
+func (c *TTSClient) SetRequestURL(url string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.RequestURL = url
 }
 
 type requestBody struct {
@@ -41,7 +52,11 @@ func (c *TTSClient) Process(text string) (io.Reader, error) {
 
 	r = bytes.NewReader(b)
 
-	req, err := http.NewRequest("POST", c.RequestURL, r)
+	c.mu.RLock()
+	requestURL := c.RequestURL
+	c.mu.RUnlock()
+
+	req, err := http.NewRequest("POST", requestURL, r)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to prepare request")
 	}
@@ -0,0 +1 @@
+DROP TABLE smileyHistory;
\ No newline at end of file
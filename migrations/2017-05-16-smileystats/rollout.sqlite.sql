@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS `smileyHistory` (
+  `emojiId` VARCHAR(20),
+  `emojiName` VARCHAR(20) COLLATE BINARY,
+  `userId` VARCHAR(20),
+  `userName` VARCHAR(20),
+  `createDatetime` DATETIME NOT NULL DEFAULT '0000-00-00 00:00:00',
+  `updateDatetime` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (emojiId, userId, createDatetime)
+);
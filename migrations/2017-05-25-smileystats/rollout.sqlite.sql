@@ -0,0 +1 @@
+-- Strings are compared case sensitively in SQLite by default
@@ -1,3 +0,0 @@
-CREATE TABLE IF NOT EXISTS `users` (
-
-)
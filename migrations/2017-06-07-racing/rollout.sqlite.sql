@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS `raceHistory` (
+	`raceId` INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	`raceDatetime` DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS `raceHistoryStats` (
+	`raceId` INTEGER NOT NULL,
+	`racerId` VARCHAR(255) NOT NULL,
+	`racerUsername` VARCHAR(255) NOT NULL,
+	`racerSpeed` FLOAT NOT NULL,
+	`racerTime` INTEGER NOT NULL,
+	`place` INTEGER NOT NULL,
+	PRIMARY KEY (`raceId`, `racerId`),
+	CONSTRAINT `FK_raceHistoryStats_raceHistory` FOREIGN KEY (`raceId`) REFERENCES `raceHistory` (`raceId`)
+);
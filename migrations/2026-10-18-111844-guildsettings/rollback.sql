@@ -0,0 +1 @@
+DROP TABLE guildSettings;
\ No newline at end of file
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS `guildSettings` (
+	`guildId` VARCHAR(20) NOT NULL,
+	`settings` TEXT NOT NULL,
+	`updateDatetime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+	PRIMARY KEY (`guildId`)
+) COLLATE='utf8_general_ci' ENGINE=InnoDB COMMENT='This table represents per guild bot settings.';
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS `guildSettings` (
+	`guildId` VARCHAR(20) NOT NULL PRIMARY KEY,
+	`settings` TEXT NOT NULL,
+	`updateDatetime` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,16 @@
+-- Databases created from removed db.sql have raceHistoryStats without place column
+-- and with columns too short for discord ids
+SET @hasPlace = (
+	SELECT COUNT(*) FROM information_schema.COLUMNS
+	WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'raceHistoryStats' AND COLUMN_NAME = 'place'
+);
+SET @query = IF(@hasPlace = 0, 'ALTER TABLE `raceHistoryStats` ADD COLUMN `place` INT(11) NOT NULL DEFAULT 0', 'DO 0');
+PREPARE addPlace FROM @query;
+EXECUTE addPlace;
+DEALLOCATE PREPARE addPlace;
+
+ALTER TABLE `raceHistoryStats`
+	MODIFY COLUMN `racerId` VARCHAR(255) NOT NULL,
+	MODIFY COLUMN `racerUsername` VARCHAR(255) NOT NULL,
+	MODIFY COLUMN `racerSpeed` FLOAT(7,6) NOT NULL,
+	MODIFY COLUMN `racerTime` INT(11) NOT NULL;
@@ -0,0 +1 @@
+-- SQLite schema of racing never had these problems
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS `raceLobby` (
+	`id` INT(11) UNSIGNED NOT NULL AUTO_INCREMENT,
+	`racerId` VARCHAR(20) NOT NULL,
+	`racerUsername` VARCHAR(255) NOT NULL,
+	`emoticon` VARCHAR(255) NOT NULL,
+	PRIMARY KEY (`id`),
+	UNIQUE KEY `UQ_raceLobby_racerId` (`racerId`)
+) COLLATE='utf8_general_ci' ENGINE=InnoDB COMMENT='This table represents racers joined to next race.';
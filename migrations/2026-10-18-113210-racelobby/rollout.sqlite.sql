@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS `raceLobby` (
+	`id` INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	`racerId` VARCHAR(20) NOT NULL UNIQUE,
+	`racerUsername` VARCHAR(255) NOT NULL,
+	`emoticon` VARCHAR(255) NOT NULL
+);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS `scheduledJobs` (
+	`id` INT(11) UNSIGNED NOT NULL AUTO_INCREMENT,
+	`handler` VARCHAR(255) NOT NULL,
+	`description` VARCHAR(255) NOT NULL DEFAULT '',
+	`guildId` VARCHAR(20) NOT NULL DEFAULT '',
+	`channelId` VARCHAR(20) NOT NULL DEFAULT '',
+	`createdBy` VARCHAR(20) NOT NULL DEFAULT '',
+	`schedule` VARCHAR(255) NOT NULL DEFAULT '',
+	`payload` TEXT NOT NULL,
+	`nextRun` BIGINT NOT NULL,
+	PRIMARY KEY (`id`),
+	KEY `IX_scheduledJobs_nextRun` (`nextRun`),
+	KEY `IX_scheduledJobs_guildId` (`guildId`)
+) COLLATE='utf8_general_ci' ENGINE=InnoDB COMMENT='This table represents scheduled jobs of plugins.';
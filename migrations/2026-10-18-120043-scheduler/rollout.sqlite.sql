@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS `scheduledJobs` (
+	`id` INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	`handler` VARCHAR(255) NOT NULL,
+	`description` VARCHAR(255) NOT NULL DEFAULT '',
+	`guildId` VARCHAR(20) NOT NULL DEFAULT '',
+	`channelId` VARCHAR(20) NOT NULL DEFAULT '',
+	`createdBy` VARCHAR(20) NOT NULL DEFAULT '',
+	`schedule` VARCHAR(255) NOT NULL DEFAULT '',
+	`payload` TEXT NOT NULL,
+	`nextRun` BIGINT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS `IX_scheduledJobs_nextRun` ON `scheduledJobs` (`nextRun`);
+CREATE INDEX IF NOT EXISTS `IX_scheduledJobs_guildId` ON `scheduledJobs` (`guildId`);
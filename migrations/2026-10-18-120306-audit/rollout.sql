@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS `commandAudit` (
+	`id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+	`callTime` BIGINT NOT NULL,
+	`guildId` VARCHAR(20) NOT NULL DEFAULT '',
+	`channelId` VARCHAR(20) NOT NULL DEFAULT '',
+	`userId` VARCHAR(20) NOT NULL DEFAULT '',
+	`userName` VARCHAR(255) NOT NULL DEFAULT '',
+	`command` VARCHAR(255) NOT NULL,
+	`args` VARCHAR(255) NOT NULL DEFAULT '',
+	`durationMs` BIGINT NOT NULL DEFAULT 0,
+	`outcome` VARCHAR(20) NOT NULL,
+	`error` VARCHAR(255) NOT NULL DEFAULT '',
+	PRIMARY KEY (`id`),
+	KEY `IX_commandAudit_callTime` (`callTime`),
+	KEY `IX_commandAudit_guildId_callTime` (`guildId`, `callTime`)
+) COLLATE='utf8mb4_general_ci' ENGINE=InnoDB COMMENT='This table represents commands called by users.';
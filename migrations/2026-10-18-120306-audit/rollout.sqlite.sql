@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS `commandAudit` (
+	`id` INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	`callTime` BIGINT NOT NULL,
+	`guildId` VARCHAR(20) NOT NULL DEFAULT '',
+	`channelId` VARCHAR(20) NOT NULL DEFAULT '',
+	`userId` VARCHAR(20) NOT NULL DEFAULT '',
+	`userName` VARCHAR(255) NOT NULL DEFAULT '',
+	`command` VARCHAR(255) NOT NULL,
+	`args` VARCHAR(255) NOT NULL DEFAULT '',
+	`durationMs` BIGINT NOT NULL DEFAULT 0,
+	`outcome` VARCHAR(20) NOT NULL,
+	`error` VARCHAR(255) NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS `IX_commandAudit_callTime` ON `commandAudit` (`callTime`);
+CREATE INDEX IF NOT EXISTS `IX_commandAudit_guildId_callTime` ON `commandAudit` (`guildId`, `callTime`);
@@ -0,0 +1,294 @@
+// Package migrations contains versioned database schema of the bot.
+// Every directory is a migration named by time of creation and plugin, e.g. 2026-10-18-120306-audit,
+// with rollout.sql applying it and rollback.sql reverting it in MySQL,
+// rollout.sqlite.sql and rollback.sqlite.sql are the same for SQLite.
+// Old migrations are named by date only, e.g. 2017-06-07-racing, every migration must have unique time.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/paulvasilenko/discordbot/discordbot/storage"
+	"github.com/pkg/errors"
+)
+
+const (
+	RolloutFile  = "rollout"
+	RollbackFile = "rollback"
+
+	DatetimeLayout = "2006-01-02 15:04:05"
+)
+
+// extensions are suffixes of migration files by storage driver
+var extensions = map[string]string{
+	storage.MySQL:  ".sql",
+	storage.SQLite: ".sqlite.sql",
+}
+
+//go:embed */*.sql
+var files embed.FS
+
+var statementEnd = regexp.MustCompile(`;[ \t\r]*(\n|$)`)
+
+// versionRegex matches version of migration: date, optional time and name
+var versionRegex = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})(-\d{6})?-[a-z][a-z0-9_]*$`)
+
+// Migration is a single schema version
+type Migration struct {
+	Version  string
+	Rollout  string
+	Rollback string
+}
+
+// Status describes whether migration is applied
+type Status struct {
+	Version   string
+	AppliedAt string
+}
+
+// Applied returns true if migration is applied to database
+func (s Status) Applied() bool {
+	return s.AppliedAt != ""
+}
+
+// Load returns embedded migrations of storage driver sorted by version
+func Load(driver string) ([]*Migration, error) {
+	return load(files, driver)
+}
+
+func load(fsys fs.FS, driver string) ([]*Migration, error) {
+	ext, ok := extensions[driver]
+	if !ok {
+		return nil, errors.Errorf("no migrations for %q driver", driver)
+	}
+
+	dirs, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read migrations")
+	}
+
+	var migrations []*Migration
+	times := map[string]string{}
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+
+		t, err := versionTime(dir.Name())
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := times[t]; ok {
+			return nil, errors.Errorf("migrations %s and %s have the same time, add time to name of the latest one", other, dir.Name())
+		}
+		times[t] = dir.Name()
+
+		rollout, err := fs.ReadFile(fsys, path.Join(dir.Name(), RolloutFile+ext))
+		if err != nil {
+			return nil, errors.Wrapf(err, "migration %s has no %s", dir.Name(), RolloutFile+ext)
+		}
+		// Missing rollback means that migration cannot be reverted, it is just unmarked
+		rollback, err := fs.ReadFile(fsys, path.Join(dir.Name(), RollbackFile+ext))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, errors.Wrapf(err, "failed to read rollback of %s", dir.Name())
+		}
+
+		migrations = append(migrations, &Migration{
+			Version:  dir.Name(),
+			Rollout:  string(rollout),
+			Rollback: string(rollback),
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		ti, _ := versionTime(migrations[i].Version)
+		tj, _ := versionTime(migrations[j].Version)
+		return ti < tj
+	})
+
+	return migrations, nil
+}
+
+// versionTime returns time of migration, which orders migrations. Migrations named by date are at midnight
+func versionTime(version string) (string, error) {
+	match := versionRegex.FindStringSubmatch(version)
+	if match == nil {
+		return "", errors.Errorf("invalid migration name %s, expected date, optional time and name, e.g. 2026-10-18-120306-audit", version)
+	}
+
+	t := match[2]
+	if t == "" {
+		t = "-000000"
+	}
+
+	return match[1] + t, nil
+}
+
+// Runner applies migrations and keeps applied versions in schema_migrations table
+type Runner struct {
+	db         *sql.DB
+	migrations []*Migration
+}
+
+// NewRunner is a constructor function for runner of embedded migrations
+func NewRunner(db *sql.DB, driver string) (*Runner, error) {
+	migrations, err := Load(driver)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Runner{db: db, migrations: migrations}, nil
+}
+
+// Status returns all known migrations with time they were applied at
+func (r *Runner) Status() ([]Status, error) {
+	applied, err := r.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(r.migrations))
+	for i, m := range r.migrations {
+		statuses[i] = Status{Version: m.Version, AppliedAt: applied[m.Version]}
+	}
+
+	return statuses, nil
+}
+
+// Up applies all pending migrations in order and returns applied versions
+func (r *Runner) Up() ([]string, error) {
+	applied, err := r.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []string
+	for _, m := range r.migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+
+		if err := r.exec(m.Rollout); err != nil {
+			return versions, errors.Wrapf(err, "failed to apply %s", m.Version)
+		}
+
+		if _, err := r.db.Exec(
+			"INSERT INTO schema_migrations (version, appliedAt) VALUES (?, ?)",
+			m.Version,
+			time.Now().UTC().Format(DatetimeLayout),
+		); err != nil {
+			return versions, errors.Wrapf(err, "failed to mark %s as applied", m.Version)
+		}
+
+		versions = append(versions, m.Version)
+	}
+
+	return versions, nil
+}
+
+// Down reverts last applied migration and returns its version, empty if nothing is applied
+func (r *Runner) Down() (string, error) {
+	applied, err := r.applied()
+	if err != nil {
+		return "", err
+	}
+
+	for i := len(r.migrations) - 1; i >= 0; i-- {
+		m := r.migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+
+		if err := r.exec(m.Rollback); err != nil {
+			return "", errors.Wrapf(err, "failed to rollback %s", m.Version)
+		}
+
+		if _, err := r.db.Exec("DELETE FROM schema_migrations WHERE version = ?", m.Version); err != nil {
+			return "", errors.Wrapf(err, "failed to unmark %s", m.Version)
+		}
+
+		return m.Version, nil
+	}
+
+	return "", nil
+}
+
+func (r *Runner) applied() (map[string]string, error) {
+	if _, err := r.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version VARCHAR(255) NOT NULL PRIMARY KEY,
+		appliedAt VARCHAR(19) NOT NULL
+	)`); err != nil {
+		return nil, errors.Wrap(err, "failed to create schema_migrations")
+	}
+
+	rows, err := r.db.Query("SELECT version, appliedAt FROM schema_migrations")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read schema_migrations")
+	}
+	defer rows.Close()
+
+	applied := map[string]string{}
+	for rows.Next() {
+		var version, appliedAt string
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+// exec runs statements on single connection, so session variables set by migration are kept
+func (r *Runner) exec(script string) error {
+	ctx := context.Background()
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	for _, statement := range Statements(script) {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return errors.Wrapf(err, "failed to execute %q", truncate(statement, 80))
+		}
+	}
+
+	return nil
+}
+
+// Statements splits script by semicolons at line ends, lines commented with -- are dropped
+func Statements(script string) []string {
+	lines := strings.Split(script, "\n")
+	code := lines[:0]
+	for _, line := range lines {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			code = append(code, line)
+		}
+	}
+
+	var statements []string
+	for _, statement := range statementEnd.Split(strings.Join(code, "\n"), -1) {
+		if statement = strings.TrimSpace(statement); statement != "" {
+			statements = append(statements, statement)
+		}
+	}
+
+	return statements
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	return s[:n] + "..."
+}
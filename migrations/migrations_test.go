@@ -0,0 +1,158 @@
+package migrations
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/paulvasilenko/discordbot/discordbot/storage"
+)
+
+func Test_Statements(t *testing.T) {
+	testCases := []struct {
+		name     string
+		script   string
+		expected []string
+	}{
+		{
+			name:     "single without semicolon",
+			script:   "DROP TABLE a",
+			expected: []string{"DROP TABLE a"},
+		},
+		{
+			name:     "multiline",
+			script:   "CREATE TABLE a (\n\tb INT\n);\n\nDROP TABLE c;  \r\n",
+			expected: []string{"CREATE TABLE a (\n\tb INT\n)", "DROP TABLE c"},
+		},
+		{
+			name:     "semicolon inside line",
+			script:   "SET @q = 'SELECT 1; SELECT 2';\nPREPARE s FROM @q;",
+			expected: []string{"SET @q = 'SELECT 1; SELECT 2'", "PREPARE s FROM @q"},
+		},
+		{
+			name:     "comments",
+			script:   "-- Comment;\n  -- DROP TABLE b;\nDROP TABLE a;\n",
+			expected: []string{"DROP TABLE a"},
+		},
+		{
+			name:   "empty",
+			script: "\n\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Statements(tc.script); !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func Test_load(t *testing.T) {
+	fsys := fstest.MapFS{
+		"2017-06-07-racing/rollout.sql":         {Data: []byte("CREATE TABLE b;")},
+		"2017-06-07-racing/rollout.sqlite.sql":  {Data: []byte("CREATE TABLE d;")},
+		"2017-05-16-smiley/rollout.sql":         {Data: []byte("CREATE TABLE a;")},
+		"2017-05-16-smiley/rollback.sql":        {Data: []byte("DROP TABLE a;")},
+		"2017-05-16-smiley/rollout.sqlite.sql":  {Data: []byte("CREATE TABLE c;")},
+		"2017-05-16-smiley/rollback.sqlite.sql": {Data: []byte("DROP TABLE c;")},
+	}
+	migrations, err := load(fsys, storage.MySQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*Migration{
+		{Version: "2017-05-16-smiley", Rollout: "CREATE TABLE a;", Rollback: "DROP TABLE a;"},
+		{Version: "2017-06-07-racing", Rollout: "CREATE TABLE b;"},
+	}
+	if !reflect.DeepEqual(migrations, expected) {
+		t.Errorf("expected %+v, got %+v", expected, migrations)
+	}
+
+	migrations, err = load(fsys, storage.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrations[0].Rollout != "CREATE TABLE c;" || migrations[1].Rollout != "CREATE TABLE d;" {
+		t.Errorf("expected sqlite migrations, got %+v", migrations)
+	}
+
+	if _, err := load(fstest.MapFS{"2017-05-16-smiley/rollback.sql": {}}, storage.MySQL); err == nil {
+		t.Error("expected error for migration without rollout")
+	}
+
+	invalid := []fstest.MapFS{
+		{"smiley/rollout.sql": {}},
+		{"2026-10-18-audit/rollout.sql": {}, "2026-10-18-racing/rollout.sql": {}},
+	}
+	for _, fsys := range invalid {
+		if _, err := load(fsys, storage.MySQL); err == nil {
+			t.Errorf("expected error for invalid names of migrations %v", fsys)
+		}
+	}
+
+	// Migrations of the same date are ordered by time, date only goes first
+	migrations, err = load(fstest.MapFS{
+		"2026-10-18-120306-audit/rollout.sql":    {},
+		"2026-10-18-111844-settings/rollout.sql": {},
+		"2026-10-18-racing/rollout.sql":          {},
+	}, storage.MySQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var versions []string
+	for _, m := range migrations {
+		versions = append(versions, m.Version)
+	}
+	if expected := []string{"2026-10-18-racing", "2026-10-18-111844-settings", "2026-10-18-120306-audit"}; !reflect.DeepEqual(versions, expected) {
+		t.Errorf("expected %v, got %v", expected, versions)
+	}
+}
+
+func Test_Runner(t *testing.T) {
+	db, err := storage.Open(storage.SQLite, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	runner, err := NewRunner(db.DB, db.Driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := runner.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(runner.migrations) {
+		t.Errorf("expected %d applied migrations, got %v", len(runner.migrations), applied)
+	}
+
+	if applied, err := runner.Up(); err != nil || len(applied) != 0 {
+		t.Errorf("expected nothing to apply, got %v, %v", applied, err)
+	}
+
+	// Rolls everything back, so every rollback is checked as well
+	for range runner.migrations {
+		if _, err := runner.Down(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	statuses, err := runner.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range statuses {
+		if s.Applied() {
+			t.Errorf("expected %s to be reverted", s.Version)
+		}
+	}
+
+	if _, err := runner.Up(); err != nil {
+		t.Errorf("expected migrations to be applied again, got %v", err)
+	}
+}